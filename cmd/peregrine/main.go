@@ -4,9 +4,15 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 
+	"github.com/Pigmice2733/peregrine-backend/internal/blob"
 	"github.com/Pigmice2733/peregrine-backend/internal/config"
+	"github.com/Pigmice2733/peregrine-backend/internal/jwtkeys"
+	"github.com/Pigmice2733/peregrine-backend/internal/lockout"
+	"github.com/Pigmice2733/peregrine-backend/internal/notify"
+	"github.com/Pigmice2733/peregrine-backend/internal/oidc"
 	"github.com/Pigmice2733/peregrine-backend/internal/server"
 	"github.com/Pigmice2733/peregrine-backend/internal/store"
 	"github.com/Pigmice2733/peregrine-backend/internal/tba"
@@ -65,11 +71,54 @@ func run(configPath string) error {
 		Year:   c.Year,
 	}
 
+	var blobs blob.Store = blob.FileSystem{Dir: c.Media.Dir}
+	if c.Media.S3 != nil {
+		blobs = blob.S3{
+			Endpoint:        c.Media.S3.Endpoint,
+			Region:          c.Media.S3.Region,
+			Bucket:          c.Media.S3.Bucket,
+			AccessKeyID:     c.Media.S3.AccessKeyID,
+			SecretAccessKey: c.Media.S3.SecretAccessKey,
+		}
+	}
+
+	keys, err := openJWTKeys(c.Server)
+	if err != nil {
+		return fmt.Errorf("unable to open jwt keys: %w", err)
+	}
+
+	var lockouts lockout.Store = &lockout.Memory{}
+	if c.Server.LockoutStore == "postgres" {
+		lockouts = sto
+	}
+
 	s := &server.Server{
 		TBA:    tba,
 		Store:  sto,
 		Logger: logger,
 		Server: c.Server,
+		Media:  c.Media,
+		Blobs:  blobs,
+		Keys:   keys,
+		Lockout: &lockout.Limiter{
+			Store:    lockouts,
+			Username: lockout.DefaultUsernamePolicy,
+			IP:       lockout.DefaultIPPolicy,
+		},
+	}
+
+	if c.OIDC != nil {
+		s.OIDC = &oidc.Provider{
+			Issuer:       c.OIDC.Issuer,
+			ClientID:     c.OIDC.ClientID,
+			ClientSecret: c.OIDC.ClientSecret,
+			RedirectURL:  c.OIDC.RedirectURL,
+		}
+		s.OIDCRealmDomains = c.OIDC.RealmDomains
+	}
+
+	if c.Webhook != nil {
+		s.Notifier = notify.Webhook{URL: c.Webhook.URL, Secret: c.Webhook.Secret}
 	}
 
 	tbaUpdates.Begin()
@@ -80,3 +129,32 @@ func run(configPath string) error {
 
 	return err
 }
+
+// openJWTKeys returns the keys that sign and verify JWTs: an HMAC key for the
+// JWT secret, if there is one, and the configured private keys.
+func openJWTKeys(c config.Server) (*jwtkeys.Set, error) {
+	var keys []jwtkeys.Key
+	if c.JWTSecret != "" {
+		key := jwtkeys.HMACKey(c.JWTSecret)
+		key.RetireAt = c.JWTSecretRetireAt
+		keys = append(keys, key)
+	}
+
+	for _, k := range c.JWTKeys {
+		data, err := ioutil.ReadFile(k.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read key %q: %w", k.ID, err)
+		}
+
+		key, err := jwtkeys.ParsePrivateKey(k.ID, data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse key %q: %w", k.ID, err)
+		}
+
+		key.SignFrom = k.SignFrom
+		key.RetireAt = k.RetireAt
+		keys = append(keys, key)
+	}
+
+	return jwtkeys.New(keys...)
+}
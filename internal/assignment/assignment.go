@@ -0,0 +1,135 @@
+package assignment
+
+import (
+	"time"
+)
+
+// Match defines a match that scouts should be assigned to. Time is nil if the
+// match has not been scheduled yet, and Teams holds the keys of every robot in
+// the match.
+type Match struct {
+	Key   string
+	Time  *time.Time
+	Teams []string
+}
+
+// Shift is a period of time a scout is available to scout.
+type Shift struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Scout defines a scout that can be assigned to matches. A scout with no
+// shifts is available for every match, otherwise they are only available for
+// matches that start during one of their shifts.
+type Scout struct {
+	ID     int64
+	Shifts []Shift
+}
+
+// available returns whether the scout can scout a match at the given time.
+func (s Scout) available(t *time.Time) bool {
+	if len(s.Shifts) == 0 {
+		return true
+	}
+
+	if t == nil {
+		return false
+	}
+
+	for _, shift := range s.Shifts {
+		if !t.Before(shift.Start) && t.Before(shift.End) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Slot is a single robot in a single match.
+type Slot struct {
+	MatchKey string
+	TeamKey  string
+}
+
+// Assignment assigns a scout to a slot.
+type Assignment struct {
+	Slot
+	ScoutID int64
+}
+
+// Schedule holds the generated assignments and any slots that could not be
+// assigned because not enough scouts were available.
+type Schedule struct {
+	Assignments []Assignment
+	Unassigned  []Slot
+}
+
+// Generate creates a scouting rotation for the given matches, which should be
+// in the order they will be played. Each robot in each match is assigned to an
+// available scout, spreading matches as evenly as possible between scouts. If
+// maxConsecutive is positive, scouts who have scouted that many matches in a
+// row are given a break as long as another scout is available to take over.
+func Generate(matches []Match, scouts []Scout, maxConsecutive int) Schedule {
+	schedule := Schedule{Assignments: []Assignment{}, Unassigned: []Slot{}}
+
+	total := make(map[int64]int)
+	consecutive := make(map[int64]int)
+
+	for _, match := range matches {
+		assigned := make(map[int64]bool)
+
+		for _, team := range match.Teams {
+			slot := Slot{MatchKey: match.Key, TeamKey: team}
+
+			var best *Scout
+			for i := range scouts {
+				scout := &scouts[i]
+				if assigned[scout.ID] || !scout.available(match.Time) {
+					continue
+				}
+
+				if best == nil || better(*scout, *best, total, consecutive, maxConsecutive) {
+					best = scout
+				}
+			}
+
+			if best == nil {
+				schedule.Unassigned = append(schedule.Unassigned, slot)
+				continue
+			}
+
+			assigned[best.ID] = true
+			total[best.ID]++
+			schedule.Assignments = append(schedule.Assignments, Assignment{Slot: slot, ScoutID: best.ID})
+		}
+
+		for _, scout := range scouts {
+			if assigned[scout.ID] {
+				consecutive[scout.ID]++
+			} else {
+				consecutive[scout.ID] = 0
+			}
+		}
+	}
+
+	return schedule
+}
+
+// better returns whether scout a should be picked over scout b. Rested scouts
+// are preferred, then scouts with fewer assignments, then lower IDs so that
+// schedules are deterministic.
+func better(a, b Scout, total, consecutive map[int64]int, maxConsecutive int) bool {
+	if maxConsecutive > 0 {
+		aRested, bRested := consecutive[a.ID] < maxConsecutive, consecutive[b.ID] < maxConsecutive
+		if aRested != bRested {
+			return aRested
+		}
+	}
+
+	if total[a.ID] != total[b.ID] {
+		return total[a.ID] < total[b.ID]
+	}
+
+	return a.ID < b.ID
+}
@@ -0,0 +1,114 @@
+package assignment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGenerate(t *testing.T) {
+	start := time.Date(2019, 3, 2, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) *time.Time {
+		t := start.Add(time.Duration(minutes) * time.Minute)
+		return &t
+	}
+
+	testCases := []struct {
+		name             string
+		matches          []Match
+		scouts           []Scout
+		maxConsecutive   int
+		expectedSchedule Schedule
+	}{
+		{
+			name:    "no matches",
+			scouts:  []Scout{{ID: 1}},
+			matches: nil,
+			expectedSchedule: Schedule{
+				Assignments: []Assignment{},
+				Unassigned:  []Slot{},
+			},
+		},
+		{
+			name: "spreads matches evenly",
+			matches: []Match{
+				{Key: "qm1", Time: at(0), Teams: []string{"frc1", "frc2"}},
+				{Key: "qm2", Time: at(7), Teams: []string{"frc3", "frc4"}},
+			},
+			scouts: []Scout{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}},
+			expectedSchedule: Schedule{
+				Assignments: []Assignment{
+					{Slot: Slot{MatchKey: "qm1", TeamKey: "frc1"}, ScoutID: 1},
+					{Slot: Slot{MatchKey: "qm1", TeamKey: "frc2"}, ScoutID: 2},
+					{Slot: Slot{MatchKey: "qm2", TeamKey: "frc3"}, ScoutID: 3},
+					{Slot: Slot{MatchKey: "qm2", TeamKey: "frc4"}, ScoutID: 4},
+				},
+				Unassigned: []Slot{},
+			},
+		},
+		{
+			name: "not enough scouts",
+			matches: []Match{
+				{Key: "qm1", Time: at(0), Teams: []string{"frc1", "frc2", "frc3"}},
+			},
+			scouts: []Scout{{ID: 1}, {ID: 2}},
+			expectedSchedule: Schedule{
+				Assignments: []Assignment{
+					{Slot: Slot{MatchKey: "qm1", TeamKey: "frc1"}, ScoutID: 1},
+					{Slot: Slot{MatchKey: "qm1", TeamKey: "frc2"}, ScoutID: 2},
+				},
+				Unassigned: []Slot{{MatchKey: "qm1", TeamKey: "frc3"}},
+			},
+		},
+		{
+			name: "respects shifts",
+			matches: []Match{
+				{Key: "qm1", Time: at(0), Teams: []string{"frc1"}},
+				{Key: "qm2", Time: at(60), Teams: []string{"frc2"}},
+				{Key: "qm3", Teams: []string{"frc3"}},
+			},
+			scouts: []Scout{
+				{ID: 1, Shifts: []Shift{{Start: start, End: *at(30)}}},
+				{ID: 2, Shifts: []Shift{{Start: *at(30), End: *at(90)}}},
+			},
+			expectedSchedule: Schedule{
+				Assignments: []Assignment{
+					{Slot: Slot{MatchKey: "qm1", TeamKey: "frc1"}, ScoutID: 1},
+					{Slot: Slot{MatchKey: "qm2", TeamKey: "frc2"}, ScoutID: 2},
+				},
+				Unassigned: []Slot{{MatchKey: "qm3", TeamKey: "frc3"}},
+			},
+		},
+		{
+			name: "gives breaks after consecutive matches",
+			matches: []Match{
+				{Key: "qm1", Time: at(0), Teams: []string{"frc1"}},
+				{Key: "qm2", Time: at(7), Teams: []string{"frc2"}},
+				{Key: "qm3", Time: at(14), Teams: []string{"frc3"}},
+			},
+			scouts: []Scout{
+				{ID: 1},
+				{ID: 2, Shifts: []Shift{{Start: *at(10), End: *at(20)}}},
+			},
+			maxConsecutive: 2,
+			expectedSchedule: Schedule{
+				Assignments: []Assignment{
+					{Slot: Slot{MatchKey: "qm1", TeamKey: "frc1"}, ScoutID: 1},
+					{Slot: Slot{MatchKey: "qm2", TeamKey: "frc2"}, ScoutID: 1},
+					{Slot: Slot{MatchKey: "qm3", TeamKey: "frc3"}, ScoutID: 2},
+				},
+				Unassigned: []Slot{},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := Generate(tt.matches, tt.scouts, tt.maxConsecutive)
+			if !cmp.Equal(tt.expectedSchedule, schedule) {
+				t.Errorf("expected schedule to match expected schedule, but got diff: %s", cmp.Diff(tt.expectedSchedule, schedule))
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Package blob stores uploaded files such as photos and videos.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when getting a blob that doesn't exist.
+var ErrNotFound = errors.New("blob not found")
+
+// Reader reads a stored blob. It can seek, so ranges of the blob can be read
+// without reading all of it.
+type Reader interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// Info describes a stored blob.
+type Info struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// Store stores blobs by key. Keys are generated by the caller and may contain
+// slashes, but are otherwise made of letters, digits, dashes, and dots.
+type Store interface {
+	// Put stores the data read from r under a key, replacing any existing
+	// blob with that key.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get returns a reader for the data stored under a key and information
+	// about it, or ErrNotFound. The reader must be closed.
+	Get(ctx context.Context, key string) (Reader, Info, error)
+	// Delete removes the blob stored under a key. Deleting a blob that
+	// doesn't exist is not an error.
+	Delete(ctx context.Context, key string) error
+}
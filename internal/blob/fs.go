@@ -0,0 +1,95 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileSystem stores blobs as files in a directory on the local filesystem.
+type FileSystem struct {
+	Dir string
+}
+
+func (f FileSystem) path(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || filepath.IsAbs(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+
+	return filepath.Join(f.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes data to the file for a key, creating any missing directories.
+func (f FileSystem) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("unable to create blob directory: %w", err)
+	}
+
+	// write to a temporary file first so partially written blobs are never read
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write blob: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to close blob: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to rename blob: %w", err)
+	}
+
+	return nil
+}
+
+// Get opens the file for a key.
+func (f FileSystem) Get(ctx context.Context, key string) (Reader, Info, error) {
+	path, err := f.path(key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, Info{}, ErrNotFound
+	} else if err != nil {
+		return nil, Info{}, fmt.Errorf("unable to open blob: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, Info{}, fmt.Errorf("unable to stat blob: %w", err)
+	}
+
+	return file, Info{Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+// Delete removes the file for a key.
+func (f FileSystem) Delete(ctx context.Context, key string) error {
+	path, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to delete blob: %w", err)
+	}
+
+	return nil
+}
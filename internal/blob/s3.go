@@ -0,0 +1,290 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 stores blobs in a bucket of an S3-compatible object store (AWS S3, MinIO,
+// etc.). Requests use path-style URLs and are signed with AWS Signature
+// Version 4.
+type S3 struct {
+	// Endpoint is the base URL of the object store, e.g.
+	// https://s3.us-west-2.amazonaws.com or http://localhost:9000.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Client is used to make requests, http.DefaultClient if nil.
+	Client *http.Client
+	// Now returns the current time for signing requests, time.Now if nil.
+	Now func() time.Time
+}
+
+func (s S3) url(key string) string {
+	return strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + "/" + key
+}
+
+// emptyPayloadHash is the hex encoded SHA-256 hash of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (s S3) do(ctx context.Context, method, key string, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.url(key), body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = size
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	signV4(req, payloadHash, s.Region, s.AccessKeyID, s.SecretAccessKey, now())
+
+	client := http.DefaultClient
+	if s.Client != nil {
+		client = s.Client
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to do %s request: %w", method, err)
+	}
+
+	return resp, nil
+}
+
+// Put uploads data to the object for a key. The data is copied to a temporary
+// file first, since the request is signed with its hash and needs its length.
+func (s S3) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	tmp, err := ioutil.TempFile("", "peregrine-blob-")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return fmt.Errorf("unable to write temporary blob file: %w", err)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to seek temporary blob file: %w", err)
+	}
+
+	var body io.Reader = tmp
+	if size == 0 {
+		body = http.NoBody
+	}
+
+	header := http.Header{"Content-Type": {contentType}}
+	resp, err := s.do(ctx, http.MethodPut, key, header, body, size, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("got unexpected status code putting object: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Get gets information about the object for a key. The object is downloaded as
+// it is read, starting from the offset it was last seeked to.
+func (s S3) Get(ctx context.Context, key string) (Reader, Info, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, Info{}, ErrNotFound
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, Info{}, fmt.Errorf("got unexpected status code getting object: %d", resp.StatusCode)
+	}
+
+	// a missing or malformed Last-Modified header leaves the zero time
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	info := Info{Size: resp.ContentLength, ModTime: modTime}
+
+	return &s3Reader{s: s, ctx: ctx, key: key, size: info.Size}, info, nil
+}
+
+// s3Reader reads an object from the offset it was last seeked to, with a ranged
+// GET request that is made on the first read after seeking.
+type s3Reader struct {
+	s    S3
+	ctx  context.Context
+	key  string
+	size int64
+	off  int64
+	body io.ReadCloser
+}
+
+func (r *s3Reader) Read(p []byte) (int, error) {
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		header := http.Header{"Range": {fmt.Sprintf("bytes=%d-", r.off)}}
+		resp, err := r.s.do(r.ctx, http.MethodGet, r.key, header, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return 0, err
+		}
+
+		if resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && r.off == 0) {
+			resp.Body.Close()
+			return 0, fmt.Errorf("got unexpected status code getting object: %d", resp.StatusCode)
+		}
+		r.body = resp.Body
+	}
+
+	n, err := r.body.Read(p)
+	r.off += int64(n)
+	return n, err
+}
+
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("seek to negative offset")
+	}
+
+	if offset != r.off && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.off = offset
+
+	return offset, nil
+}
+
+func (r *s3Reader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+
+	return r.body.Close()
+}
+
+// Delete deletes the object for a key.
+func (s S3) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("got unexpected status code deleting object: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// signV4 signs a request for S3 with AWS Signature Version 4, setting the
+// X-Amz-Date, X-Amz-Content-Sha256, and Authorization headers. Every header
+// already set on the request is signed, along with the host.
+func signV4(req *http.Request, payloadHash, region, accessKeyID, secretAccessKey string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath URI encodes a path the way AWS expects, leaving only unreserved
+// characters and slashes unescaped.
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"gopkg.in/go-playground/validator.v9"
@@ -11,11 +12,76 @@ import (
 
 // Server holds information about the peregrine backend HTTP server.
 type Server struct {
-	Listen    string       `json:"listen" validate:"required"`
-	Origin    string       `json:"origin" validate:"required"`
-	LogLevel  logrus.Level `json:"logLevel"`
-	LogJSON   bool         `json:"logJSON"`
-	JWTSecret string       `json:"jwtSecret" validate:"required,min=32"`
+	Listen   string       `json:"listen" validate:"required"`
+	Origin   string       `json:"origin" validate:"required"`
+	LogLevel logrus.Level `json:"logLevel"`
+	LogJSON  bool         `json:"logJSON"`
+	// JWTSecret signs JWTs with HS256 until the first of JWTKeys starts signing,
+	// and verifies tokens without a key ID until JWTSecretRetireAt, if it is
+	// set. It is only required if there are no JWTKeys, or OIDC is configured,
+	// since it also signs OIDC login state.
+	JWTSecret         string    `json:"jwtSecret" validate:"required_without=JWTKeys,omitempty,min=32"`
+	JWTSecretRetireAt time.Time `json:"jwtSecretRetireAt"`
+	// LockoutStore is where failed logins are stored, either "memory" or
+	// "postgres". Servers only agree on who is locked out if it's "postgres".
+	LockoutStore string `json:"lockoutStore" validate:"omitempty,oneof=memory postgres"`
+	// JWTKeys are RSA or Ed25519 keys that sign JWTs.
+	JWTKeys []JWTKey `json:"jwtKeys" validate:"dive"`
+}
+
+// JWTKey is a PEM encoded private key that signs JWTs. The newest key whose
+// SignFrom has passed signs new tokens, and tokens it signed are accepted until
+// RetireAt, if it is set. To rotate keys, add a new key with a SignFrom in the
+// future so other services can fetch it first, and retire the old key at least
+// four weeks (the lifetime of refresh tokens) after the new key's SignFrom.
+type JWTKey struct {
+	ID             string    `json:"id" validate:"required"`
+	PrivateKeyFile string    `json:"privateKeyFile" validate:"required"`
+	SignFrom       time.Time `json:"signFrom"`
+	RetireAt       time.Time `json:"retireAt"`
+}
+
+// Default media limits, used when they aren't configured.
+const (
+	DefaultMaxImageBytes = 10 << 20 // 10 MiB
+	DefaultMaxVideoBytes = 50 << 20 // 50 MiB
+)
+
+// S3 holds information about an S3-compatible object store.
+type S3 struct {
+	Endpoint        string `json:"endpoint" validate:"required,url"`
+	Region          string `json:"region" validate:"required"`
+	Bucket          string `json:"bucket" validate:"required"`
+	AccessKeyID     string `json:"accessKeyId" validate:"required"`
+	SecretAccessKey string `json:"secretAccessKey" validate:"required"`
+}
+
+// Media holds information about how uploaded photos and videos are stored. They
+// are stored in S3 if it is configured, otherwise in Dir on the local filesystem.
+type Media struct {
+	Dir           string `json:"dir"`
+	S3            *S3    `json:"s3"`
+	MaxImageBytes int64  `json:"maxImageBytes" validate:"gte=0"`
+	MaxVideoBytes int64  `json:"maxVideoBytes" validate:"gte=0"`
+}
+
+// OIDC holds information about an OpenID Connect provider users can log in
+// with. RedirectURL is the page of the frontend the provider sends users back to.
+// Users with a verified email address in one of RealmDomains automatically join
+// its realm the first time they log in.
+type OIDC struct {
+	Issuer       string           `json:"issuer" validate:"required,url"`
+	ClientID     string           `json:"clientId" validate:"required"`
+	ClientSecret string           `json:"clientSecret" validate:"required"`
+	RedirectURL  string           `json:"redirectURL" validate:"required,url"`
+	RealmDomains map[string]int64 `json:"realmDomains"`
+}
+
+// Webhook holds information about where notifications, such as password reset
+// tokens, are posted. Requests are signed with Secret.
+type Webhook struct {
+	URL    string `json:"url" validate:"required,url"`
+	Secret string `json:"secret" validate:"required,min=32"`
 }
 
 // Config holds information about how the peregrine backend is configured.
@@ -26,7 +92,10 @@ type Config struct {
 		URL    string `validate:"required"`
 		APIKey string `validate:"required"`
 	} `json:"tba"`
-	DSN string `json:"dsn" validate:"required"`
+	DSN     string   `json:"dsn" validate:"required"`
+	Media   Media    `json:"media"`
+	OIDC    *OIDC    `json:"oidc"`
+	Webhook *Webhook `json:"webhook"`
 }
 
 // Open parses and validates the JSON config at the given path.
@@ -42,10 +111,24 @@ func Open(path string) (Config, error) {
 		return Config{}, fmt.Errorf("unable to unmarshal file: %w", err)
 	}
 
+	if c.Media.Dir == "" {
+		c.Media.Dir = "media"
+	}
+	if c.Media.MaxImageBytes == 0 {
+		c.Media.MaxImageBytes = DefaultMaxImageBytes
+	}
+	if c.Media.MaxVideoBytes == 0 {
+		c.Media.MaxVideoBytes = DefaultMaxVideoBytes
+	}
+
 	validate := validator.New()
 	if err := validate.Struct(c); err != nil {
 		return Config{}, fmt.Errorf("config loaded from %q fails to validate: %w", path, err)
 	}
 
+	if c.OIDC != nil && c.Server.JWTSecret == "" {
+		return Config{}, fmt.Errorf("config loaded from %q fails to validate: jwtSecret is required with oidc", path)
+	}
+
 	return c, nil
 }
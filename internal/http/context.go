@@ -12,21 +12,42 @@ import (
 type contextKey string
 
 const (
-	keyRolesContext   contextKey = "peregrine_roles"
-	keySubjectContext contextKey = "peregrine_subject"
-	keyRealmContext   contextKey = "peregrine_realm"
+	keyPermissionsContext contextKey = "peregrine_permissions"
+	keySubjectContext     contextKey = "peregrine_subject"
+	keyRealmContext       contextKey = "peregrine_realm"
+	keyAPIKeyContext      contextKey = "peregrine_api_key"
+	keySessionContext     contextKey = "peregrine_session"
 )
 
-// Claims holds the standard jwt claims, peregrine roles, and realm id.
+// Types of JWTs. Access and refresh tokens are signed with the same keys, so
+// each says which it is to keep one from being used as the other.
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
+// Claims holds the standard jwt claims, peregrine permissions, realm id, and the
+// id of the session the token was issued for. Tokens are scoped to a single
+// realm, and the permissions are the user's permissions in it. Tokens for
+// pending members of a realm have a realm ID of 0.
 type Claims struct {
-	Roles   store.Roles `json:"peregrineRoles"`
-	RealmID int64       `json:"peregrineRealm"`
+	Type        string            `json:"peregrineType"`
+	Permissions store.Permissions `json:"peregrinePermissions"`
+	RealmID     int64             `json:"peregrineRealm"`
+	SessionID   int64             `json:"peregrineSession,omitempty"`
 	jwt.StandardClaims
 }
 
-// RefreshClaims holds the standard jwt claims plus
+// RefreshClaims holds the standard jwt claims plus when the user's password was
+// changed, the realm access tokens should be scoped to, and the session and
+// generation of the token. If the realm ID is 0 access tokens are scoped to the
+// realm the user logs in to by default.
 type RefreshClaims struct {
-	PasswordChanged int64 `json:"peregrinePasswordChanged"`
+	Type            string `json:"peregrineType"`
+	PasswordChanged int64  `json:"peregrinePasswordChanged"`
+	RealmID         int64  `json:"peregrineRealm,omitempty"`
+	SessionID       int64  `json:"peregrineSession,omitempty"`
+	Generation      int64  `json:"peregrineGeneration,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -50,22 +71,24 @@ func GetSubject(r *http.Request) (int64, error) {
 	return id, nil
 }
 
-// GetRoles retrieves the roles from the http context.
-func GetRoles(r *http.Request) store.Roles {
-	contextRoles := r.Context().Value(keyRolesContext)
-	if contextRoles == nil {
-		return store.Roles{}
+// GetPermissions retrieves the permissions from the http context.
+func GetPermissions(r *http.Request) store.Permissions {
+	contextPermissions := r.Context().Value(keyPermissionsContext)
+	if contextPermissions == nil {
+		return store.Permissions{}
 	}
 
-	roles, ok := contextRoles.(store.Roles)
+	permissions, ok := contextPermissions.(store.Permissions)
 	if !ok {
-		return store.Roles{}
+		return store.Permissions{}
 	}
 
-	return roles
+	return permissions
 }
 
-// GetRealmID retrieves the ID of the user's realm from the http context.
+// GetRealmID retrieves the ID of the realm the user's token is scoped to from the
+// http context. It returns an error if the user isn't logged in, or is only a
+// pending member of the realm.
 func GetRealmID(r *http.Request) (int64, error) {
 	contextRealm := r.Context().Value(keyRealmContext)
 	if contextRealm == nil {
@@ -78,3 +101,17 @@ func GetRealmID(r *http.Request) (int64, error) {
 	}
 	return realmID, nil
 }
+
+// GetAPIKeyID retrieves the ID of the API key the request was authenticated
+// with from the http context, and whether there was one.
+func GetAPIKeyID(r *http.Request) (int64, bool) {
+	id, ok := r.Context().Value(keyAPIKeyContext).(int64)
+	return id, ok
+}
+
+// GetSessionID retrieves the ID of the session the user's access token was issued
+// for from the http context, and whether there was one.
+func GetSessionID(r *http.Request) (int64, bool) {
+	id, ok := r.Context().Value(keySessionContext).(int64)
+	return id, ok
+}
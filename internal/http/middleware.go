@@ -2,11 +2,15 @@ package http
 
 import (
 	"context"
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/sirupsen/logrus"
 )
@@ -17,6 +21,7 @@ func CORS(next http.Handler, origin string) http.Handler {
 		w.Header().Set("Access-Control-Allow-Origin", origin)
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, PATCH, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
 
 		if r.Method == "OPTIONS" {
 			return
@@ -26,11 +31,39 @@ func CORS(next http.Handler, origin string) http.Handler {
 	})
 }
 
+// DefaultBodyLimit is the most bytes a request body can contain, unless a
+// larger limit is given for the request.
+const DefaultBodyLimit = 1000000 // 1 MB
+
 // LimitBody is middleware to protect the server from requests containing
-// massive amounts of data.
-func LimitBody(next http.Handler) http.Handler {
+// massive amounts of data. If limit is not nil it returns the limit for each
+// request, otherwise DefaultBodyLimit is used.
+func LimitBody(next http.Handler, limit func(*http.Request) int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int64(DefaultBodyLimit)
+		if limit != nil {
+			n = limit(r)
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Deadline is middleware to give some requests longer to be read and
+// responded to than the server's timeouts allow. timeout returns how long each
+// request is given, or zero to keep the server's timeouts. It must wrap the
+// http.ResponseWriter given by the server, since the deadlines are set on the
+// underlying connection.
+func Deadline(next http.Handler, timeout func(*http.Request) time.Duration) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		r.Body = http.MaxBytesReader(w, r.Body, 1000000) // 1 MB
+		if d := timeout(r); d > 0 {
+			rc := http.NewResponseController(w)
+			deadline := time.Now().Add(d)
+			_ = rc.SetReadDeadline(deadline)
+			_ = rc.SetWriteDeadline(deadline)
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -65,8 +98,6 @@ func Log(next http.Handler, l *logrus.Logger) http.HandlerFunc {
 		next.ServeHTTP(rr, r)
 		end := time.Now()
 
-		roles := GetRoles(r)
-
 		fields := logrus.Fields{
 			"method":      r.Method,
 			"remoteAddr":  r.RemoteAddr,
@@ -75,8 +106,7 @@ func Log(next http.Handler, l *logrus.Logger) http.HandlerFunc {
 			"requestTime": end.Sub(start).Seconds(),
 			"statusCode":  rr.code,
 			"bodySize":    rr.length,
-			"admin":       roles.IsAdmin,
-			"superAdmin":  roles.IsSuperAdmin,
+			"permissions": GetPermissions(r),
 			"userAgent":   r.Header.Get("User-Agent"),
 		}
 
@@ -88,6 +118,10 @@ func Log(next http.Handler, l *logrus.Logger) http.HandlerFunc {
 			fields["realmId"] = realm
 		}
 
+		if apiKey, ok := GetAPIKeyID(r); ok {
+			fields["apiKeyId"] = apiKey
+		}
+
 		withFields := l.WithFields(fields)
 		if rr.code >= 200 && rr.code < 300 {
 			withFields.Info("got request")
@@ -99,8 +133,33 @@ func Log(next http.Handler, l *logrus.Logger) http.HandlerFunc {
 	}
 }
 
-// Auth returns a middleware used for jwt authentication.
-func Auth(next http.Handler, secret string) http.Handler {
+// APIKeyPrefix is the prefix of every API key, which distinguishes them from
+// JWTs.
+const APIKeyPrefix = "pgk_"
+
+// HashAPIKey returns the hash of an API key that is stored.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyUser is used for retrieving API keys by the hash of the key. It should
+// return store.ErrNoResults if the key doesn't exist or has been revoked.
+type APIKeyUser interface {
+	UseAPIKey(ctx context.Context, keyHash string) (store.APIKey, error)
+}
+
+// SessionChecker is used for checking that the session an access token was
+// issued for is still active. It should return store.ErrNoResults if the session
+// doesn't exist, has been revoked, or has expired.
+type SessionChecker interface {
+	CheckSession(ctx context.Context, userID, id int64) error
+}
+
+// Auth returns a middleware used for jwt and API key authentication. JWTs are
+// verified with the key returned by keyfunc, and must be access tokens for a
+// session that is still active.
+func Auth(next http.Handler, keyfunc jwt.Keyfunc, keys APIKeyUser, sessions SessionChecker) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Authorization") == "" {
 			next.ServeHTTP(w, r)
@@ -108,13 +167,30 @@ func Auth(next http.Handler, secret string) http.Handler {
 		}
 
 		ss := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
-		token, err := jwt.ParseWithClaims(ss, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		if strings.HasPrefix(ss, APIKeyPrefix) {
+			key, err := keys.UseAPIKey(r.Context(), HashAPIKey(ss))
+			if errors.Is(err, store.ErrNoResults{}) {
+				Error(w, http.StatusUnauthorized)
+				return
+			} else if err != nil {
+				Error(w, http.StatusInternalServerError)
+				return
+			}
+
+			permissions := key.Permissions()
+			ctx := context.WithValue(r.Context(), keyPermissionsContext, permissions)
+			ctx = context.WithValue(ctx, keySubjectContext, strconv.FormatInt(key.CreatorID, 10))
+			// Keys can only see their realm's private data if they can view reports.
+			if permissions.Has(store.PermissionViewReports) {
+				ctx = context.WithValue(ctx, keyRealmContext, key.RealmID)
 			}
+			ctx = context.WithValue(ctx, keyAPIKeyContext, key.ID)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
 
-			return []byte(secret), nil
-		})
+		token, err := jwt.ParseWithClaims(ss, &Claims{}, keyfunc)
 		if err != nil {
 			Error(w, http.StatusUnauthorized)
 			return
@@ -126,34 +202,65 @@ func Auth(next http.Handler, secret string) http.Handler {
 		}
 
 		claims, ok := token.Claims.(*Claims)
-		if !ok {
+		if !ok || claims.Type != AccessTokenType || claims.SessionID == 0 {
+			Error(w, http.StatusUnauthorized)
+			return
+		}
+
+		userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+		if err != nil {
 			Error(w, http.StatusUnauthorized)
 			return
 		}
 
-		ctx := context.WithValue(r.Context(), keyRolesContext, claims.Roles)
+		if err := sessions.CheckSession(r.Context(), userID, claims.SessionID); errors.Is(err, store.ErrNoResults{}) {
+			Error(w, http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), keyPermissionsContext, claims.Permissions)
 		ctx = context.WithValue(ctx, keySubjectContext, claims.Subject)
-		ctx = context.WithValue(ctx, keyRealmContext, claims.RealmID)
+		if claims.RealmID != 0 {
+			ctx = context.WithValue(ctx, keyRealmContext, claims.RealmID)
+		}
+		ctx = context.WithValue(ctx, keySessionContext, claims.SessionID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// NoAPIKey returns a middleware that rejects requests authenticated with an API
+// key, for endpoints that act on the user's account rather than their realm.
+func NoAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetAPIKeyID(r); ok {
+			Error(w, http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
 // ACL returns a middleware that must be used inside of an Auth middleware for
-// checking user roles. The SuperOrAdmin requirement will be satisfied by any
-// user who is either a SuperAdmin or a realm Admin.
-func ACL(next http.HandlerFunc, requireSuperOrAdmin, requireVerified, requireLoggedIn bool) http.HandlerFunc {
+// checking user permissions. The user must be logged in and have every one of
+// the given permissions.
+func ACL(next http.HandlerFunc, permissions ...store.Permission) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		_, err := GetSubject(r)
-		if err != nil && requireLoggedIn {
+		if _, err := GetSubject(r); err != nil {
 			Error(w, http.StatusUnauthorized)
 			return
 		}
 
-		roles := GetRoles(r)
-		if (requireSuperOrAdmin && !roles.IsSuperAdmin && !roles.IsAdmin) || (requireVerified && !roles.IsVerified) {
-			Error(w, http.StatusForbidden)
-			return
+		userPermissions := GetPermissions(r)
+		for _, p := range permissions {
+			if !userPermissions.Has(p) {
+				Error(w, http.StatusForbidden)
+				return
+			}
 		}
 
 		next(w, r)
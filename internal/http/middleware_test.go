@@ -0,0 +1,175 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/jwtkeys"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+type mockAuthStore struct {
+	key        store.APIKey
+	keyErr     error
+	sessionErr error
+}
+
+func (m mockAuthStore) UseAPIKey(ctx context.Context, keyHash string) (store.APIKey, error) {
+	return m.key, m.keyErr
+}
+
+func (m mockAuthStore) CheckSession(ctx context.Context, userID, id int64) error {
+	return m.sessionErr
+}
+
+func TestAuth(t *testing.T) {
+	keys := jwtkeys.NewHMAC("i-am-secret")
+	expires := time.Now().Add(time.Minute).Unix()
+
+	sign := func(claims jwt.Claims) string {
+		token, err := keys.Sign(claims)
+		if err != nil {
+			t.Fatalf("unable to sign token: %v", err)
+		}
+		return token
+	}
+
+	accessToken := sign(&Claims{
+		StandardClaims: jwt.StandardClaims{ExpiresAt: expires, Subject: "7"},
+		Type:           AccessTokenType,
+		RealmID:        3,
+		SessionID:      1,
+	})
+
+	testCases := []struct {
+		name               string
+		token              string
+		store              mockAuthStore
+		expectedStatusCode int
+		expectedSubject    int64
+		expectedRealmID    int64
+	}{
+		{
+			name:               "no token",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "access token",
+			token:              accessToken,
+			expectedStatusCode: http.StatusOK,
+			expectedSubject:    7,
+			expectedRealmID:    3,
+		},
+		{
+			name:               "revoked session",
+			token:              accessToken,
+			store:              mockAuthStore{sessionErr: store.ErrNoResults{}},
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "refresh token",
+			token: sign(&RefreshClaims{
+				StandardClaims: jwt.StandardClaims{ExpiresAt: expires, Subject: "7"},
+				Type:           RefreshTokenType,
+				RealmID:        3,
+				SessionID:      1,
+				Generation:     2,
+			}),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "token without a type",
+			token: sign(&Claims{
+				StandardClaims: jwt.StandardClaims{ExpiresAt: expires, Subject: "7"},
+				RealmID:        3,
+				SessionID:      1,
+			}),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:  "api key that can view reports",
+			token: APIKeyPrefix + "key",
+			store: mockAuthStore{key: store.APIKey{
+				RealmID:            3,
+				CreatorID:          7,
+				Scopes:             store.Permissions{store.PermissionViewReports},
+				CreatorPermissions: store.Permissions{store.PermissionViewReports},
+			}},
+			expectedStatusCode: http.StatusOK,
+			expectedSubject:    7,
+			expectedRealmID:    3,
+		},
+		{
+			name:  "api key without scopes",
+			token: APIKeyPrefix + "key",
+			store: mockAuthStore{key: store.APIKey{
+				RealmID:            3,
+				CreatorID:          7,
+				Scopes:             store.Permissions{},
+				CreatorPermissions: store.Permissions{store.PermissionViewReports},
+			}},
+			expectedStatusCode: http.StatusOK,
+			expectedSubject:    7,
+		},
+		{
+			name:  "api key whose creator can't view reports",
+			token: APIKeyPrefix + "key",
+			store: mockAuthStore{key: store.APIKey{
+				RealmID:   3,
+				CreatorID: 7,
+				Scopes:    store.Permissions{store.PermissionViewReports},
+			}},
+			expectedStatusCode: http.StatusOK,
+			expectedSubject:    7,
+		},
+		{
+			name:               "revoked api key",
+			token:              APIKeyPrefix + "key",
+			store:              mockAuthStore{keyErr: store.ErrNoResults{}},
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "access token without session",
+			token: sign(&Claims{
+				StandardClaims: jwt.StandardClaims{ExpiresAt: expires, Subject: "7"},
+				Type:           AccessTokenType,
+				RealmID:        3,
+			}),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject, realmID int64
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				subject, _ = GetSubject(r)
+				realmID, _ = GetRealmID(r)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			rr := httptest.NewRecorder()
+			Auth(next, keys.Keyfunc, tt.store, tt.store).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedStatusCode {
+				t.Errorf("expected status code %d but got %d", tt.expectedStatusCode, rr.Code)
+			}
+
+			if subject != tt.expectedSubject {
+				t.Errorf("expected subject %d but got %d", tt.expectedSubject, subject)
+			}
+
+			if realmID != tt.expectedRealmID {
+				t.Errorf("expected realm %d but got %d", tt.expectedRealmID, realmID)
+			}
+		})
+	}
+}
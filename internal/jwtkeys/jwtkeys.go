@@ -0,0 +1,223 @@
+// Package jwtkeys signs and verifies peregrine's JWTs with a set of keys that
+// can be rotated without invalidating tokens signed with older keys. Public keys
+// are published as a JWK set so other services can verify tokens.
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Key signs and verifies tokens. The newest key whose SignFrom has passed signs
+// new tokens, and tokens with its ID verify until RetireAt, or forever if it is
+// zero. Keys with an empty ID verify tokens without a key ID.
+type Key struct {
+	ID       string
+	Method   jwt.SigningMethod
+	Private  interface{}
+	Public   interface{}
+	SignFrom time.Time
+	RetireAt time.Time
+}
+
+// Set is a set of keys on a rotation schedule.
+type Set struct {
+	keys []Key
+
+	// Now returns the current time for choosing keys, time.Now if nil.
+	Now func() time.Time
+}
+
+// New returns a set of keys. There must be at least one key, and key IDs must
+// be unique.
+func New(keys ...Key) (*Set, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+
+	ids := make(map[string]bool)
+	for _, k := range keys {
+		if ids[k.ID] {
+			return nil, fmt.Errorf("duplicate key ID %q", k.ID)
+		}
+		ids[k.ID] = true
+	}
+
+	sorted := append([]Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SignFrom.Before(sorted[j].SignFrom)
+	})
+
+	return &Set{keys: sorted}, nil
+}
+
+// HMACKey returns a key that signs tokens with HS256 and a shared secret. It
+// has no ID, so it verifies tokens signed before keys had IDs.
+func HMACKey(secret string) Key {
+	return Key{Method: jwt.SigningMethodHS256, Private: []byte(secret), Public: []byte(secret)}
+}
+
+// NewHMAC returns a set with only an HMAC key for the secret.
+func NewHMAC(secret string) *Set {
+	return &Set{keys: []Key{HMACKey(secret)}}
+}
+
+// ParsePrivateKey parses a PEM encoded RSA or Ed25519 private key, in PKCS #8
+// or (for RSA) PKCS #1 form, into a key with the given ID. RSA keys sign with
+// RS256 and Ed25519 keys sign with EdDSA.
+func ParsePrivateKey(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM data found")
+	}
+
+	var private interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("unable to parse private key: %w", err)
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		return Key{ID: id, Method: jwt.SigningMethodRS256, Private: private, Public: &private.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return Key{ID: id, Method: SigningMethodEdDSA, Private: private, Public: private.Public()}, nil
+	default:
+		return Key{}, fmt.Errorf("unsupported private key type %T", private)
+	}
+}
+
+func (s *Set) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+
+	return time.Now()
+}
+
+func (k Key) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && !now.Before(k.RetireAt)
+}
+
+// signingKey returns the newest key whose SignFrom has passed.
+func (s *Set) signingKey() (Key, error) {
+	now := s.now()
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		k := s.keys[i]
+		if !k.SignFrom.After(now) && !k.retired(now) {
+			return k, nil
+		}
+	}
+
+	return Key{}, errors.New("no key can sign tokens")
+}
+
+// Sign signs a token with the current signing key.
+func (s *Set) Sign(claims jwt.Claims) (string, error) {
+	k, err := s.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(k.Method, claims)
+	if k.ID != "" {
+		token.Header["kid"] = k.ID
+	}
+
+	return token.SignedString(k.Private)
+}
+
+// Keyfunc returns the key to verify a token with, for jwt.Parse. The token's
+// algorithm must match its key, so tokens signed with a public key as an HMAC
+// secret are rejected.
+func (s *Set) Keyfunc(token *jwt.Token) (interface{}, error) {
+	id, _ := token.Header["kid"].(string)
+
+	now := s.now()
+	for _, k := range s.keys {
+		if k.ID != id {
+			continue
+		}
+
+		if k.retired(now) {
+			return nil, fmt.Errorf("key %q is retired", id)
+		}
+
+		if token.Method.Alg() != k.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return k.Public, nil
+	}
+
+	return nil, fmt.Errorf("unknown key %q", id)
+}
+
+// JWK is a public key in JSON Web Key form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet is a set of public keys in JSON Web Key form.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that haven't retired, including keys that will
+// sign tokens in the future so verifiers know about them in advance. HMAC keys
+// are secret, so they aren't included.
+func (s *Set) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	now := s.now()
+	for _, k := range s.keys {
+		if k.retired(now) {
+			continue
+		}
+
+		switch public := k.Public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     k.ID,
+				Use:       "sig",
+				Algorithm: k.Method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     k.ID,
+				Use:       "sig",
+				Algorithm: k.Method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,274 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+func rsaKey(t *testing.T, id string) Key {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate rsa key: %v", err)
+	}
+
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)})
+	k, err := ParsePrivateKey(id, b)
+	if err != nil {
+		t.Fatalf("unable to parse rsa key: %v", err)
+	}
+
+	return k
+}
+
+func ed25519Key(t *testing.T, id string) Key {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate ed25519 key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("unable to marshal ed25519 key: %v", err)
+	}
+
+	k, err := ParsePrivateKey(id, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("unable to parse ed25519 key: %v", err)
+	}
+
+	return k
+}
+
+func parse(s *Set, token string) (*jwt.StandardClaims, error) {
+	claims := &jwt.StandardClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, s.Keyfunc)
+	return claims, err
+}
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1558050528, 0)
+
+	testCases := []struct {
+		name        string
+		key         Key
+		expectedAlg string
+	}{
+		{name: "hmac", key: HMACKey("i-am-secret"), expectedAlg: "HS256"},
+		{name: "rsa", key: rsaKey(t, "rsa"), expectedAlg: "RS256"},
+		{name: "ed25519", key: ed25519Key(t, "ed25519"), expectedAlg: "EdDSA"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(tt.key)
+			if err != nil {
+				t.Fatalf("did not expect error but got: %v", err)
+			}
+			s.Now = func() time.Time { return now }
+
+			token, err := s.Sign(&jwt.StandardClaims{Subject: "14"})
+			if err != nil {
+				t.Fatalf("did not expect error signing but got: %v", err)
+			}
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(token, &jwt.StandardClaims{})
+			if err != nil {
+				t.Fatalf("did not expect error parsing but got: %v", err)
+			}
+
+			if alg := parsed.Header["alg"]; alg != tt.expectedAlg {
+				t.Errorf("expected alg %q but got %v", tt.expectedAlg, alg)
+			}
+
+			if kid, _ := parsed.Header["kid"].(string); kid != tt.key.ID {
+				t.Errorf("expected kid %q but got %q", tt.key.ID, kid)
+			}
+
+			claims, err := parse(s, token)
+			if err != nil {
+				t.Fatalf("did not expect error verifying but got: %v", err)
+			}
+
+			if claims.Subject != "14" {
+				t.Errorf("expected subject 14 but got %q", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	now := time.Unix(1558050528, 0)
+
+	old := ed25519Key(t, "old")
+	old.RetireAt = now.Add(time.Hour * 24 * 30)
+
+	current := rsaKey(t, "current")
+	current.SignFrom = now.Add(-time.Hour)
+
+	next := ed25519Key(t, "next")
+	next.SignFrom = now.Add(time.Hour)
+
+	s, err := New(next, old, current)
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+	s.Now = func() time.Time { return now }
+
+	oldToken, err := (&Set{keys: []Key{old}, Now: s.Now}).Sign(&jwt.StandardClaims{})
+	if err != nil {
+		t.Fatalf("did not expect error signing but got: %v", err)
+	}
+
+	token, err := s.Sign(&jwt.StandardClaims{})
+	if err != nil {
+		t.Fatalf("did not expect error signing but got: %v", err)
+	}
+
+	parsed, _, _ := new(jwt.Parser).ParseUnverified(token, &jwt.StandardClaims{})
+	if kid := parsed.Header["kid"]; kid != "current" {
+		t.Errorf("expected newest key that can sign to sign but got %v", kid)
+	}
+
+	if _, err := parse(s, oldToken); err != nil {
+		t.Errorf("did not expect error verifying token from older key but got: %v", err)
+	}
+
+	s.Now = func() time.Time { return now.Add(time.Hour * 24 * 30) }
+
+	token, err = s.Sign(&jwt.StandardClaims{})
+	if err != nil {
+		t.Fatalf("did not expect error signing but got: %v", err)
+	}
+
+	parsed, _, _ = new(jwt.Parser).ParseUnverified(token, &jwt.StandardClaims{})
+	if kid := parsed.Header["kid"]; kid != "next" {
+		t.Errorf("expected next key to sign after its sign from time but got %v", kid)
+	}
+
+	if _, err := parse(s, oldToken); err == nil {
+		t.Errorf("expected error verifying token from retired key")
+	}
+
+	expectedKIDs := []string{"current", "next"}
+	var kids []string
+	for _, k := range s.JWKS().Keys {
+		kids = append(kids, k.KeyID)
+	}
+	if !cmp.Equal(expectedKIDs, kids) {
+		t.Errorf("expected jwks to have unretired keys, but got diff: %s", cmp.Diff(expectedKIDs, kids))
+	}
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	k := rsaKey(t, "rsa")
+
+	s, err := New(k, HMACKey("i-am-secret"))
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	// sign with the public key as an HMAC secret, claiming to be the RSA key
+	der := x509.MarshalPKCS1PublicKey(k.Public.(*rsa.PublicKey))
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{})
+	token.Header["kid"] = "rsa"
+	forged, err := token.SignedString(der)
+	if err != nil {
+		t.Fatalf("unable to sign token: %v", err)
+	}
+
+	if _, err := parse(s, forged); err == nil {
+		t.Errorf("expected error verifying token with mismatched algorithm")
+	}
+
+	if _, err := parse(s, "eyJhbGciOiJIUzI1NiIsImtpZCI6Im1pc3NpbmciLCJ0eXAiOiJKV1QifQ.e30.c2ln"); err == nil {
+		t.Errorf("expected error verifying token with unknown key")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaK := rsaKey(t, "rsa")
+	edK := ed25519Key(t, "ed25519")
+
+	s, err := New(HMACKey("i-am-secret"), rsaK, edK)
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	set := s.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("expected 2 public keys but got %d", len(set.Keys))
+	}
+
+	for _, k := range set.Keys {
+		switch k.KeyID {
+		case "rsa":
+			if k.KeyType != "RSA" || k.Algorithm != "RS256" || k.N == "" || k.E != "AQAB" {
+				t.Errorf("unexpected rsa jwk: %+v", k)
+			}
+		case "ed25519":
+			if k.KeyType != "OKP" || k.Algorithm != "EdDSA" || k.Curve != "Ed25519" || k.X == "" {
+				t.Errorf("unexpected ed25519 jwk: %+v", k)
+			}
+		default:
+			t.Errorf("unexpected jwk %q", k.KeyID)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name        string
+		keys        []Key
+		expectError bool
+	}{
+		{name: "hmac", keys: []Key{HMACKey("a")}},
+		{name: "no keys", expectError: true},
+		{name: "duplicate key ID", keys: []Key{HMACKey("a"), HMACKey("b")}, expectError: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.keys...)
+			if err != nil && !tt.expectError {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if err == nil && tt.expectError {
+				t.Errorf("expected error but didn't get one")
+			}
+		})
+	}
+}
+
+func TestRetiredHMACKey(t *testing.T) {
+	now := time.Unix(1558050528, 0)
+
+	hmacKey := HMACKey("i-am-secret")
+	hmacKey.RetireAt = now.Add(time.Hour * 24 * 28)
+
+	s, err := New(hmacKey, ed25519Key(t, "ed25519"))
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	token, err := (&Set{keys: []Key{hmacKey}, Now: func() time.Time { return now }}).Sign(&jwt.StandardClaims{})
+	if err != nil {
+		t.Fatalf("did not expect error signing but got: %v", err)
+	}
+
+	s.Now = func() time.Time { return now }
+	if _, err := parse(s, token); err != nil {
+		t.Errorf("did not expect error verifying token before secret retired but got: %v", err)
+	}
+
+	s.Now = func() time.Time { return hmacKey.RetireAt }
+	if _, err := parse(s, token); err == nil {
+		t.Errorf("expected error verifying token without a key ID after secret retired")
+	}
+}
@@ -0,0 +1,147 @@
+// Package lockout locks out usernames and IP addresses after repeated failed
+// logins, with lockouts that get exponentially longer the more logins fail.
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Store stores failed logins and lockouts by key. Stores shared by multiple
+// servers let them agree on who is locked out.
+type Store interface {
+	// GetLoginLockout returns when the lockout of a key ends, or the zero time if
+	// it was never locked out.
+	GetLoginLockout(ctx context.Context, key string) (time.Time, error)
+	// RecordLoginFailure records a failed login for a key at now, forgetting
+	// failures if the last one was before since, and returns the number of
+	// failures.
+	RecordLoginFailure(ctx context.Context, key string, now, since time.Time) (int, error)
+	// LockLogin locks out a key until the given time.
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	// ResetLoginFailures forgets the failed logins and lockout of a key.
+	ResetLoginFailures(ctx context.Context, key string) error
+}
+
+// Policy is how many logins can fail before a key is locked out, and for how
+// long. Each failure after the first lockout doubles its length, up to
+// MaxLockout. Failures are forgotten after ResetAfter without any.
+type Policy struct {
+	MaxFailures int
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	ResetAfter  time.Duration
+}
+
+// Lockout returns how long a key is locked out for after the given number of
+// failed logins.
+func (p Policy) Lockout(failures int) time.Duration {
+	if failures < p.MaxFailures {
+		return 0
+	}
+
+	lockout := p.BaseLockout
+	for i := p.MaxFailures; i < failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+
+	if lockout > p.MaxLockout {
+		return p.MaxLockout
+	}
+
+	return lockout
+}
+
+// Default policies. Many scouts at an event often share an IP address, so
+// addresses can fail more logins than usernames.
+var (
+	DefaultUsernamePolicy = Policy{
+		MaxFailures: 5,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		ResetAfter:  time.Hour * 24,
+	}
+	DefaultIPPolicy = Policy{
+		MaxFailures: 50,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		ResetAfter:  time.Hour * 24,
+	}
+)
+
+// Limiter locks out usernames and IP addresses after repeated failed logins.
+type Limiter struct {
+	Store    Store
+	Username Policy
+	IP       Policy
+}
+
+func usernameKey(username string) string {
+	return "username:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check returns when the lockout of a username or IP address ends, or the zero
+// time if neither is locked out at now.
+func (l *Limiter) Check(ctx context.Context, username, ip string, now time.Time) (time.Time, error) {
+	var lockedUntil time.Time
+
+	for _, key := range []string{usernameKey(username), ipKey(ip)} {
+		until, err := l.Store.GetLoginLockout(ctx, key)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to get lockout of %q: %w", key, err)
+		}
+
+		if until.After(now) && until.After(lockedUntil) {
+			lockedUntil = until
+		}
+	}
+
+	return lockedUntil, nil
+}
+
+// Fail records a failed login for a username from an IP address, and returns
+// when the resulting lockout ends, or the zero time if neither is locked out.
+func (l *Limiter) Fail(ctx context.Context, username, ip string, now time.Time) (time.Time, error) {
+	var lockedUntil time.Time
+
+	for key, policy := range map[string]Policy{usernameKey(username): l.Username, ipKey(ip): l.IP} {
+		failures, err := l.Store.RecordLoginFailure(ctx, key, now, now.Add(-policy.ResetAfter))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to record failed login for %q: %w", key, err)
+		}
+
+		lockout := policy.Lockout(failures)
+		if lockout == 0 {
+			continue
+		}
+
+		until := now.Add(lockout)
+		if err := l.Store.LockLogin(ctx, key, until); err != nil {
+			return time.Time{}, fmt.Errorf("unable to lock out %q: %w", key, err)
+		}
+
+		if until.After(lockedUntil) {
+			lockedUntil = until
+		}
+	}
+
+	return lockedUntil, nil
+}
+
+// Unlock forgets the failed logins and lockout of a username, after it logs in
+// successfully or is unlocked by an admin. Failures from IP addresses aren't
+// forgotten, so logging in to one account doesn't allow guessing the passwords
+// of others.
+func (l *Limiter) Unlock(ctx context.Context, username string) error {
+	if err := l.Store.ResetLoginFailures(ctx, usernameKey(username)); err != nil {
+		return fmt.Errorf("unable to unlock username: %w", err)
+	}
+
+	return nil
+}
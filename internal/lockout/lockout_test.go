@@ -0,0 +1,140 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPolicyLockout(t *testing.T) {
+	p := Policy{MaxFailures: 3, BaseLockout: time.Minute, MaxLockout: time.Minute * 5}
+
+	testCases := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 0, expected: 0},
+		{failures: 2, expected: 0},
+		{failures: 3, expected: time.Minute},
+		{failures: 4, expected: time.Minute * 2},
+		{failures: 5, expected: time.Minute * 4},
+		{failures: 6, expected: time.Minute * 5},
+		{failures: 1000, expected: time.Minute * 5},
+	}
+
+	for _, tt := range testCases {
+		if got := p.Lockout(tt.failures); got != tt.expected {
+			t.Errorf("expected %d failures to lock out for %v but got %v", tt.failures, tt.expected, got)
+		}
+	}
+}
+
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if until, err := s.GetLoginLockout(ctx, "missing"); err != nil {
+		t.Fatalf("did not expect error getting lockout but got: %v", err)
+	} else if !until.IsZero() {
+		t.Errorf("expected missing key not to be locked out but got %v", until)
+	}
+
+	for i := 1; i <= 3; i++ {
+		failures, err := s.RecordLoginFailure(ctx, "key", now, now.Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("did not expect error recording failure but got: %v", err)
+		}
+		if failures != i {
+			t.Errorf("expected %d failures but got %d", i, failures)
+		}
+	}
+
+	failures, err := s.RecordLoginFailure(ctx, "key", now.Add(time.Hour*2), now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("did not expect error recording failure but got: %v", err)
+	}
+	if failures != 1 {
+		t.Errorf("expected old failures to be forgotten but got %d failures", failures)
+	}
+
+	until := now.Add(time.Minute).Truncate(time.Second)
+	if err := s.LockLogin(ctx, "key", until); err != nil {
+		t.Fatalf("did not expect error locking login but got: %v", err)
+	}
+
+	if got, err := s.GetLoginLockout(ctx, "key"); err != nil {
+		t.Fatalf("did not expect error getting lockout but got: %v", err)
+	} else if !got.Equal(until) {
+		t.Errorf("expected lockout until %v but got %v", until, got)
+	}
+
+	if err := s.ResetLoginFailures(ctx, "key"); err != nil {
+		t.Fatalf("did not expect error resetting failures but got: %v", err)
+	}
+
+	if got, err := s.GetLoginLockout(ctx, "key"); err != nil {
+		t.Fatalf("did not expect error getting lockout but got: %v", err)
+	} else if !got.IsZero() {
+		t.Errorf("expected reset key not to be locked out but got %v", got)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, &Memory{})
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := &Limiter{
+		Store:    &Memory{},
+		Username: Policy{MaxFailures: 2, BaseLockout: time.Minute, MaxLockout: time.Hour, ResetAfter: time.Hour},
+		IP:       Policy{MaxFailures: 3, BaseLockout: time.Minute, MaxLockout: time.Hour, ResetAfter: time.Hour},
+	}
+
+	if until, err := l.Fail(ctx, "Franklin", "10.0.0.1", now); err != nil {
+		t.Fatalf("did not expect error failing login but got: %v", err)
+	} else if !until.IsZero() {
+		t.Errorf("did not expect first failure to lock out but got %v", until)
+	}
+
+	if until, err := l.Fail(ctx, "franklin", "10.0.0.1", now); err != nil {
+		t.Fatalf("did not expect error failing login but got: %v", err)
+	} else if !until.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected second failure to lock out username until %v but got %v", now.Add(time.Minute), until)
+	}
+
+	if until, err := l.Check(ctx, "FRANKLIN", "10.0.0.2", now); err != nil {
+		t.Fatalf("did not expect error checking login but got: %v", err)
+	} else if until.IsZero() {
+		t.Errorf("expected username to be locked out regardless of case")
+	}
+
+	if until, err := l.Check(ctx, "franklin", "10.0.0.1", now.Add(time.Minute)); err != nil {
+		t.Fatalf("did not expect error checking login but got: %v", err)
+	} else if !until.IsZero() {
+		t.Errorf("expected lockout to have ended but got %v", until)
+	}
+
+	if until, err := l.Fail(ctx, "marie", "10.0.0.1", now); err != nil {
+		t.Fatalf("did not expect error failing login but got: %v", err)
+	} else if !until.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected third failure to lock out ip until %v but got %v", now.Add(time.Minute), until)
+	}
+
+	if until, err := l.Check(ctx, "ada", "10.0.0.1", now); err != nil {
+		t.Fatalf("did not expect error checking login but got: %v", err)
+	} else if until.IsZero() {
+		t.Errorf("expected ip to be locked out for other usernames")
+	}
+
+	if err := l.Unlock(ctx, "franklin"); err != nil {
+		t.Fatalf("did not expect error unlocking but got: %v", err)
+	}
+
+	if until, err := l.Check(ctx, "franklin", "10.0.0.2", now); err != nil {
+		t.Fatalf("did not expect error checking login but got: %v", err)
+	} else if !until.IsZero() {
+		t.Errorf("expected unlocked username not to be locked out but got %v", until)
+	}
+}
@@ -0,0 +1,106 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often a Memory store forgets keys whose failures and
+// lockouts have expired.
+const pruneInterval = time.Minute
+
+type memoryEntry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Memory stores failed logins in memory, so servers don't share them. The zero
+// value is ready to use.
+type Memory struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastPrune time.Time
+}
+
+// GetLoginLockout returns when the lockout of a key ends.
+func (m *Memory) GetLoginLockout(ctx context.Context, key string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		return e.lockedUntil, nil
+	}
+
+	return time.Time{}, nil
+}
+
+// RecordLoginFailure records a failed login for a key and returns the number of
+// failures since the last one before since.
+func (m *Memory) RecordLoginFailure(ctx context.Context, key string, now, since time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = make(map[string]*memoryEntry)
+	}
+
+	if now.Sub(m.lastPrune) > pruneInterval {
+		m.prune(now, since)
+	}
+
+	e, ok := m.entries[key]
+	if !ok {
+		e = &memoryEntry{}
+		m.entries[key] = e
+	} else if e.lastFailure.Before(since) {
+		e.failures = 0
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	return e.failures, nil
+}
+
+// prune forgets keys that last failed before since and aren't locked out.
+func (m *Memory) prune(now, since time.Time) {
+	for key, e := range m.entries {
+		if e.lastFailure.Before(since) && !e.lockedUntil.After(now) {
+			delete(m.entries, key)
+		}
+	}
+
+	m.lastPrune = now
+}
+
+// LockLogin locks out a key until the given time.
+func (m *Memory) LockLogin(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = make(map[string]*memoryEntry)
+	}
+
+	e, ok := m.entries[key]
+	if !ok {
+		e = &memoryEntry{}
+		m.entries[key] = e
+	}
+
+	e.lockedUntil = until
+
+	return nil
+}
+
+// ResetLoginFailures forgets the failed logins and lockout of a key.
+func (m *Memory) ResetLoginFailures(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package notify delivers messages to users outside of peregrine, such as
+// password reset tokens.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// PasswordReset is a one-time token that lets a user set a new password.
+type PasswordReset struct {
+	UserID    int64     `json:"userId"`
+	Username  string    `json:"username"`
+	FirstName string    `json:"firstName"`
+	LastName  string    `json:"lastName"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	PasswordReset(ctx context.Context, reset PasswordReset) error
+}
+
+// SignatureHeader is the header of webhook requests holding the hex encoded
+// HMAC-SHA256 of the body, keyed with the webhook secret.
+const SignatureHeader = "X-Peregrine-Signature"
+
+// Webhook delivers messages by posting them as JSON to a URL, such as a service
+// that emails or messages users. Receivers should check the signature header
+// so others can't send them messages.
+type Webhook struct {
+	URL    string
+	Secret string
+
+	// Client is used to make requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+type webhookMessage struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// Sign returns the signature of a webhook body.
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (wh Webhook) post(ctx context.Context, messageType string, data interface{}) error {
+	body, err := json.Marshal(webhookMessage{Type: messageType, Data: data})
+	if err != nil {
+		return fmt.Errorf("unable to marshal message: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(body, wh.Secret))
+
+	client := http.DefaultClient
+	if wh.Client != nil {
+		client = wh.Client
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got unexpected status code posting %s message: %d", messageType, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// PasswordReset posts a password reset message to the webhook.
+func (wh Webhook) PasswordReset(ctx context.Context, reset PasswordReset) error {
+	return wh.post(ctx, "passwordReset", reset)
+}
@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWebhookPasswordReset(t *testing.T) {
+	reset := PasswordReset{
+		UserID:    14,
+		Username:  "franklin",
+		FirstName: "Ben",
+		LastName:  "Franklin",
+		Token:     "the-token",
+		ExpiresAt: time.Unix(1558050528, 0).UTC(),
+	}
+
+	var got struct {
+		Type string        `json:"type"`
+		Data PasswordReset `json:"data"`
+	}
+	var signature, expectedSignature string
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unable to read body: %v", err)
+		}
+
+		signature = r.Header.Get(SignatureHeader)
+		expectedSignature = Sign(body, "i-am-secret")
+
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("unable to unmarshal body: %v", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	wh := Webhook{URL: s.URL, Secret: "i-am-secret"}
+	if err := wh.PasswordReset(context.Background(), reset); err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	if got.Type != "passwordReset" {
+		t.Errorf("expected message type passwordReset but got %q", got.Type)
+	}
+
+	if !cmp.Equal(reset, got.Data) {
+		t.Errorf("expected posted reset to match reset, but got diff: %s", cmp.Diff(reset, got.Data))
+	}
+
+	if signature != expectedSignature {
+		t.Errorf("expected signature %q but got %q", expectedSignature, signature)
+	}
+}
+
+func TestWebhookError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer s.Close()
+
+	wh := Webhook{URL: s.URL, Secret: "i-am-secret"}
+	if err := wh.PasswordReset(context.Background(), PasswordReset{}); err == nil {
+		t.Errorf("expected error but didn't get one")
+	}
+}
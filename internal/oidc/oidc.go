@@ -0,0 +1,347 @@
+// Package oidc logs users in with an OpenID Connect provider, such as Google,
+// using the authorization code flow.
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ErrInvalid is returned when an authorization code is rejected by the
+// provider, or the ID token it is exchanged for is invalid.
+var ErrInvalid = errors.New("invalid authorization code or id token")
+
+// Identity is a user of an OpenID Connect provider. Subject uniquely identifies
+// the user for the issuer.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// Provider is an OpenID Connect provider. Its endpoints and keys are discovered
+// from the issuer the first time they are needed.
+type Provider struct {
+	// Issuer is the URL of the provider, e.g. https://accounts.google.com.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to after they log in.
+	RedirectURL string
+
+	// Client is used to make requests, http.DefaultClient if nil.
+	Client *http.Client
+	// Now returns the current time for validating ID tokens, time.Now if nil.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]*rsa.PublicKey
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func (p *Provider) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+
+	return http.DefaultClient
+}
+
+func (p *Provider) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+
+	return time.Now()
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("got unexpected status code: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("unable to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// discover returns the provider's endpoints, fetching them the first time.
+func (p *Provider) discover(ctx context.Context) (discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return *p.discovery, nil
+	}
+
+	var d discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return discovery{}, fmt.Errorf("unable to get openid configuration: %w", err)
+	}
+
+	if d.Issuer != p.Issuer {
+		return discovery{}, fmt.Errorf("openid configuration is for issuer %q, not %q", d.Issuer, p.Issuer)
+	}
+
+	p.discovery = &d
+	return d, nil
+}
+
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+// key returns the provider's public key with the given ID. Providers rotate
+// their keys, so they are fetched again if the key isn't known.
+func (p *Provider) key(ctx context.Context, id string) (*rsa.PublicKey, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("unable to get keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.KeyType != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode modulus of key %q: %w", k.KeyID, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode exponent of key %q: %w", k.KeyID, err)
+		}
+
+		keys[k.KeyID] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = keys
+
+	key, ok := keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalid, id)
+	}
+
+	return key, nil
+}
+
+// AuthURL returns the URL to send users to so they can log in with the
+// provider. The provider sends them back to the redirect URL with the state and
+// an authorization code, and the nonce is included in their ID token.
+func (p *Provider) AuthURL(ctx context.Context, state, nonce string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse authorization endpoint: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", "openid email profile")
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// audience is the aud claim of an ID token, which can be a string or an array
+// of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+
+	*a = ss
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+type idTokenClaims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	ExpiresAt     int64    `json:"exp"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	GivenName     string   `json:"given_name"`
+	FamilyName    string   `json:"family_name"`
+}
+
+// Valid is checked by Exchange instead, since it needs the provider.
+func (c *idTokenClaims) Valid() error {
+	return nil
+}
+
+// Exchange exchanges an authorization code for the identity of the user who
+// logged in. The ID token's nonce must match the nonce given to AuthURL.
+// ErrInvalid is returned if the code or ID token is invalid.
+func (p *Provider) Exchange(ctx context.Context, code, nonce string) (Identity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+
+	req, err := http.NewRequest(http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Identity{}, fmt.Errorf("unable to create token request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("unable to do token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return Identity{}, fmt.Errorf("%w: token request got status code %d", ErrInvalid, resp.StatusCode)
+	} else if resp.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("token request got unexpected status code: %d", resp.StatusCode)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return Identity{}, fmt.Errorf("unable to decode token response: %w", err)
+	}
+
+	return p.verify(ctx, tokens.IDToken, nonce)
+}
+
+// verify checks the signature and claims of an ID token, and returns the
+// identity it is for.
+func (p *Provider) verify(ctx context.Context, idToken, nonce string) (Identity, error) {
+	var keyErr error
+	token, err := jwt.ParseWithClaims(idToken, &idTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %q", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		var key *rsa.PublicKey
+		key, keyErr = p.key(ctx, kid)
+		return key, keyErr
+	})
+	if keyErr != nil && !errors.Is(keyErr, ErrInvalid) {
+		return Identity{}, keyErr
+	} else if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	claims := token.Claims.(*idTokenClaims)
+
+	switch {
+	case claims.Issuer != p.Issuer:
+		return Identity{}, fmt.Errorf("%w: id token is for issuer %q", ErrInvalid, claims.Issuer)
+	case !claims.Audience.contains(p.ClientID):
+		return Identity{}, fmt.Errorf("%w: id token isn't for this client", ErrInvalid)
+	case p.now().Unix() >= claims.ExpiresAt:
+		return Identity{}, fmt.Errorf("%w: id token is expired", ErrInvalid)
+	case claims.Nonce != nonce:
+		return Identity{}, fmt.Errorf("%w: id token nonce doesn't match", ErrInvalid)
+	case claims.Subject == "":
+		return Identity{}, fmt.Errorf("%w: id token has no subject", ErrInvalid)
+	}
+
+	return Identity{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		FirstName:     claims.GivenName,
+		LastName:      claims.FamilyName,
+	}, nil
+}
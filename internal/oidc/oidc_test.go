@@ -0,0 +1,287 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/google/go-cmp/cmp"
+)
+
+// standIn is a local stand-in for an OpenID Connect provider, which exchanges
+// the code "good-code" for an ID token with the given claims.
+type standIn struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newStandIn(t *testing.T) *standIn {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	s := &standIn{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 s.URL,
+			"authorization_endpoint": s.URL + "/authorize",
+			"token_endpoint":         s.URL + "/token",
+			"jwks_uri":               s.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "stand-in",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		if clientID != "peregrine" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.FormValue("code") != "good-code" || r.FormValue("redirect_uri") != "https://peregrine.test/callback" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, s.claims)
+		token.Header["kid"] = "stand-in"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func TestAuthURL(t *testing.T) {
+	s := newStandIn(t)
+	defer s.Close()
+
+	p := &Provider{Issuer: s.URL, ClientID: "peregrine", RedirectURL: "https://peregrine.test/callback"}
+
+	authURL, err := p.AuthURL(context.Background(), "the-state", "the-nonce")
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("unable to parse auth url: %v", err)
+	}
+
+	if got := u.Scheme + "://" + u.Host + u.Path; got != s.URL+"/authorize" {
+		t.Errorf("expected auth url to be the authorization endpoint but got %q", got)
+	}
+
+	expected := url.Values{
+		"response_type": {"code"},
+		"client_id":     {"peregrine"},
+		"redirect_uri":  {"https://peregrine.test/callback"},
+		"scope":         {"openid email profile"},
+		"state":         {"the-state"},
+		"nonce":         {"the-nonce"},
+	}
+	if !cmp.Equal(expected, u.Query()) {
+		t.Errorf("expected auth url query to match, but got diff: %s", cmp.Diff(expected, u.Query()))
+	}
+}
+
+func TestExchange(t *testing.T) {
+	s := newStandIn(t)
+	defer s.Close()
+
+	now := time.Unix(1558050528, 0)
+
+	testCases := []struct {
+		name             string
+		code             string
+		clientSecret     string
+		claims           jwt.MapClaims
+		expectedIdentity Identity
+		expectInvalid    bool
+	}{
+		{
+			name: "valid code",
+			code: "good-code",
+			claims: jwt.MapClaims{
+				"aud":            "peregrine",
+				"exp":            now.Add(time.Hour).Unix(),
+				"nonce":          "the-nonce",
+				"sub":            "1234",
+				"email":          "franklin@school.edu",
+				"email_verified": true,
+				"given_name":     "Ben",
+				"family_name":    "Franklin",
+			},
+			expectedIdentity: Identity{
+				Subject:       "1234",
+				Email:         "franklin@school.edu",
+				EmailVerified: true,
+				FirstName:     "Ben",
+				LastName:      "Franklin",
+			},
+		},
+		{
+			name: "audience array",
+			code: "good-code",
+			claims: jwt.MapClaims{
+				"aud":   []string{"other", "peregrine"},
+				"exp":   now.Add(time.Hour).Unix(),
+				"nonce": "the-nonce",
+				"sub":   "1234",
+			},
+			expectedIdentity: Identity{Subject: "1234"},
+		},
+		{
+			name:          "bad code",
+			code:          "bad-code",
+			expectInvalid: true,
+		},
+		{
+			name:          "bad client secret",
+			code:          "good-code",
+			clientSecret:  "wrong",
+			expectInvalid: true,
+		},
+		{
+			name: "wrong audience",
+			code: "good-code",
+			claims: jwt.MapClaims{
+				"aud":   "other",
+				"exp":   now.Add(time.Hour).Unix(),
+				"nonce": "the-nonce",
+				"sub":   "1234",
+			},
+			expectInvalid: true,
+		},
+		{
+			name: "expired",
+			code: "good-code",
+			claims: jwt.MapClaims{
+				"aud":   "peregrine",
+				"exp":   now.Add(-time.Minute).Unix(),
+				"nonce": "the-nonce",
+				"sub":   "1234",
+			},
+			expectInvalid: true,
+		},
+		{
+			name: "wrong nonce",
+			code: "good-code",
+			claims: jwt.MapClaims{
+				"aud":   "peregrine",
+				"exp":   now.Add(time.Hour).Unix(),
+				"nonce": "replayed",
+				"sub":   "1234",
+			},
+			expectInvalid: true,
+		},
+		{
+			name: "wrong issuer",
+			code: "good-code",
+			claims: jwt.MapClaims{
+				"iss":   "https://evil.test",
+				"aud":   "peregrine",
+				"exp":   now.Add(time.Hour).Unix(),
+				"nonce": "the-nonce",
+				"sub":   "1234",
+			},
+			expectInvalid: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			s.claims = jwt.MapClaims{"iss": s.URL}
+			for k, v := range tt.claims {
+				s.claims[k] = v
+			}
+
+			clientSecret := "secret"
+			if tt.clientSecret != "" {
+				clientSecret = tt.clientSecret
+			}
+
+			p := &Provider{
+				Issuer:       s.URL,
+				ClientID:     "peregrine",
+				ClientSecret: clientSecret,
+				RedirectURL:  "https://peregrine.test/callback",
+				Now:          func() time.Time { return now },
+			}
+
+			identity, err := p.Exchange(context.Background(), tt.code, "the-nonce")
+
+			if tt.expectInvalid {
+				if !errors.Is(err, ErrInvalid) {
+					t.Errorf("expected ErrInvalid but got: %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("did not expect error but got: %v", err)
+			}
+
+			tt.expectedIdentity.Issuer = s.URL
+			if !cmp.Equal(tt.expectedIdentity, identity) {
+				t.Errorf("expected identity to match expected identity, but got diff: %s", cmp.Diff(tt.expectedIdentity, identity))
+			}
+		})
+	}
+}
+
+func TestExchangeForgedToken(t *testing.T) {
+	s := newStandIn(t)
+	defer s.Close()
+
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.URL,
+		"aud":   "peregrine",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": "the-nonce",
+		"sub":   "1234",
+	})
+	token.Header["kid"] = "stand-in"
+	idToken, err := token.SignedString(forger)
+	if err != nil {
+		t.Fatalf("unable to sign token: %v", err)
+	}
+
+	p := &Provider{Issuer: s.URL, ClientID: "peregrine"}
+	if _, err := p.verify(context.Background(), idToken, "the-nonce"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid for forged token but got: %v", err)
+	}
+}
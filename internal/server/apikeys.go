@@ -0,0 +1,167 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// newAPIKey generates a new random API key and returns it along with the hash
+// that should be stored.
+func newAPIKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	key = ihttp.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, ihttp.HashAPIKey(key), nil
+}
+
+// validateAPIKeyScopes checks that every scope is a known permission. Keys are
+// scoped to a single realm, so they can't manage all realms.
+func validateAPIKeyScopes(scopes store.Permissions) error {
+	if err := validatePermissions(scopes); err != nil {
+		return err
+	}
+
+	for _, p := range scopes {
+		if p == store.PermissionManageAll {
+			return errors.New("api keys can't have the all:manage scope")
+		}
+	}
+
+	return nil
+}
+
+// getAPIKeysHandler returns a handler to get the API keys for the current realm.
+func (s *Server) getAPIKeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		keys, err := s.Store.GetAPIKeys(r.Context(), realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving api keys")
+			return
+		}
+
+		ihttp.Respond(w, keys, http.StatusOK)
+	}
+}
+
+// createAPIKeyHandler returns a handler to create an API key for the current
+// realm that acts as the current user. Users can't create keys with scopes
+// they don't have. The key is only returned once.
+func (s *Server) createAPIKeyHandler() http.HandlerFunc {
+	type createdAPIKey struct {
+		store.APIKey
+		Key string `json:"key"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var k store.APIKey
+		if err := json.NewDecoder(r.Body).Decode(&k); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validator.New().Struct(k); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validateAPIKeyScopes(k.Scopes); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if !canGrant(ihttp.GetPermissions(r), k.Scopes) {
+			ihttp.Respond(w, errors.New("api keys can't have scopes you don't have"), http.StatusForbidden)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		creatorID, err := ihttp.GetSubject(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusUnauthorized)
+			return
+		}
+
+		key, hash, err := newAPIKey()
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("generating api key")
+			return
+		}
+
+		k.RealmID = realmID
+		k.CreatorID = creatorID
+		k.KeyHash = hash
+		k.LastUsedAt = nil
+		k.RevokedAt = nil
+
+		if k.Scopes == nil {
+			k.Scopes = store.Permissions{}
+		}
+
+		k.ID, k.CreatedAt, err = s.Store.CreateAPIKey(r.Context(), k)
+		if errors.Is(err, store.ErrFKeyViolation{}) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("creating api key")
+			return
+		}
+
+		ihttp.Respond(w, createdAPIKey{APIKey: k, Key: key}, http.StatusCreated)
+	}
+}
+
+// revokeAPIKeyHandler returns a handler to revoke an API key for the current
+// realm.
+func (s *Server) revokeAPIKeyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.RevokeAPIKey(r.Context(), realmID, id)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("revoking api key")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+)
+
+func TestValidateAPIKeyScopes(t *testing.T) {
+	testCases := []struct {
+		name        string
+		scopes      store.Permissions
+		expectError bool
+	}{
+		{
+			name: "no scopes",
+		},
+		{
+			name:   "read stats and write reports",
+			scopes: store.Permissions{store.PermissionViewReports, store.PermissionSubmitReports},
+		},
+		{
+			name:        "unknown scope",
+			scopes:      store.Permissions{store.PermissionViewReports, "reports:frobnicate"},
+			expectError: true,
+		},
+		{
+			name:        "manage all",
+			scopes:      store.Permissions{store.PermissionManageAll},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAPIKeyScopes(tt.scopes)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+		})
+	}
+}
+
+func TestNewAPIKey(t *testing.T) {
+	key, hash, err := newAPIKey()
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	if !strings.HasPrefix(key, ihttp.APIKeyPrefix) {
+		t.Errorf("expected key %q to start with %q", key, ihttp.APIKeyPrefix)
+	}
+
+	if hash != ihttp.HashAPIKey(key) {
+		t.Errorf("expected hash to be the hash of the key")
+	}
+
+	other, _, err := newAPIKey()
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	if key == other {
+		t.Errorf("expected keys to be unique but got %q twice", key)
+	}
+}
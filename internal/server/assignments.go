@@ -0,0 +1,252 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/assignment"
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+)
+
+// defaultMaxConsecutiveMatches is how many matches in a row a scout is assigned
+// before being given a break, if another scout is available.
+const defaultMaxConsecutiveMatches = 4
+
+// getEventScoutsHandler returns a handler to get the scouts registered for an
+// event in the current realm.
+func (s *Server) getEventScoutsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventKey := mux.Vars(r)["eventKey"]
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		scouts, err := s.Store.GetEventScouts(r.Context(), eventKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event scouts")
+			return
+		}
+
+		ihttp.Respond(w, scouts, http.StatusOK)
+	}
+}
+
+// putEventScoutsHandler returns a handler to replace the scouts and shifts
+// registered for an event in the current realm.
+func (s *Server) putEventScoutsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventKey := mux.Vars(r)["eventKey"]
+
+		var scouts []store.EventScout
+		if err := json.NewDecoder(r.Body).Decode(&scouts); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		for _, scout := range scouts {
+			for _, shift := range scout.Shifts {
+				if !shift.End.After(shift.Start) {
+					ihttp.Respond(w, errors.New("shifts must end after they start"), http.StatusUnprocessableEntity)
+					return
+				}
+			}
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		if _, err := s.Store.GetEventForRealm(r.Context(), eventKey, &realmID); errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event")
+			return
+		}
+
+		err = s.Store.SetEventScouts(r.Context(), eventKey, realmID, scouts)
+		if errors.Is(err, store.ErrFKeyViolation{}) {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("setting event scouts")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type scoutAssignment struct {
+	MatchKey string     `json:"matchKey"`
+	TeamKey  string     `json:"teamKey"`
+	UserID   int64      `json:"userId"`
+	Time     *time.Time `json:"time,omitempty"`
+}
+
+type matchTeam struct {
+	MatchKey string `json:"matchKey"`
+	TeamKey  string `json:"teamKey"`
+}
+
+// generateAssignmentsHandler returns a handler that generates a scouting rotation
+// covering every robot in every unplayed qualification match at an event, using
+// the scouts registered for the event in the current realm. Assignments for
+// matches that have already been played are kept.
+func (s *Server) generateAssignmentsHandler() http.HandlerFunc {
+	type schedule struct {
+		Assignments []scoutAssignment `json:"assignments"`
+		Unassigned  []matchTeam       `json:"unassigned"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventKey := mux.Vars(r)["eventKey"]
+
+		maxConsecutive := defaultMaxConsecutiveMatches
+		if maxQuery := r.URL.Query().Get("maxConsecutive"); maxQuery != "" {
+			var err error
+			if maxConsecutive, err = strconv.Atoi(maxQuery); err != nil {
+				ihttp.Error(w, http.StatusBadRequest)
+				return
+			}
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		if _, err := s.Store.GetEventForRealm(r.Context(), eventKey, &realmID); errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event")
+			return
+		}
+
+		storeScouts, err := s.Store.GetEventScouts(r.Context(), eventKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event scouts")
+			return
+		}
+
+		storeMatches, err := s.Store.GetUnplayedQualificationMatches(r.Context(), eventKey)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event matches")
+			return
+		}
+
+		scouts := make([]assignment.Scout, len(storeScouts))
+		for i, scout := range storeScouts {
+			scouts[i].ID = scout.UserID
+			for _, shift := range scout.Shifts {
+				scouts[i].Shifts = append(scouts[i].Shifts, assignment.Shift{Start: shift.Start, End: shift.End})
+			}
+		}
+
+		matches := make([]assignment.Match, len(storeMatches))
+		matchKeys := make([]string, len(storeMatches))
+		matchTimes := make(map[string]*time.Time)
+		for i, match := range storeMatches {
+			matches[i] = assignment.Match{Key: match.Key, Time: match.Time, Teams: match.Teams}
+			matchKeys[i] = match.Key
+			matchTimes[match.Key] = match.Time
+		}
+
+		generated := assignment.Generate(matches, scouts, maxConsecutive)
+
+		storeAssignments := make([]store.ScoutAssignment, len(generated.Assignments))
+		sched := schedule{Assignments: make([]scoutAssignment, len(generated.Assignments)), Unassigned: make([]matchTeam, len(generated.Unassigned))}
+		for i, a := range generated.Assignments {
+			storeAssignments[i] = store.ScoutAssignment{MatchKey: a.MatchKey, TeamKey: a.TeamKey, UserID: a.ScoutID}
+			sched.Assignments[i] = scoutAssignment{
+				MatchKey: strings.TrimPrefix(a.MatchKey, eventKey+"_"),
+				TeamKey:  a.TeamKey,
+				UserID:   a.ScoutID,
+				Time:     matchTimes[a.MatchKey],
+			}
+		}
+		for i, slot := range generated.Unassigned {
+			sched.Unassigned[i] = matchTeam{MatchKey: strings.TrimPrefix(slot.MatchKey, eventKey+"_"), TeamKey: slot.TeamKey}
+		}
+
+		if err := s.Store.ReplaceScoutAssignments(r.Context(), eventKey, realmID, matchKeys, storeAssignments); err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("replacing scout assignments")
+			return
+		}
+
+		ihttp.Respond(w, sched, http.StatusOK)
+	}
+}
+
+// getAssignmentsHandler returns a handler to get the scout assignments for an
+// event in the current realm. If mine is true only the current users upcoming
+// assignments are returned.
+func (s *Server) getAssignmentsHandler(mine bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventKey := mux.Vars(r)["eventKey"]
+		upcoming, _ := strconv.ParseBool(r.URL.Query().Get("upcoming"))
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		var userID *int64
+		if mine {
+			subject, err := ihttp.GetSubject(r)
+			if err != nil {
+				ihttp.Error(w, http.StatusForbidden)
+				return
+			}
+			userID = &subject
+			upcoming = true
+		} else if userQuery := r.URL.Query().Get("user"); userQuery != "" {
+			id, err := strconv.ParseInt(userQuery, 10, 64)
+			if err != nil {
+				ihttp.Error(w, http.StatusBadRequest)
+				return
+			}
+			userID = &id
+		}
+
+		storeAssignments, err := s.Store.GetScoutAssignments(r.Context(), eventKey, realmID, userID, upcoming)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving scout assignments")
+			return
+		}
+
+		assignments := make([]scoutAssignment, len(storeAssignments))
+		for i, a := range storeAssignments {
+			assignments[i] = scoutAssignment{
+				MatchKey: strings.TrimPrefix(a.MatchKey, eventKey+"_"),
+				TeamKey:  a.TeamKey,
+				UserID:   a.UserID,
+				Time:     a.Time,
+			}
+		}
+
+		ihttp.Respond(w, assignments, http.StatusOK)
+	}
+}
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+)
+
+// calendarMatchDuration is how long each match is blocked out for in the
+// calendar, which includes some time for field reset.
+const calendarMatchDuration = 10 * time.Minute
+
+// newCalendarToken generates a new random calendar token and returns it along
+// with the hash that should be stored.
+func newCalendarToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashCalendarToken(token), nil
+}
+
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createCalendarTokenHandler returns a handler that generates a new calendar
+// token for a user in their current realm, replacing their old token. Calendar
+// tokens allow calendar apps to subscribe to event calendars without a JWT.
+func (s *Server) createCalendarTokenHandler() http.HandlerFunc {
+	type calendarToken struct {
+		Token string `json:"token"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		// only allow users to create calendar tokens for themselves
+		if subject, err := ihttp.GetSubject(r); err != nil || subject != id {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		// pending members don't have a realm in their JWT
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		token, hash, err := newCalendarToken()
+		if err != nil {
+			s.Logger.WithError(err).Error("generating calendar token")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = s.Store.SetCalendarToken(r.Context(), id, realmID, hash)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("setting calendar token")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		ihttp.Respond(w, calendarToken{Token: token}, http.StatusCreated)
+	}
+}
+
+// deleteCalendarTokenHandler returns a handler that revokes a users calendar token.
+func (s *Server) deleteCalendarTokenHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		// only allow users to delete their own calendar tokens
+		if subject, err := ihttp.GetSubject(r); err != nil || subject != id {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.DeleteCalendarToken(r.Context(), id)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("deleting calendar token")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// eventCalendarHandler returns a handler to get an iCalendar feed of the match
+// schedule for an event, optionally filtered to a single team. The realm is
+// taken from the calendar token if one is given, and otherwise from the JWT.
+func (s *Server) eventCalendarHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventKey := mux.Vars(r)["eventKey"]
+		teamKey := r.URL.Query().Get("team")
+
+		var realmID *int64
+		if token := r.URL.Query().Get("token"); token != "" {
+			tokenRealmID, err := s.Store.GetCalendarTokenRealmID(r.Context(), hashCalendarToken(token))
+			if errors.Is(err, store.ErrNoResults{}) {
+				ihttp.Error(w, http.StatusUnauthorized)
+				return
+			} else if err != nil {
+				ihttp.Error(w, http.StatusInternalServerError)
+				s.Logger.WithError(err).Error("retrieving calendar token realm")
+				return
+			}
+			realmID = &tokenRealmID
+		} else if userRealmID, err := ihttp.GetRealmID(r); err == nil {
+			realmID = &userRealmID
+		}
+
+		event, err := s.Store.GetEventForRealm(r.Context(), eventKey, realmID)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event")
+			return
+		}
+
+		var teamKeys []string
+		if teamKey != "" {
+			teamKeys = []string{teamKey}
+		}
+
+		matches, err := s.Store.GetMatchesForRealm(r.Context(), eventKey, teamKeys, false, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event matches")
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := writeCalendar(&buf, event, matches, teamKey, time.Now()); err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("writing event calendar")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", eventKey+".ics"))
+		w.WriteHeader(http.StatusOK)
+		_, _ = buf.WriteTo(w)
+	}
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// writeCalendar writes an iCalendar (RFC 5545) calendar with an event for each
+// match that has a time. If teamKey is set, the description of each match
+// includes the alliance color, partners, and opponents of that team.
+func writeCalendar(w io.Writer, event store.Event, matches []store.Match, teamKey string, now time.Time) error {
+	cw := &calendarWriter{w: w}
+
+	name := event.Name
+	if teamKey != "" {
+		name = fmt.Sprintf("%s (%s)", event.Name, strings.TrimPrefix(teamKey, "frc"))
+	}
+
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", "-//Pigmice2733//Peregrine//EN")
+	cw.line("CALSCALE", "GREGORIAN")
+	cw.line("METHOD", "PUBLISH")
+	cw.text("X-WR-CALNAME", name)
+	cw.line("REFRESH-INTERVAL;VALUE=DURATION", "PT15M")
+	cw.line("X-PUBLISHED-TTL", "PT15M")
+
+	for _, m := range matches {
+		start := m.PredictedTime
+		if start == nil {
+			start = m.ScheduledTime
+		}
+		if start == nil {
+			continue
+		}
+
+		summary := matchName(strings.TrimPrefix(m.Key, event.Key+"_"))
+		description := fmt.Sprintf("Red: %s\nBlue: %s", teamNumbers(m.RedAlliance), teamNumbers(m.BlueAlliance))
+
+		if teamKey != "" {
+			color, partners, opponents := "Red", m.RedAlliance, m.BlueAlliance
+			if contains(m.BlueAlliance, teamKey) {
+				color, partners, opponents = "Blue", m.BlueAlliance, m.RedAlliance
+			}
+
+			summary = fmt.Sprintf("%s (%s)", summary, color)
+			description = fmt.Sprintf("Alliance: %s\nPartners: %s\nOpponents: %s",
+				color, teamNumbers(without(partners, teamKey)), teamNumbers(opponents))
+		}
+
+		uid := m.Key
+		if teamKey != "" {
+			uid += "-" + teamKey
+		}
+
+		cw.line("BEGIN", "VEVENT")
+		cw.text("UID", uid+"@peregrine")
+		cw.line("DTSTAMP", now.UTC().Format(icalTimeFormat))
+		cw.line("DTSTART", start.UTC().Format(icalTimeFormat))
+		cw.line("DTEND", start.Add(calendarMatchDuration).UTC().Format(icalTimeFormat))
+		cw.text("SUMMARY", summary)
+		cw.text("DESCRIPTION", description)
+		cw.text("LOCATION", event.LocationName)
+		if m.TBAURL != nil {
+			cw.line("URL", *m.TBAURL)
+		}
+		cw.line("END", "VEVENT")
+	}
+
+	cw.line("END", "VCALENDAR")
+
+	return cw.err
+}
+
+// calendarWriter writes iCalendar content lines, folding them at 75 octets and
+// keeping the first error encountered.
+type calendarWriter struct {
+	w   io.Writer
+	err error
+}
+
+var icalTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// text writes a content line with a TEXT value, escaping it as required.
+func (cw *calendarWriter) text(name, value string) {
+	cw.line(name, icalTextEscaper.Replace(value))
+}
+
+func (cw *calendarWriter) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+
+	l := name + ":" + value
+
+	// continuation lines start with a space which counts towards their length
+	limit := 75
+	for len(l) > limit {
+		i := limit
+		for !utf8.RuneStart(l[i]) {
+			i--
+		}
+
+		if _, cw.err = io.WriteString(cw.w, l[:i]+"\r\n "); cw.err != nil {
+			return
+		}
+
+		l = l[i:]
+		limit = 74
+	}
+
+	_, cw.err = io.WriteString(cw.w, l+"\r\n")
+}
+
+var matchKeyRegexp = regexp.MustCompile(`^(qm|ef|qf|sf|f)(\d+)(?:m(\d+))?$`)
+
+var compLevelNames = map[string]string{
+	"qm": "Qualification",
+	"ef": "Octofinal",
+	"qf": "Quarterfinal",
+	"sf": "Semifinal",
+	"f":  "Final",
+}
+
+// matchName returns a human readable name for a match key without the event
+// key prefix, e.g. "Quarterfinal 2 Match 1" for "qf2m1".
+func matchName(key string) string {
+	parts := matchKeyRegexp.FindStringSubmatch(key)
+	if parts == nil {
+		return key
+	}
+
+	name := compLevelNames[parts[1]] + " " + parts[2]
+	if parts[3] != "" {
+		name += " Match " + parts[3]
+	}
+
+	return name
+}
+
+func teamNumbers(teamKeys []string) string {
+	numbers := make([]string, len(teamKeys))
+	for i, key := range teamKeys {
+		numbers[i] = strings.TrimPrefix(key, "frc")
+	}
+
+	return strings.Join(numbers, ", ")
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+func without(items []string, item string) []string {
+	var rest []string
+	for _, i := range items {
+		if i != item {
+			rest = append(rest, i)
+		}
+	}
+
+	return rest
+}
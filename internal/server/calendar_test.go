@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteCalendar(t *testing.T) {
+	now := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	scheduled := time.Date(2019, 3, 2, 17, 0, 0, 0, time.UTC)
+	predicted := time.Date(2019, 3, 2, 17, 4, 0, 0, time.UTC)
+	tbaURL := "https://www.thebluealliance.com/match/2019orwil_qf2m1"
+
+	event := store.Event{Key: "2019orwil", Name: "PNW District Wilsonville Event", LocationName: "Wilsonville High School"}
+	matches := []store.Match{
+		{
+			Key:           "2019orwil_qm1",
+			ScheduledTime: &scheduled,
+			RedAlliance:   []string{"frc2733", "frc1425", "frc2471"},
+			BlueAlliance:  []string{"frc254", "frc1678", "frc971"},
+		},
+		{
+			Key:           "2019orwil_qf2m1",
+			ScheduledTime: &scheduled,
+			PredictedTime: &predicted,
+			RedAlliance:   []string{"frc254", "frc1678", "frc971"},
+			BlueAlliance:  []string{"frc2733", "frc1425", "frc2471"},
+			TBAURL:        &tbaURL,
+		},
+		{
+			Key:          "2019orwil_f1m1",
+			RedAlliance:  []string{"frc2733", "frc1425", "frc2471"},
+			BlueAlliance: []string{"frc254", "frc1678", "frc971"},
+		},
+	}
+
+	testCases := []struct {
+		name             string
+		teamKey          string
+		expectedCalendar []string
+	}{
+		{
+			name: "all matches",
+			expectedCalendar: []string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:-//Pigmice2733//Peregrine//EN",
+				"CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH",
+				"X-WR-CALNAME:PNW District Wilsonville Event",
+				"REFRESH-INTERVAL;VALUE=DURATION:PT15M",
+				"X-PUBLISHED-TTL:PT15M",
+				"BEGIN:VEVENT",
+				"UID:2019orwil_qm1@peregrine",
+				"DTSTAMP:20190301T120000Z",
+				"DTSTART:20190302T170000Z",
+				"DTEND:20190302T171000Z",
+				"SUMMARY:Qualification 1",
+				`DESCRIPTION:Red: 2733\, 1425\, 2471\nBlue: 254\, 1678\, 971`,
+				"LOCATION:Wilsonville High School",
+				"END:VEVENT",
+				"BEGIN:VEVENT",
+				"UID:2019orwil_qf2m1@peregrine",
+				"DTSTAMP:20190301T120000Z",
+				"DTSTART:20190302T170400Z",
+				"DTEND:20190302T171400Z",
+				"SUMMARY:Quarterfinal 2 Match 1",
+				`DESCRIPTION:Red: 254\, 1678\, 971\nBlue: 2733\, 1425\, 2471`,
+				"LOCATION:Wilsonville High School",
+				"URL:https://www.thebluealliance.com/match/2019orwil_qf2m1",
+				"END:VEVENT",
+				"END:VCALENDAR",
+			},
+		},
+		{
+			name:    "team matches",
+			teamKey: "frc2733",
+			expectedCalendar: []string{
+				"BEGIN:VCALENDAR",
+				"VERSION:2.0",
+				"PRODID:-//Pigmice2733//Peregrine//EN",
+				"CALSCALE:GREGORIAN",
+				"METHOD:PUBLISH",
+				"X-WR-CALNAME:PNW District Wilsonville Event (2733)",
+				"REFRESH-INTERVAL;VALUE=DURATION:PT15M",
+				"X-PUBLISHED-TTL:PT15M",
+				"BEGIN:VEVENT",
+				"UID:2019orwil_qm1-frc2733@peregrine",
+				"DTSTAMP:20190301T120000Z",
+				"DTSTART:20190302T170000Z",
+				"DTEND:20190302T171000Z",
+				"SUMMARY:Qualification 1 (Red)",
+				`DESCRIPTION:Alliance: Red\nPartners: 1425\, 2471\nOpponents: 254\, 1678\, 9`,
+				` 71`,
+				"LOCATION:Wilsonville High School",
+				"END:VEVENT",
+				"BEGIN:VEVENT",
+				"UID:2019orwil_qf2m1-frc2733@peregrine",
+				"DTSTAMP:20190301T120000Z",
+				"DTSTART:20190302T170400Z",
+				"DTEND:20190302T171400Z",
+				"SUMMARY:Quarterfinal 2 Match 1 (Blue)",
+				`DESCRIPTION:Alliance: Blue\nPartners: 1425\, 2471\nOpponents: 254\, 1678\, `,
+				` 971`,
+				"LOCATION:Wilsonville High School",
+				"URL:https://www.thebluealliance.com/match/2019orwil_qf2m1",
+				"END:VEVENT",
+				"END:VCALENDAR",
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeCalendar(&buf, event, matches, tt.teamKey, now); err != nil {
+				t.Fatalf("did not expect error but got: %v", err)
+			}
+
+			calendar := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+			if !cmp.Equal(tt.expectedCalendar, calendar) {
+				t.Errorf("expected calendar to match expected calendar, but got diff: %s", cmp.Diff(tt.expectedCalendar, calendar))
+			}
+		})
+	}
+}
+
+func TestCalendarWriterFolding(t *testing.T) {
+	var buf bytes.Buffer
+	cw := &calendarWriter{w: &buf}
+	cw.text("SUMMARY", strings.Repeat("é", 100))
+
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("expected line %d to be at most 75 octets, got %d", i, len(line))
+		}
+	}
+
+	unfolded := strings.ReplaceAll(buf.String(), "\r\n ", "")
+	if expected := "SUMMARY:" + strings.Repeat("é", 100) + "\r\n"; unfolded != expected {
+		t.Errorf("expected unfolded line %q, got %q", expected, unfolded)
+	}
+}
+
+func TestMatchName(t *testing.T) {
+	testCases := map[string]string{
+		"qm12":  "Qualification 12",
+		"ef3m2": "Octofinal 3 Match 2",
+		"qf2m1": "Quarterfinal 2 Match 1",
+		"sf1m3": "Semifinal 1 Match 3",
+		"f1m2":  "Final 1 Match 2",
+		"pm1":   "pm1",
+	}
+
+	for key, expected := range testCases {
+		if name := matchName(key); name != expected {
+			t.Errorf("expected match name for %q to be %q, got %q", key, expected, name)
+		}
+	}
+}
@@ -2,8 +2,11 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
@@ -11,6 +14,11 @@ import (
 	"github.com/gorilla/mux"
 )
 
+const (
+	defaultCommentSearchLimit = 25
+	maxCommentSearchLimit     = 100
+)
+
 // this is a hack because match keys are stored weirdly right now
 func trimMatchKey(key string) string {
 	parts := strings.Split(key, "_")
@@ -73,7 +81,9 @@ func (s *Server) getEventComments() http.HandlerFunc {
 			realmID = &userRealmID
 		}
 
-		comments, err = s.Store.GetEventTeamCommentsForRealm(r.Context(), eventKey, teamKey, realmID)
+		tags := uniqueStrings(r.URL.Query()["tag"])
+
+		comments, err = s.Store.GetEventTeamCommentsForRealm(r.Context(), eventKey, teamKey, realmID, tags)
 
 		if err != nil {
 			ihttp.Error(w, http.StatusInternalServerError)
@@ -126,6 +136,21 @@ func (s *Server) putMatchTeamComment() http.HandlerFunc {
 		}
 		comment.RealmID = &realmID
 
+		catalog, err := s.Store.GetCommentTags(r.Context(), realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving comment tags")
+			return
+		}
+
+		if comment.Tags != nil {
+			comment.Tags = uniqueStrings(comment.Tags)
+		}
+		if err := validateCommentTags(catalog, comment.Tags); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
 		created, err := s.Store.UpsertMatchTeamComment(r.Context(), comment)
 		if err != nil {
 			ihttp.Error(w, http.StatusInternalServerError)
@@ -141,3 +166,74 @@ func (s *Server) putMatchTeamComment() http.HandlerFunc {
 	}
 
 }
+
+// parseCommentSearchFilter parses the query parameters for searching comments
+// into a comment search filter.
+func parseCommentSearchFilter(query url.Values) (store.CommentSearchFilter, error) {
+	filter := store.CommentSearchFilter{
+		Query: strings.TrimSpace(query.Get("q")),
+		Limit: defaultCommentSearchLimit,
+	}
+
+	if filter.Query == "" {
+		return filter, errors.New("q is required")
+	}
+
+	if event := query.Get("event"); event != "" {
+		filter.EventKey = &event
+	}
+
+	if team := query.Get("team"); team != "" {
+		filter.TeamKey = &team
+	}
+
+	if reporterQuery := query.Get("reporter"); reporterQuery != "" {
+		reporterID, err := strconv.ParseInt(reporterQuery, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid reporter: %w", err)
+		}
+		filter.ReporterID = &reporterID
+	}
+
+	if limitQuery := query.Get("limit"); limitQuery != "" {
+		limit, err := strconv.Atoi(limitQuery)
+		if err != nil || limit < 1 || limit > maxCommentSearchLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxCommentSearchLimit)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// searchCommentsHandler returns a handler to search comments visible to the
+// current realm, most relevant first.
+func (s *Server) searchCommentsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseCommentSearchFilter(r.URL.Query())
+		if err != nil {
+			ihttp.Respond(w, err, http.StatusBadRequest)
+			return
+		}
+
+		var realmID *int64
+		userRealmID, err := ihttp.GetRealmID(r)
+		if err == nil {
+			realmID = &userRealmID
+		}
+
+		results, err := s.Store.SearchCommentsForRealm(r.Context(), realmID, filter)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("searching comments")
+			return
+		}
+
+		// this is a hack since match keys are stored weirdly right now
+		for i, c := range results {
+			results[i].MatchKey = trimMatchKey(c.MatchKey)
+		}
+
+		ihttp.Respond(w, results, http.StatusOK)
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCommentSearchFilter(t *testing.T) {
+	event := "2019orwil"
+	team := "frc1234"
+	reporterID := int64(7)
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedFilter store.CommentSearchFilter
+		expectError    bool
+	}{
+		{
+			name:  "query only",
+			query: "q=tipped+over",
+			expectedFilter: store.CommentSearchFilter{
+				Query: "tipped over",
+				Limit: defaultCommentSearchLimit,
+			},
+		},
+		{
+			name:  "all filters",
+			query: "q=%22tipped+over%22&event=2019orwil&team=frc1234&reporter=7&limit=10",
+			expectedFilter: store.CommentSearchFilter{
+				Query:      `"tipped over"`,
+				EventKey:   &event,
+				TeamKey:    &team,
+				ReporterID: &reporterID,
+				Limit:      10,
+			},
+		},
+		{
+			name:        "missing query",
+			query:       "event=2019orwil",
+			expectError: true,
+		},
+		{
+			name:        "blank query",
+			query:       "q=+++",
+			expectError: true,
+		},
+		{
+			name:        "invalid reporter",
+			query:       "q=fast&reporter=bob",
+			expectError: true,
+		},
+		{
+			name:        "limit too large",
+			query:       "q=fast&limit=101",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+
+			filter, err := parseCommentSearchFilter(query)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+
+			if !tt.expectError && !cmp.Equal(tt.expectedFilter, filter) {
+				t.Errorf("expected filter to match expected filter, but got diff: %s", cmp.Diff(tt.expectedFilter, filter))
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+)
+
+type slotCoverage struct {
+	MatchKey string `json:"matchKey"`
+	TeamKey  string `json:"teamKey"`
+	ScoutID  *int64 `json:"scoutId,omitempty"`
+	Played   bool   `json:"played"`
+	Reports  int    `json:"reports"`
+	Comments int    `json:"comments"`
+}
+
+type mismatchedReport struct {
+	MatchKey   string `json:"matchKey"`
+	TeamKey    string `json:"teamKey"`
+	ReporterID *int64 `json:"reporterId"`
+}
+
+// reporterCoverage aggregates coverage for a single reporter. ReporterID is nil
+// for reports by users that have been deleted.
+type reporterCoverage struct {
+	ReporterID *int64 `json:"reporterId"`
+	Reports    int    `json:"reports"`
+	Comments   int    `json:"comments"`
+	Duplicates int    `json:"duplicates"`
+	Mismatched int    `json:"mismatched"`
+	Assigned   int    `json:"assigned"`
+	Completed  int    `json:"completed"`
+}
+
+// coverage describes how complete a realms scouting of an event is. Missing
+// holds slots with no reports, Duplicates holds slots with more than one report,
+// and Mismatched holds reports for teams that weren't in the match.
+type coverage struct {
+	Total      int                `json:"total"`
+	Reported   int                `json:"reported"`
+	Slots      []slotCoverage     `json:"slots"`
+	Missing    []slotCoverage     `json:"missing"`
+	Duplicates []slotCoverage     `json:"duplicates"`
+	Mismatched []mismatchedReport `json:"mismatched"`
+	Reporters  []reporterCoverage `json:"reporters"`
+}
+
+// buildCoverage aggregates the reports and comments for an event by slot and by
+// reporter. Match keys in the returned coverage have the event key prefix removed.
+func buildCoverage(eventKey string, slots []store.Slot, reports, comments []store.ReportKey) coverage {
+	c := coverage{
+		Slots:      []slotCoverage{},
+		Missing:    []slotCoverage{},
+		Duplicates: []slotCoverage{},
+		Mismatched: []mismatchedReport{},
+		Reporters:  []reporterCoverage{},
+	}
+
+	type slotKey struct{ matchKey, teamKey string }
+
+	slotIndexes := make(map[slotKey]int)
+	matches := make(map[string]bool)
+	for i, slot := range slots {
+		slotIndexes[slotKey{slot.MatchKey, slot.TeamKey}] = i
+		matches[slot.MatchKey] = true
+		c.Slots = append(c.Slots, slotCoverage{
+			MatchKey: strings.TrimPrefix(slot.MatchKey, eventKey+"_"),
+			TeamKey:  slot.TeamKey,
+			ScoutID:  slot.ScoutID,
+			Played:   slot.Played,
+		})
+	}
+
+	// reporters are keyed by ID, with deleted users keyed by 0 since user IDs
+	// start at 1
+	reporters := make(map[int64]*reporterCoverage)
+	reporter := func(id *int64) *reporterCoverage {
+		var key int64
+		if id != nil {
+			key = *id
+		}
+
+		if reporters[key] == nil {
+			reporters[key] = &reporterCoverage{ReporterID: id}
+		}
+
+		return reporters[key]
+	}
+
+	for _, slot := range c.Slots {
+		if slot.ScoutID != nil {
+			reporter(slot.ScoutID).Assigned++
+		}
+	}
+
+	slotReporters := make(map[int][]*int64)
+	for _, report := range reports {
+		reporter(report.ReporterID).Reports++
+
+		i, ok := slotIndexes[slotKey{report.MatchKey, report.TeamKey}]
+		if !ok {
+			// the team wasn't in the match, unless the match was deleted from
+			// the event in which case the report is ignored
+			if matches[report.MatchKey] {
+				reporter(report.ReporterID).Mismatched++
+				c.Mismatched = append(c.Mismatched, mismatchedReport{
+					MatchKey:   strings.TrimPrefix(report.MatchKey, eventKey+"_"),
+					TeamKey:    report.TeamKey,
+					ReporterID: report.ReporterID,
+				})
+			}
+			continue
+		}
+
+		c.Slots[i].Reports++
+		slotReporters[i] = append(slotReporters[i], report.ReporterID)
+
+		if scoutID := c.Slots[i].ScoutID; scoutID != nil && report.ReporterID != nil && *scoutID == *report.ReporterID {
+			reporter(scoutID).Completed++
+		}
+	}
+
+	for _, comment := range comments {
+		reporter(comment.ReporterID).Comments++
+
+		if i, ok := slotIndexes[slotKey{comment.MatchKey, comment.TeamKey}]; ok {
+			c.Slots[i].Comments++
+		}
+	}
+
+	c.Total = len(c.Slots)
+	for i, slot := range c.Slots {
+		switch {
+		case slot.Reports == 0:
+			c.Missing = append(c.Missing, slot)
+		case slot.Reports > 1:
+			c.Duplicates = append(c.Duplicates, slot)
+			for _, id := range slotReporters[i] {
+				reporter(id).Duplicates++
+			}
+		}
+
+		if slot.Reports > 0 {
+			c.Reported++
+		}
+	}
+
+	for _, r := range reporters {
+		c.Reporters = append(c.Reporters, *r)
+	}
+
+	sort.Slice(c.Reporters, func(i, j int) bool {
+		a, b := c.Reporters[i], c.Reporters[j]
+		if a.Reports != b.Reports {
+			return a.Reports > b.Reports
+		}
+		if a.ReporterID == nil || b.ReporterID == nil {
+			return b.ReporterID == nil && a.ReporterID != nil
+		}
+		return *a.ReporterID < *b.ReporterID
+	})
+
+	return c
+}
+
+// coverageHandler returns a handler to get how complete the current realms
+// scouting of an event is: the number of reports and comments for every team in
+// every match, missing and duplicate reports, reports for teams that weren't in
+// the match, and totals for each reporter.
+func (s *Server) coverageHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventKey := mux.Vars(r)["eventKey"]
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		slots, err := s.Store.GetEventSlots(r.Context(), eventKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event slots")
+			return
+		}
+
+		reports, err := s.Store.GetEventReportKeys(r.Context(), eventKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event reports")
+			return
+		}
+
+		comments, err := s.Store.GetEventCommentKeys(r.Context(), eventKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event comments")
+			return
+		}
+
+		ihttp.Respond(w, buildCoverage(eventKey, slots, reports, comments), http.StatusOK)
+	}
+}
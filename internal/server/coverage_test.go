@@ -0,0 +1,59 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildCoverage(t *testing.T) {
+	alice, bob := int64(1), int64(2)
+
+	slots := []store.Slot{
+		{MatchKey: "2019orwil_qm1", TeamKey: "frc2733", ScoutID: &alice, Played: true},
+		{MatchKey: "2019orwil_qm1", TeamKey: "frc254", ScoutID: &bob, Played: true},
+		{MatchKey: "2019orwil_qm2", TeamKey: "frc2733", ScoutID: &alice},
+	}
+
+	reports := []store.ReportKey{
+		{MatchKey: "2019orwil_qm1", TeamKey: "frc2733", ReporterID: &alice},
+		{MatchKey: "2019orwil_qm1", TeamKey: "frc2733", ReporterID: &bob},
+		{MatchKey: "2019orwil_qm1", TeamKey: "frc1678", ReporterID: &bob},
+		{MatchKey: "2019orwil_qm99", TeamKey: "frc2733", ReporterID: nil},
+	}
+
+	comments := []store.ReportKey{
+		{MatchKey: "2019orwil_qm1", TeamKey: "frc2733", ReporterID: &alice},
+	}
+
+	expectedCoverage := coverage{
+		Total:    3,
+		Reported: 1,
+		Slots: []slotCoverage{
+			{MatchKey: "qm1", TeamKey: "frc2733", ScoutID: &alice, Played: true, Reports: 2, Comments: 1},
+			{MatchKey: "qm1", TeamKey: "frc254", ScoutID: &bob, Played: true},
+			{MatchKey: "qm2", TeamKey: "frc2733", ScoutID: &alice},
+		},
+		Missing: []slotCoverage{
+			{MatchKey: "qm1", TeamKey: "frc254", ScoutID: &bob, Played: true},
+			{MatchKey: "qm2", TeamKey: "frc2733", ScoutID: &alice},
+		},
+		Duplicates: []slotCoverage{
+			{MatchKey: "qm1", TeamKey: "frc2733", ScoutID: &alice, Played: true, Reports: 2, Comments: 1},
+		},
+		Mismatched: []mismatchedReport{
+			{MatchKey: "qm1", TeamKey: "frc1678", ReporterID: &bob},
+		},
+		Reporters: []reporterCoverage{
+			{ReporterID: &bob, Reports: 2, Duplicates: 1, Mismatched: 1, Assigned: 1},
+			{ReporterID: &alice, Reports: 1, Comments: 1, Duplicates: 1, Assigned: 2, Completed: 1},
+			{ReporterID: nil, Reports: 1},
+		},
+	}
+
+	actualCoverage := buildCoverage("2019orwil", slots, reports, comments)
+	if !cmp.Equal(expectedCoverage, actualCoverage) {
+		t.Errorf("expected coverage to match expected coverage, but got diff: %s", cmp.Diff(expectedCoverage, actualCoverage))
+	}
+}
@@ -2,10 +2,14 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"errors"
 
@@ -15,10 +19,182 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-// eventsHandler returns a handler to get all events in a given year.
+const maxEventsLimit = 500
+
+// parseEventFilter parses the query parameters for the events listing into an
+// event filter.
+func parseEventFilter(query url.Values) (store.EventFilter, error) {
+	var filter store.EventFilter
+
+	filter.TBADeleted, _ = strconv.ParseBool(query.Get("tbaDeleted"))
+
+	if district := query.Get("district"); district != "" {
+		filter.District = &district
+	}
+
+	if weekQuery := query.Get("week"); weekQuery != "" {
+		week, err := strconv.Atoi(weekQuery)
+		if err != nil {
+			return filter, fmt.Errorf("invalid week: %w", err)
+		}
+		filter.Week = &week
+	}
+
+	if fromQuery := query.Get("from"); fromQuery != "" {
+		from, err := time.Parse("2006-01-02", fromQuery)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date: %w", err)
+		}
+		filter.From = &from
+	}
+
+	if toQuery := query.Get("to"); toQuery != "" {
+		to, err := time.Parse("2006-01-02", toQuery)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date: %w", err)
+		}
+		filter.To = &to
+	}
+
+	filter.Types = query["type"]
+	filter.Search = query.Get("q")
+
+	switch source := query.Get("source"); source {
+	case "", store.EventSourceRealm, store.EventSourceTBA:
+		filter.Source = source
+	default:
+		return filter, fmt.Errorf("invalid source %q", source)
+	}
+
+	if nearQuery := query.Get("near"); nearQuery != "" {
+		near, err := parseCoordinates(nearQuery)
+		if err != nil {
+			return filter, fmt.Errorf("invalid near: %w", err)
+		}
+		filter.Near = &near
+	}
+
+	if radiusQuery := query.Get("radius"); radiusQuery != "" {
+		if filter.Near == nil {
+			return filter, errors.New("radius requires near")
+		}
+
+		radius, err := strconv.ParseFloat(radiusQuery, 64)
+		if err != nil || radius <= 0 {
+			return filter, errors.New("radius must be a positive number of kilometers")
+		}
+		filter.Radius = &radius
+	}
+
+	sort := query.Get("sort")
+	if strings.HasPrefix(sort, "-") {
+		filter.Descending = true
+		sort = strings.TrimPrefix(sort, "-")
+	}
+
+	switch sort {
+	case "":
+		filter.Sort = store.EventSortStartDate
+		if filter.Near != nil {
+			filter.Sort = store.EventSortDistance
+		}
+	case "startDate":
+		filter.Sort = store.EventSortStartDate
+	case "endDate":
+		filter.Sort = store.EventSortEndDate
+	case "name":
+		filter.Sort = store.EventSortName
+	case "distance":
+		if filter.Near == nil {
+			return filter, errors.New("sorting by distance requires near")
+		}
+		filter.Sort = store.EventSortDistance
+	default:
+		return filter, fmt.Errorf("invalid sort %q", sort)
+	}
+
+	if limitQuery := query.Get("limit"); limitQuery != "" {
+		limit, err := strconv.Atoi(limitQuery)
+		if err != nil || limit < 1 || limit > maxEventsLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxEventsLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if cursorQuery := query.Get("cursor"); cursorQuery != "" {
+		cursor, err := decodeEventCursor(cursorQuery)
+		if err != nil {
+			return filter, fmt.Errorf("invalid cursor: %w", err)
+		}
+		filter.Cursor = &cursor
+	}
+
+	return filter, nil
+}
+
+// parseCoordinates parses coordinates in the form "lat,lon".
+func parseCoordinates(s string) (store.Coordinates, error) {
+	var coords store.Coordinates
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return coords, errors.New("coordinates must be in the form lat,lon")
+	}
+
+	var err error
+	if coords.Lat, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64); err != nil {
+		return coords, fmt.Errorf("invalid latitude: %w", err)
+	}
+	if coords.Lon, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err != nil {
+		return coords, fmt.Errorf("invalid longitude: %w", err)
+	}
+
+	if coords.Lat < -90 || coords.Lat > 90 || coords.Lon < -180 || coords.Lon > 180 {
+		return coords, errors.New("coordinates out of range")
+	}
+
+	return coords, nil
+}
+
+func encodeEventCursor(cursor store.EventCursor) (string, error) {
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeEventCursor(s string) (store.EventCursor, error) {
+	var cursor store.EventCursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+
+	return cursor, json.Unmarshal(b, &cursor)
+}
+
+// eventsHandler returns a handler to get all events matching the query filters. If a
+// limit is given and more events are available, the cursor for the next page is set
+// in the X-Next-Cursor header.
 func (s *Server) eventsHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tbaDeleted, _ := strconv.ParseBool(r.URL.Query().Get("tbaDeleted"))
+		filter, err := parseEventFilter(r.URL.Query())
+		if err != nil {
+			ihttp.Respond(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if starred, _ := strconv.ParseBool(r.URL.Query().Get("starred")); starred {
+			userID, err := ihttp.GetSubject(r)
+			if err != nil {
+				ihttp.Error(w, http.StatusUnauthorized)
+				return
+			}
+			filter.StarredBy = &userID
+		}
 
 		var realmID *int64
 		userRealmID, err := ihttp.GetRealmID(r)
@@ -26,13 +202,23 @@ func (s *Server) eventsHandler() http.HandlerFunc {
 			realmID = &userRealmID
 		}
 
-		events, err := s.Store.GetEventsForRealm(r.Context(), tbaDeleted, realmID)
+		events, err := s.Store.GetEventsForRealm(r.Context(), realmID, filter)
 		if err != nil {
 			ihttp.Error(w, http.StatusInternalServerError)
 			s.Logger.WithError(err).Error("retrieving event data")
 			return
 		}
 
+		if next := filter.NextCursor(events); next != nil {
+			cursor, err := encodeEventCursor(*next)
+			if err != nil {
+				ihttp.Error(w, http.StatusInternalServerError)
+				s.Logger.WithError(err).Error("encoding event cursor")
+				return
+			}
+			w.Header().Set("X-Next-Cursor", cursor)
+		}
+
 		ihttp.Respond(w, &events, http.StatusOK)
 	}
 }
@@ -77,12 +263,12 @@ func (s *Server) upsertEventHandler() http.HandlerFunc {
 			ihttp.Error(w, http.StatusForbidden)
 			return
 		}
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
 
 		event.Key = eventKey
 		event.RealmID = &creatorRealm
 
-		existed, err := editEvent(r.Context(), s.Store, roles, creatorRealm, event.Key, func(tx *sqlx.Tx) error {
+		existed, err := editEvent(r.Context(), s.Store, permissions, creatorRealm, event.Key, func(tx *sqlx.Tx) error {
 			if err := s.Store.UpsertEventTx(r.Context(), tx, event); err != nil {
 				return fmt.Errorf("unable to upsert event: %w", err)
 			}
@@ -106,7 +292,7 @@ func (s *Server) upsertEventHandler() http.HandlerFunc {
 	}
 }
 
-func editEvent(ctx context.Context, sto *store.Service, roles store.Roles, userRealmID int64, eventKey string, editFunc func(tx *sqlx.Tx) error) (existed bool, err error) {
+func editEvent(ctx context.Context, sto *store.Service, permissions store.Permissions, userRealmID int64, eventKey string, editFunc func(tx *sqlx.Tx) error) (existed bool, err error) {
 	existed = true
 
 	err = sto.DoTransaction(ctx, func(tx *sqlx.Tx) error {
@@ -121,7 +307,7 @@ func editEvent(ctx context.Context, sto *store.Service, roles store.Roles, userR
 			return fmt.Errorf("unable to get events: %w", err)
 		}
 
-		if realmID == nil && !roles.IsSuperAdmin {
+		if realmID == nil && !permissions.Has(store.PermissionManageAll) {
 			return forbiddenError{errors.New("only super-admins can edit events with no realm ID")}
 		} else if realmID != nil && *realmID != userRealmID {
 			return forbiddenError{errors.New("only realm admins with matching realm IDs can edit events with a specified realm ID")}
@@ -136,3 +322,25 @@ func editEvent(ctx context.Context, sto *store.Service, roles store.Roles, userR
 
 	return existed, err
 }
+
+// eventAwardsHandler returns a handler to get all awards given at a specific event.
+func (s *Server) eventAwardsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventKey := mux.Vars(r)["eventKey"]
+
+		var realmID *int64
+		userRealmID, err := ihttp.GetRealmID(r)
+		if err == nil {
+			realmID = &userRealmID
+		}
+
+		awards, err := s.Store.GetEventAwardsForRealm(r.Context(), eventKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event awards")
+			return
+		}
+
+		ihttp.Respond(w, awards, http.StatusOK)
+	}
+}
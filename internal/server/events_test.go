@@ -0,0 +1,193 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseEventFilter(t *testing.T) {
+	district := "pnw"
+	week := 3
+	from := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2019, 3, 31, 0, 0, 0, 0, time.UTC)
+	radius := 50.5
+
+	cursor, err := encodeEventCursor(store.EventCursor{Value: "Wilsonville", Key: "2019orwil"})
+	if err != nil {
+		t.Fatalf("did not expect error encoding cursor but got: %v", err)
+	}
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedFilter store.EventFilter
+		expectError    bool
+	}{
+		{
+			name:           "no query",
+			query:          "",
+			expectedFilter: store.EventFilter{Sort: store.EventSortStartDate},
+		},
+		{
+			name:  "all filters",
+			query: "tbaDeleted=true&district=pnw&week=3&from=2019-03-01&to=2019-03-31&type=district&type=offseason&source=tba&q=wil&sort=-name&limit=20&cursor=" + cursor,
+			expectedFilter: store.EventFilter{
+				TBADeleted: true,
+				District:   &district,
+				Week:       &week,
+				From:       &from,
+				To:         &to,
+				Types:      []string{"district", "offseason"},
+				Source:     store.EventSourceTBA,
+				Search:     "wil",
+				Sort:       store.EventSortName,
+				Descending: true,
+				Limit:      20,
+				Cursor:     &store.EventCursor{Value: "Wilsonville", Key: "2019orwil"},
+			},
+		},
+		{
+			name:  "near defaults to distance sort",
+			query: "near=45.52,-122.68&radius=50.5",
+			expectedFilter: store.EventFilter{
+				Near:   &store.Coordinates{Lat: 45.52, Lon: -122.68},
+				Radius: &radius,
+				Sort:   store.EventSortDistance,
+			},
+		},
+		{
+			name:  "near with other sort",
+			query: "near=45.52,-122.68&sort=-startDate",
+			expectedFilter: store.EventFilter{
+				Near:       &store.Coordinates{Lat: 45.52, Lon: -122.68},
+				Sort:       store.EventSortStartDate,
+				Descending: true,
+			},
+		},
+		{
+			name:        "invalid near",
+			query:       "near=45.52",
+			expectError: true,
+		},
+		{
+			name:        "near out of range",
+			query:       "near=95,-122.68",
+			expectError: true,
+		},
+		{
+			name:        "radius without near",
+			query:       "radius=50",
+			expectError: true,
+		},
+		{
+			name:        "negative radius",
+			query:       "near=45.52,-122.68&radius=-5",
+			expectError: true,
+		},
+		{
+			name:        "distance sort without near",
+			query:       "sort=distance",
+			expectError: true,
+		},
+		{
+			name:        "invalid week",
+			query:       "week=three",
+			expectError: true,
+		},
+		{
+			name:        "invalid date",
+			query:       "from=03/01/2019",
+			expectError: true,
+		},
+		{
+			name:        "invalid source",
+			query:       "source=everywhere",
+			expectError: true,
+		},
+		{
+			name:        "invalid sort",
+			query:       "sort=lat",
+			expectError: true,
+		},
+		{
+			name:        "limit too large",
+			query:       "limit=501",
+			expectError: true,
+		},
+		{
+			name:        "invalid cursor",
+			query:       "cursor=not-a-cursor",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+
+			filter, err := parseEventFilter(query)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+
+			if !tt.expectError && !cmp.Equal(tt.expectedFilter, filter) {
+				t.Errorf("expected filter to match expected filter, but got diff: %s", cmp.Diff(tt.expectedFilter, filter))
+			}
+		})
+	}
+}
+
+func TestEventFilterNextCursor(t *testing.T) {
+	orwilDistance := 12.25
+	events := []store.Event{
+		{Key: "2019orore", Name: "Clackamas", StartDate: time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Key: "2019orwil", Name: "Wilsonville", StartDate: time.Date(2019, 3, 15, 0, 0, 0, 0, time.UTC), Distance: &orwilDistance},
+	}
+
+	testCases := []struct {
+		name           string
+		filter         store.EventFilter
+		expectedCursor *store.EventCursor
+	}{
+		{
+			name:           "no limit",
+			filter:         store.EventFilter{},
+			expectedCursor: nil,
+		},
+		{
+			name:           "last page",
+			filter:         store.EventFilter{Limit: 3},
+			expectedCursor: nil,
+		},
+		{
+			name:           "full page sorted by start date",
+			filter:         store.EventFilter{Limit: 2},
+			expectedCursor: &store.EventCursor{Value: "2019-03-15T00:00:00Z", Key: "2019orwil"},
+		},
+		{
+			name:           "full page sorted by name",
+			filter:         store.EventFilter{Limit: 2, Sort: store.EventSortName},
+			expectedCursor: &store.EventCursor{Value: "Wilsonville", Key: "2019orwil"},
+		},
+		{
+			name:           "full page sorted by distance",
+			filter:         store.EventFilter{Limit: 2, Near: &store.Coordinates{}, Sort: store.EventSortDistance},
+			expectedCursor: &store.EventCursor{Value: "12.25", Key: "2019orwil"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor := tt.filter.NextCursor(events)
+			if !cmp.Equal(tt.expectedCursor, cursor) {
+				t.Errorf("expected cursor to match expected cursor, but got diff: %s", cmp.Diff(tt.expectedCursor, cursor))
+			}
+		})
+	}
+}
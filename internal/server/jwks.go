@@ -0,0 +1,18 @@
+package server
+
+import (
+	"net/http"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/jwtkeys"
+)
+
+// jwksHandler returns a handler that publishes the public keys JWTs are signed
+// with, so other services can verify them. Keys are published before they sign
+// tokens, so verifiers can cache them for a few minutes.
+func jwksHandler(keys *jwtkeys.Set) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		ihttp.Respond(w, keys.JWKS(), http.StatusOK)
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/jwtkeys"
+)
+
+func TestJWKSHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	if err != nil {
+		t.Fatalf("did not expect error %v setting up test", err)
+	}
+
+	jwksHandler(jwtkeys.NewHMAC("i-am-secret"))(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	var set jwtkeys.JWKSet
+	if err := json.NewDecoder(rr.Body).Decode(&set); err != nil {
+		t.Fatalf("did not expect error decoding jwks but got: %v", err)
+	}
+
+	if set.Keys == nil || len(set.Keys) != 0 {
+		t.Errorf("expected empty key list without publishing the jwt secret, but got %+v", set.Keys)
+	}
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+)
+
+// leaderboardEntry adds the longest streak of consecutive matches a reporter
+// reported on, and how accurate their reports were, to a store leaderboard entry.
+// Accuracy is only known when the leaderboard is for a single event with a schema,
+// and the reporter's reports could be compared to something.
+type leaderboardEntry struct {
+	store.LeaderboardEntry
+	LongestStreak int      `json:"longestStreak"`
+	Accuracy      *float64 `json:"accuracy"`
+}
+
+// parseLeaderboardFilter parses the query parameters for the leaderboard into a
+// leaderboard filter.
+func parseLeaderboardFilter(query url.Values) (store.LeaderboardFilter, error) {
+	var filter store.LeaderboardFilter
+
+	if event := query.Get("event"); event != "" {
+		filter.EventKey = &event
+	}
+
+	if fromQuery := query.Get("from"); fromQuery != "" {
+		from, err := time.Parse("2006-01-02", fromQuery)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date: %w", err)
+		}
+		filter.From = &from
+	}
+
+	if toQuery := query.Get("to"); toQuery != "" {
+		to, err := time.Parse("2006-01-02", toQuery)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date: %w", err)
+		}
+		filter.To = &to
+	}
+
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		return filter, errors.New("to must not be before from")
+	}
+
+	return filter, nil
+}
+
+// longestStreaks returns the most consecutive matches at a single event that each
+// reporter reported on. The matches must be ordered by reporter, event, and match
+// number, with no duplicates.
+func longestStreaks(matches []store.ReporterMatch) map[int64]int {
+	streaks := make(map[int64]int)
+
+	var streak int
+	for i, match := range matches {
+		if i > 0 {
+			prev := matches[i-1]
+			if prev.ReporterID == match.ReporterID && prev.EventKey == match.EventKey && prev.MatchNumber+1 == match.MatchNumber {
+				streak++
+			} else {
+				streak = 1
+			}
+		} else {
+			streak = 1
+		}
+
+		if streak > streaks[match.ReporterID] {
+			streaks[match.ReporterID] = streak
+		}
+	}
+
+	return streaks
+}
+
+// leaderboardHandler returns a handler to get how much scouting each user in the
+// current realm has done. The leaderboard can be limited to a single event and to
+// matches within a date range.
+func (s *Server) leaderboardHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLeaderboardFilter(r.URL.Query())
+		if err != nil {
+			ihttp.Respond(w, err, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		storeLeaderboard, err := s.Store.GetLeaderboardForRealm(r.Context(), realmID, filter)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting leaderboard")
+			return
+		}
+
+		reporterMatches, err := s.Store.GetReporterMatchesForRealm(r.Context(), realmID, filter)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting reporter matches")
+			return
+		}
+
+		streaks := longestStreaks(reporterMatches)
+
+		var accuracies map[int64]float64
+		if filter.EventKey != nil {
+			accuracies, err = s.reporterAccuracies(r, *filter.EventKey, realmID)
+			if errors.Is(err, store.ErrNoResults{}) {
+				ihttp.Error(w, http.StatusNotFound)
+				return
+			} else if err != nil {
+				ihttp.Error(w, http.StatusInternalServerError)
+				s.Logger.WithError(err).Error("getting reporter accuracies")
+				return
+			}
+		}
+
+		leaderboard := make([]leaderboardEntry, len(storeLeaderboard))
+		for i, entry := range storeLeaderboard {
+			leaderboard[i] = leaderboardEntry{
+				LeaderboardEntry: entry,
+				LongestStreak:    streaks[entry.ReporterID],
+			}
+
+			if accuracy, ok := accuracies[entry.ReporterID]; ok {
+				leaderboard[i].Accuracy = &accuracy
+			}
+		}
+
+		ihttp.Respond(w, leaderboard, http.StatusOK)
+	}
+}
+
+// reporterAccuracies returns the fraction of comparisons each reporter's reports
+// at an event agreed with, for reporters whose reports could be compared to TBA
+// data or other reports. Events without a schema have no accuracies.
+func (s *Server) reporterAccuracies(r *http.Request, eventKey string, realmID int64) (map[int64]float64, error) {
+	schema, teamToMatches, err := s.getEventTeamMatches(r.Context(), eventKey, &realmID)
+	if errors.Is(err, errNoSchema) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	reliabilities, err := analyzeReliability(schema, teamToMatches)
+	if err != nil {
+		return nil, fmt.Errorf("unable to analyze reliability: %w", err)
+	}
+
+	accuracies := make(map[int64]float64)
+	for reporterID, reliability := range reliabilities {
+		if reliability.TBAComparisons+reliability.PeerComparisons > 0 {
+			accuracies[reporterID] = 1 - reliability.ErrorRate()
+		}
+	}
+
+	return accuracies, nil
+}
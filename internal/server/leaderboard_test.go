@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLeaderboardFilter(t *testing.T) {
+	event := "2019orwil"
+	from := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2019, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedFilter store.LeaderboardFilter
+		expectError    bool
+	}{
+		{
+			name:           "no query",
+			query:          "",
+			expectedFilter: store.LeaderboardFilter{},
+		},
+		{
+			name:           "all filters",
+			query:          "event=2019orwil&from=2019-03-01&to=2019-03-03",
+			expectedFilter: store.LeaderboardFilter{EventKey: &event, From: &from, To: &to},
+		},
+		{
+			name:           "single day",
+			query:          "from=2019-03-03&to=2019-03-03",
+			expectedFilter: store.LeaderboardFilter{From: &to, To: &to},
+		},
+		{
+			name:        "invalid date",
+			query:       "to=03/03/2019",
+			expectError: true,
+		},
+		{
+			name:        "to before from",
+			query:       "from=2019-03-03&to=2019-03-01",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+
+			filter, err := parseLeaderboardFilter(query)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+
+			if !tt.expectError && !cmp.Equal(tt.expectedFilter, filter) {
+				t.Errorf("expected filter to match expected filter, but got diff: %s", cmp.Diff(tt.expectedFilter, filter))
+			}
+		})
+	}
+}
+
+func TestLongestStreaks(t *testing.T) {
+	matches := []store.ReporterMatch{
+		{ReporterID: 1, EventKey: "2019orlak", MatchNumber: 1},
+		{ReporterID: 1, EventKey: "2019orlak", MatchNumber: 2},
+		{ReporterID: 1, EventKey: "2019orlak", MatchNumber: 4},
+		{ReporterID: 1, EventKey: "2019orwil", MatchNumber: 5},
+		{ReporterID: 1, EventKey: "2019orwil", MatchNumber: 6},
+		{ReporterID: 1, EventKey: "2019orwil", MatchNumber: 7},
+		{ReporterID: 2, EventKey: "2019orwil", MatchNumber: 8},
+		{ReporterID: 3, EventKey: "2019orlak", MatchNumber: 10},
+		{ReporterID: 3, EventKey: "2019orwil", MatchNumber: 11},
+	}
+
+	expectedStreaks := map[int64]int{1: 3, 2: 1, 3: 1}
+
+	streaks := longestStreaks(matches)
+	if !cmp.Equal(expectedStreaks, streaks) {
+		t.Errorf("expected streaks to match expected streaks, but got diff: %s", cmp.Diff(expectedStreaks, streaks))
+	}
+}
@@ -148,7 +148,7 @@ func (s *Server) upsertMatchHandler() http.HandlerFunc {
 			TBAURL:        m.TBAURL,
 		}
 
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
 
 		userRealmID, err := ihttp.GetRealmID(r)
 		if err != nil {
@@ -156,7 +156,7 @@ func (s *Server) upsertMatchHandler() http.HandlerFunc {
 			return
 		}
 
-		existed, err := editMatch(r.Context(), s.Store, roles, userRealmID, sm.Key, func(tx *sqlx.Tx) error {
+		existed, err := editMatch(r.Context(), s.Store, permissions, userRealmID, sm.Key, func(tx *sqlx.Tx) error {
 			if err := s.Store.UpsertMatchTx(r.Context(), tx, sm); err != nil {
 				return fmt.Errorf("unable to upsert match: %w", err)
 			}
@@ -186,7 +186,7 @@ func (s *Server) deleteMatchHandler() http.HandlerFunc {
 		eventKey := vars["eventKey"]
 		matchKey := vars["matchKey"]
 
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
 
 		userRealmID, err := ihttp.GetRealmID(r)
 		if err != nil {
@@ -198,7 +198,7 @@ func (s *Server) deleteMatchHandler() http.HandlerFunc {
 		// unique and consistent with TBA match keys.
 		matchKey = fmt.Sprintf("%s_%s", eventKey, matchKey)
 
-		existed, err := editMatch(r.Context(), s.Store, roles, userRealmID, matchKey, func(tx *sqlx.Tx) error {
+		existed, err := editMatch(r.Context(), s.Store, permissions, userRealmID, matchKey, func(tx *sqlx.Tx) error {
 			if err := s.Store.DeleteMatchTx(r.Context(), tx, matchKey); err != nil {
 				return fmt.Errorf("unable to delete match: %w", err)
 			}
@@ -222,7 +222,7 @@ func (s *Server) deleteMatchHandler() http.HandlerFunc {
 	}
 }
 
-func editMatch(ctx context.Context, sto *store.Service, roles store.Roles, userRealmID int64, matchKey string, editFunc func(tx *sqlx.Tx) error) (existed bool, err error) {
+func editMatch(ctx context.Context, sto *store.Service, permissions store.Permissions, userRealmID int64, matchKey string, editFunc func(tx *sqlx.Tx) error) (existed bool, err error) {
 	existed = true
 
 	err = sto.DoTransaction(ctx, func(tx *sqlx.Tx) error {
@@ -237,7 +237,7 @@ func editMatch(ctx context.Context, sto *store.Service, roles store.Roles, userR
 			return fmt.Errorf("unable to get matches: %w", err)
 		}
 
-		if realmID == nil && !roles.IsSuperAdmin {
+		if realmID == nil && !permissions.Has(store.PermissionManageAll) {
 			return forbiddenError{errors.New("only super-admins can edit matches with no realm ID")}
 		} else if realmID != nil && *realmID != userRealmID {
 			return forbiddenError{errors.New("only realm admins with matching realm IDs can edit matches with a specified realm ID")}
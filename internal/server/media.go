@@ -0,0 +1,435 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/blob"
+	"github.com/Pigmice2733/peregrine-backend/internal/config"
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/Pigmice2733/peregrine-backend/internal/thumbnail"
+	"github.com/gorilla/mux"
+)
+
+// thumbnailSize is the largest width or height of image thumbnails.
+const thumbnailSize = 320
+
+// mediaTimeout is how long media uploads and downloads have to be read and
+// responded to.
+const mediaTimeout = time.Minute * 10
+
+// Names of the routes for uploading media, which are allowed larger bodies, and
+// for downloading media.
+const (
+	pitReportMediaRoute = "pit-report-media"
+	commentMediaRoute   = "comment-media"
+	mediaRoute          = "media"
+)
+
+var (
+	mediaUploadRoutes   = map[string]bool{pitReportMediaRoute: true, commentMediaRoute: true}
+	mediaTransferRoutes = map[string]bool{pitReportMediaRoute: true, commentMediaRoute: true, mediaRoute: true}
+)
+
+// mediaExtensions maps the content types that can be uploaded to the extension
+// their blobs are stored with.
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"video/mp4":  ".mp4",
+	"video/webm": ".webm",
+}
+
+var (
+	errUnsupportedMedia = errors.New("media must be a JPEG, PNG, or GIF image or an MP4 or WebM video")
+	errMediaTooLarge    = errors.New("media is too large")
+)
+
+// mediaReader reads uploaded media, failing with errMediaTooLarge once more
+// than limit bytes are read.
+type mediaReader struct {
+	r     io.Reader
+	limit int64
+	n     int64 // bytes read so far
+	err   error // error reading the media, if any
+}
+
+func (m *mediaReader) Read(p []byte) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+
+	n, err := m.r.Read(p)
+	m.n += int64(n)
+	if m.n > m.limit {
+		m.err = errMediaTooLarge
+	} else if err != nil && err != io.EOF {
+		// the body is limited by LimitBody, so a failed read is almost always
+		// because the media was too large for any type
+		m.err = errMediaTooLarge
+	}
+
+	if m.err != nil {
+		return n, m.err
+	}
+	return n, err
+}
+
+// openMedia detects the content type of uploaded media from its first bytes and
+// checks that it is allowed. It returns a reader for the whole media that fails
+// if it is larger than the size limit for its type.
+func openMedia(body io.Reader, limits config.Media) (string, *mediaReader, error) {
+	// http.DetectContentType only considers the first 512 bytes
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, errMediaTooLarge
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if _, ok := mediaExtensions[contentType]; !ok {
+		return "", nil, errUnsupportedMedia
+	}
+
+	limit := limits.MaxImageBytes
+	if !isImage(contentType) {
+		limit = limits.MaxVideoBytes
+	}
+
+	return contentType, &mediaReader{r: io.MultiReader(bytes.NewReader(head), body), limit: limit}, nil
+}
+
+func isImage(contentType string) bool {
+	return contentType == "image/jpeg" || contentType == "image/png" || contentType == "image/gif"
+}
+
+// newBlobKey generates a random key for a blob in a realm.
+func newBlobKey(realmID int64, extension string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d/%s%s", realmID, hex.EncodeToString(b), extension), nil
+}
+
+// uploadMedia reads media from the request body, stores it and its thumbnail,
+// and records it. It responds with the recorded media. Videos are streamed to
+// the blob store rather than read into memory.
+func (s *Server) uploadMedia(w http.ResponseWriter, r *http.Request, m store.Media) {
+	contentType, media, err := openMedia(r.Body, s.Media)
+	if err == errUnsupportedMedia {
+		ihttp.Respond(w, err, http.StatusUnsupportedMediaType)
+		return
+	} else if err == errMediaTooLarge {
+		ihttp.Respond(w, err, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var data io.Reader = media
+	var thumbnailData []byte
+	if isImage(contentType) {
+		// images are small enough to read into memory to generate thumbnails
+		imageData, err := ioutil.ReadAll(media)
+		if err != nil {
+			ihttp.Respond(w, err, http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if thumbnailData, err = thumbnail.Generate(imageData, thumbnailSize); err == thumbnail.ErrTooLarge {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			ihttp.Respond(w, errors.New("unable to decode image"), http.StatusUnprocessableEntity)
+			return
+		}
+		data = bytes.NewReader(imageData)
+	}
+
+	m.ContentType = contentType
+	if m.BlobKey, err = newBlobKey(*m.RealmID, mediaExtensions[contentType]); err != nil {
+		ihttp.Error(w, http.StatusInternalServerError)
+		s.Logger.WithError(err).Error("generating blob key")
+		return
+	}
+
+	if err := s.Blobs.Put(r.Context(), m.BlobKey, data, contentType); media.err != nil {
+		ihttp.Respond(w, media.err, http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		ihttp.Error(w, http.StatusInternalServerError)
+		s.Logger.WithError(err).Error("storing media")
+		return
+	}
+	m.Size = media.n
+
+	if thumbnailData != nil {
+		thumbnailKey := m.BlobKey + ".thumb.jpg"
+		if err := s.Blobs.Put(r.Context(), thumbnailKey, bytes.NewReader(thumbnailData), "image/jpeg"); err != nil {
+			s.deleteBlobs(r.Context(), m.BlobKey)
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("storing thumbnail")
+			return
+		}
+		m.ThumbnailKey = &thumbnailKey
+		m.HasThumbnail = true
+	}
+
+	if m.ID, err = s.Store.CreateMedia(r.Context(), m); err != nil {
+		s.deleteBlobs(r.Context(), m.BlobKey, m.ThumbnailKey)
+		ihttp.Error(w, http.StatusInternalServerError)
+		s.Logger.WithError(err).Error("creating media")
+		return
+	}
+
+	ihttp.Respond(w, m, http.StatusCreated)
+}
+
+// deleteBlobs deletes the blobs for media, logging any errors since there is
+// nothing else to be done about them.
+func (s *Server) deleteBlobs(ctx context.Context, key string, thumbnailKey ...*string) {
+	keys := []string{key}
+	for _, k := range thumbnailKey {
+		if k != nil {
+			keys = append(keys, *k)
+		}
+	}
+
+	for _, k := range keys {
+		if err := s.Blobs.Delete(ctx, k); err != nil {
+			s.Logger.WithError(err).WithField("key", k).Error("deleting media blob")
+		}
+	}
+}
+
+// uploadPitReportMediaHandler returns a handler to attach a photo or video to
+// the current user's pit report for a team at an event.
+func (s *Server) uploadPitReportMediaHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventKey, teamKey := vars["eventKey"], vars["teamKey"]
+
+		subject, err := ihttp.GetSubject(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		pitReportID, err := s.Store.GetPitReportID(r.Context(), eventKey, teamKey, subject)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Respond(w, errors.New("submit a pit report before attaching media"), http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving pit report")
+			return
+		}
+
+		s.uploadMedia(w, r, store.Media{
+			UploaderID:  &subject,
+			RealmID:     &realmID,
+			EventKey:    eventKey,
+			TeamKey:     teamKey,
+			PitReportID: &pitReportID,
+		})
+	}
+}
+
+// uploadCommentMediaHandler returns a handler to attach a photo or video to the
+// current user's comment on a team in a match.
+func (s *Server) uploadCommentMediaHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventKey, teamKey := vars["eventKey"], vars["teamKey"]
+
+		// Add eventKey as prefix to matchKey so that matchKey is globally
+		// unique and consistent with TBA match keys.
+		matchKey := fmt.Sprintf("%s_%s", eventKey, vars["matchKey"])
+
+		subject, err := ihttp.GetSubject(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		commentID, err := s.Store.GetCommentID(r.Context(), matchKey, teamKey, subject)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Respond(w, errors.New("submit a comment before attaching media"), http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving comment")
+			return
+		}
+
+		s.uploadMedia(w, r, store.Media{
+			UploaderID: &subject,
+			RealmID:    &realmID,
+			EventKey:   eventKey,
+			TeamKey:    teamKey,
+			CommentID:  &commentID,
+		})
+	}
+}
+
+// getEventTeamMediaHandler returns a handler to list the media attached to pit
+// reports and comments for a team at an event.
+func (s *Server) getEventTeamMediaHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventKey, teamKey := vars["eventKey"], vars["teamKey"]
+
+		var realmID *int64
+		userRealmID, err := ihttp.GetRealmID(r)
+		if err == nil {
+			realmID = &userRealmID
+		}
+
+		media, err := s.Store.GetEventTeamMediaForRealm(r.Context(), eventKey, teamKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving media")
+			return
+		}
+
+		// this is a hack since match keys are stored weirdly right now
+		for i, m := range media {
+			if m.MatchKey != nil {
+				matchKey := trimMatchKey(*m.MatchKey)
+				media[i].MatchKey = &matchKey
+			}
+		}
+
+		ihttp.Respond(w, media, http.StatusOK)
+	}
+}
+
+// getMediaHandler returns a handler to download media, or its thumbnail if
+// thumbnail is true.
+func (s *Server) getMediaHandler(thumbnail bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		var realmID *int64
+		userRealmID, err := ihttp.GetRealmID(r)
+		if err == nil {
+			realmID = &userRealmID
+		}
+
+		m, err := s.Store.GetMediaForRealm(r.Context(), id, realmID)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving media")
+			return
+		}
+
+		key, contentType := m.BlobKey, m.ContentType
+		if thumbnail {
+			if m.ThumbnailKey == nil {
+				ihttp.Error(w, http.StatusNotFound)
+				return
+			}
+			key, contentType = *m.ThumbnailKey, "image/jpeg"
+		}
+
+		data, info, err := s.Blobs.Get(r.Context(), key)
+		if err == blob.ErrNotFound {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving media blob")
+			return
+		}
+		defer data.Close()
+
+		// ServeContent handles range requests, which videos need for seeking
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		http.ServeContent(w, r, "", info.ModTime, data)
+	}
+}
+
+// deleteMediaHandler returns a handler to delete media. Uploaders can delete
+// their own media, and moderators can delete any media in their realm.
+func (s *Server) deleteMediaHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		subject, err := ihttp.GetSubject(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		m, err := s.Store.GetMediaForRealm(r.Context(), id, &realmID)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving media")
+			return
+		}
+
+		if m.RealmID == nil || *m.RealmID != realmID || !canModify(ihttp.GetPermissions(r), subject, m.UploaderID) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		if err := s.Store.DeleteMedia(r.Context(), id); errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("deleting media")
+			return
+		}
+
+		s.deleteBlobs(r.Context(), m.BlobKey, m.ThumbnailKey)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/config"
+)
+
+func TestOpenMedia(t *testing.T) {
+	limits := config.Media{MaxImageBytes: 64, MaxVideoBytes: 128}
+
+	png := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), make([]byte, 16)...)
+	webm := append([]byte("\x1A\x45\xDF\xA3"), make([]byte, 16)...)
+
+	testCases := []struct {
+		name                string
+		data                []byte
+		expectedContentType string
+		expectedErr         error
+	}{
+		{
+			name:                "image",
+			data:                png,
+			expectedContentType: "image/png",
+		},
+		{
+			name:                "video",
+			data:                webm,
+			expectedContentType: "video/webm",
+		},
+		{
+			name:        "image too large",
+			data:        append(png, make([]byte, 64)...),
+			expectedErr: errMediaTooLarge,
+		},
+		{
+			name:                "video larger than image limit",
+			data:                append(webm, make([]byte, 64)...),
+			expectedContentType: "video/webm",
+		},
+		{
+			name:        "video too large",
+			data:        append(webm, make([]byte, 128)...),
+			expectedErr: errMediaTooLarge,
+		},
+		{
+			name:        "unsupported",
+			data:        bytes.Repeat([]byte("text"), 4),
+			expectedErr: errUnsupportedMedia,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			contentType, media, err := openMedia(bytes.NewReader(tt.data), limits)
+			var data []byte
+			if err == nil {
+				data, err = ioutil.ReadAll(media)
+			}
+
+			if err != tt.expectedErr {
+				t.Fatalf("expected error %v but got: %v", tt.expectedErr, err)
+			} else if err != nil {
+				return
+			}
+
+			if contentType != tt.expectedContentType {
+				t.Errorf("expected content type %q but got %q", tt.expectedContentType, contentType)
+			}
+
+			if !bytes.Equal(data, tt.data) {
+				t.Errorf("expected to read all of the media")
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// newInviteCode generates a new random realm invite code. Codes are URL safe so
+// they can be shared as part of a link.
+func newInviteCode() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validateInviteExpiry checks that an invite expiring at expiresAt hasn't already
+// expired.
+func validateInviteExpiry(expiresAt *time.Time, now time.Time) error {
+	if expiresAt != nil && !expiresAt.After(now) {
+		return errors.New("invites can't expire in the past")
+	}
+
+	return nil
+}
+
+// getRealmInvitesHandler returns a handler to get the invites to the current
+// realm.
+func (s *Server) getRealmInvitesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		invites, err := s.Store.GetRealmInvites(r.Context(), realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving realm invites")
+			return
+		}
+
+		ihttp.Respond(w, invites, http.StatusOK)
+	}
+}
+
+// createRealmInviteHandler returns a handler to create an invite code for the
+// current realm. Users can't create invites for roles with permissions they
+// don't have.
+func (s *Server) createRealmInviteHandler() http.HandlerFunc {
+	type requestInvite struct {
+		Roles     []string   `json:"roles"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ri requestInvite
+		if err := json.NewDecoder(r.Body).Decode(&ri); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validateInviteExpiry(ri.ExpiresAt, time.Now()); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		creatorID, err := ihttp.GetSubject(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusUnauthorized)
+			return
+		}
+
+		roles := uniqueStrings(ri.Roles)
+
+		if !s.checkGrantableRoles(w, r, ihttp.GetPermissions(r), realmID, roles) {
+			return
+		}
+
+		code, err := newInviteCode()
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("generating realm invite code")
+			return
+		}
+
+		invite := store.RealmInvite{
+			RealmID:   realmID,
+			Code:      code,
+			Roles:     roles,
+			ExpiresAt: ri.ExpiresAt,
+			CreatorID: &creatorID,
+		}
+
+		invite.ID, invite.CreatedAt, err = s.Store.CreateRealmInvite(r.Context(), invite)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("creating realm invite")
+			return
+		}
+
+		ihttp.Respond(w, invite, http.StatusCreated)
+	}
+}
+
+// deleteRealmInviteHandler returns a handler to revoke an invite to the current
+// realm.
+func (s *Server) deleteRealmInviteHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.DeleteRealmInvite(r.Context(), realmID, id)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("deleting realm invite")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getMembershipRequestsHandler returns a handler to get the users waiting for
+// approval to join the current realm.
+func (s *Server) getMembershipRequestsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		users, err := s.Store.GetPendingUsers(r.Context(), realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving pending users")
+			return
+		}
+
+		ihttp.Respond(w, users, http.StatusOK)
+	}
+}
+
+// approveMembershipRequestHandler returns a handler to approve a pending user's
+// request to join the current realm. The user is given the requested roles, or
+// the scout role if none are given.
+func (s *Server) approveMembershipRequestHandler() http.HandlerFunc {
+	type approval struct {
+		Roles []string `json:"roles"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		var a approval
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+				ihttp.Error(w, http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		roles := uniqueStrings(a.Roles)
+		if a.Roles == nil {
+			roles = []string{store.RoleScout}
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		if !s.checkGrantableRoles(w, r, ihttp.GetPermissions(r), realmID, roles) {
+			return
+		}
+
+		err = s.Store.ApproveMembershipRequest(r.Context(), realmID, userID, roles)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("approving membership request")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// rejectMembershipRequestHandler returns a handler to reject a pending user's
+// request to join the current realm. Users who aren't members of any other realm
+// are deleted.
+func (s *Server) rejectMembershipRequestHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.ParseInt(mux.Vars(r)["userId"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.RejectMembershipRequest(r.Context(), realmID, userID)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("rejecting membership request")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getUserRealmsHandler returns a handler to get the realms a user is a member of.
+// Users can only get their own realms unless they are a super admin.
+func (s *Server) getUserRealmsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		subjectID, err := ihttp.GetSubject(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		if id != subjectID && !ihttp.GetPermissions(r).Has(store.PermissionManageAll) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		memberships, err := s.Store.GetUserRealms(r.Context(), id)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving user realms")
+			return
+		}
+
+		ihttp.Respond(w, memberships, http.StatusOK)
+	}
+}
+
+// joinRealmHandler returns a handler for users to join another realm. Users
+// joining with an invite get the invite's realm and roles, otherwise they ask to
+// join the realm and wait for approval.
+func (s *Server) joinRealmHandler() http.HandlerFunc {
+	type joinRequest struct {
+		RealmID    int64   `json:"realmId" validate:"required_without=InviteCode"`
+		InviteCode *string `json:"inviteCode"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		subjectID, err := ihttp.GetSubject(r)
+		if err != nil || id != subjectID {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		var jr joinRequest
+		if err := json.NewDecoder(r.Body).Decode(&jr); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validator.New().Struct(jr); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if jr.InviteCode != nil {
+			var invite store.RealmInvite
+			invite, err = s.Store.GetRealmInviteByCode(r.Context(), *jr.InviteCode)
+			if errors.Is(err, store.ErrNoResults{}) {
+				ihttp.Respond(w, errors.New("invite code is invalid or expired"), http.StatusUnprocessableEntity)
+				return
+			} else if err != nil {
+				ihttp.Error(w, http.StatusInternalServerError)
+				s.Logger.WithError(err).Error("retrieving realm invite")
+				return
+			}
+
+			jr.RealmID = invite.RealmID
+			err = s.Store.JoinRealm(r.Context(), id, invite.RealmID, invite.Roles, false)
+		} else {
+			err = s.Store.JoinRealm(r.Context(), id, jr.RealmID, nil, true)
+		}
+
+		if errors.Is(err, store.ErrExists{}) {
+			ihttp.Error(w, http.StatusConflict)
+			return
+		} else if errors.Is(err, store.ErrFKeyViolation{}) || errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("joining realm")
+			return
+		}
+
+		memberships, err := s.Store.GetUserRealms(r.Context(), id)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving user realms")
+			return
+		}
+
+		for _, m := range memberships {
+			if m.RealmID == jr.RealmID {
+				ihttp.Respond(w, m, http.StatusCreated)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// leaveRealmHandler returns a handler for users to leave a realm. Users can't
+// leave the only realm they are a member of, they should delete their account
+// instead.
+func (s *Server) leaveRealmHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := strconv.ParseInt(mux.Vars(r)["realmId"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		subjectID, err := ihttp.GetSubject(r)
+		if err != nil || id != subjectID {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		memberships, err := s.Store.GetUserRealms(r.Context(), id)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving user realms")
+			return
+		}
+
+		if len(memberships) == 1 && memberships[0].RealmID == realmID {
+			ihttp.Respond(w, errors.New("users can't leave their only realm"), http.StatusUnprocessableEntity)
+			return
+		}
+
+		err = s.Store.DeleteUserByIDRealm(r.Context(), id, realmID)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("leaving realm")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
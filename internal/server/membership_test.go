@@ -0,0 +1,67 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateInviteExpiry(t *testing.T) {
+	now := time.Date(2019, 3, 2, 10, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	testCases := []struct {
+		name        string
+		expiresAt   *time.Time
+		expectError bool
+	}{
+		{
+			name: "never expires",
+		},
+		{
+			name:      "expires in the future",
+			expiresAt: &future,
+		},
+		{
+			name:        "expires now",
+			expiresAt:   &now,
+			expectError: true,
+		},
+		{
+			name:        "expired",
+			expiresAt:   &past,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateInviteExpiry(tt.expiresAt, now)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+		})
+	}
+}
+
+func TestNewInviteCode(t *testing.T) {
+	a, err := newInviteCode()
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	b, err := newInviteCode()
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	if len(a) != 16 {
+		t.Errorf("expected invite code to be 16 characters, got %d", len(a))
+	}
+
+	if a == b {
+		t.Errorf("expected invite codes to be different, both were %q", a)
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+)
+
+// exclusion is the body for setting whether a report or comment is excluded.
+type exclusion struct {
+	Excluded *bool `json:"excluded"`
+}
+
+// deleteReportHandler returns a handler to delete a report.
+func (s *Server) deleteReportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, ok := parseReportTarget(w, r)
+		if !ok {
+			return
+		}
+
+		reporterID, err := s.Store.GetReportReporterID(r.Context(), target)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting report reporter")
+			return
+		}
+
+		subject, err := ihttp.GetSubject(r)
+		if err != nil || !canModify(ihttp.GetPermissions(r), subject, reporterID) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.DeleteReport(r.Context(), target)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("deleting report")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// patchReportHandler returns a handler for admins to set whether a report is
+// excluded from stats.
+func (s *Server) patchReportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var e exclusion
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil || e.Excluded == nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		target, ok := parseReportTarget(w, r)
+		if !ok {
+			return
+		}
+
+		err := s.Store.SetReportExcluded(r.Context(), target, *e.Excluded)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("setting report excluded")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// deleteCommentHandler returns a handler to delete a comment.
+func (s *Server) deleteCommentHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, ok := parseReportTarget(w, r)
+		if !ok {
+			return
+		}
+
+		reporterID, err := s.Store.GetCommentReporterID(r.Context(), target)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting comment reporter")
+			return
+		}
+
+		subject, err := ihttp.GetSubject(r)
+		if err != nil || !canModify(ihttp.GetPermissions(r), subject, reporterID) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.DeleteComment(r.Context(), target)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("deleting comment")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// patchCommentHandler returns a handler for admins to set whether a comment is
+// excluded.
+func (s *Server) patchCommentHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var e exclusion
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil || e.Excluded == nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		target, ok := parseReportTarget(w, r)
+		if !ok {
+			return
+		}
+
+		err := s.Store.SetCommentExcluded(r.Context(), target, *e.Excluded)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("setting comment excluded")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,389 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/oidc"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// oidcStateDuration is how long users have to log in with the OpenID Connect
+// provider.
+const oidcStateDuration = time.Minute * 10
+
+// oidcStateSignature signs the nonce and expiry of a state so the server doesn't
+// need to store it.
+func oidcStateSignature(nonce, expires, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte("oidc-state:" + nonce + "." + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newOIDCState returns a state to send users to the OpenID Connect provider with,
+// and the nonce to include in their ID token.
+func newOIDCState(now time.Time, secret string) (state, nonce string, err error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	nonce = base64.RawURLEncoding.EncodeToString(b)
+	expires := strconv.FormatInt(now.Add(oidcStateDuration).Unix(), 10)
+
+	return nonce + "." + expires + "." + oidcStateSignature(nonce, expires, secret), nonce, nil
+}
+
+// checkOIDCState checks that a state was created by newOIDCState and hasn't
+// expired, and returns its nonce.
+func checkOIDCState(state string, now time.Time, secret string) (string, error) {
+	parts := strings.Split(state, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed state")
+	}
+
+	nonce, expires, signature := parts[0], parts[1], parts[2]
+	if !hmac.Equal([]byte(signature), []byte(oidcStateSignature(nonce, expires, secret))) {
+		return "", errors.New("invalid state signature")
+	}
+
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() >= exp {
+		return "", errors.New("state is expired")
+	}
+
+	return nonce, nil
+}
+
+// identityUsername returns an unused username for a new user logging in with an
+// identity, based on their email address.
+func (s *Server) identityUsername(ctx context.Context, identity oidc.Identity) (string, error) {
+	base := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return -1
+	}, strings.Split(identity.Email, "@")[0])
+
+	if len(base) > 26 {
+		base = base[:26]
+	}
+	for len(base) < 4 {
+		base += "0"
+	}
+
+	username := base
+	for i := 0; i < 10; i++ {
+		err := s.Store.CheckSimilarUsernameExists(ctx, username, nil)
+		if err == nil {
+			return username, nil
+		} else if !errors.Is(err, store.ErrExists{}) {
+			return "", err
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+		if err != nil {
+			return "", err
+		}
+		username = fmt.Sprintf("%s%d", base, n)
+	}
+
+	return "", errors.New("unable to find unused username")
+}
+
+// identityUser returns the user linked to an identity. If no user is linked
+// and the identity has a verified email address in one of the realm domains,
+// a user is created in the domain's realm. Otherwise ErrNoResults is returned.
+func (s *Server) identityUser(ctx context.Context, identity oidc.Identity) (store.User, error) {
+	userID, err := s.Store.GetIdentityUserID(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return s.Store.GetUserByID(ctx, userID)
+	} else if !errors.Is(err, store.ErrNoResults{}) {
+		return store.User{}, err
+	}
+
+	email := strings.ToLower(identity.Email)
+	at := strings.LastIndex(email, "@")
+	if !identity.EmailVerified || at == -1 {
+		return store.User{}, err
+	}
+
+	realmID, ok := s.OIDCRealmDomains[email[at+1:]]
+	if !ok {
+		return store.User{}, err
+	}
+
+	username, err := s.identityUsername(ctx, identity)
+	if err != nil {
+		return store.User{}, fmt.Errorf("unable to choose username: %w", err)
+	}
+
+	firstName := identity.FirstName
+	if firstName == "" {
+		firstName = username
+	}
+
+	// users who only log in with the provider have no password
+	u := store.User{Username: username, RealmID: realmID, FirstName: firstName, LastName: identity.LastName}
+	userID, err = s.Store.CreateIdentityUser(ctx, u, store.Identity{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		Email:   identity.Email,
+	})
+	if err != nil {
+		return store.User{}, fmt.Errorf("unable to create user: %w", err)
+	}
+
+	return s.Store.GetUserByID(ctx, userID)
+}
+
+// oidcAuthorizeHandler returns a handler to get the URL to send users to so they
+// can log in with the OpenID Connect provider. The frontend should keep the
+// state and check that the provider sends it back.
+func (s *Server) oidcAuthorizeHandler() http.HandlerFunc {
+	type authorizeResponse struct {
+		URL   string `json:"url"`
+		State string `json:"state"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.OIDC == nil {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		}
+
+		state, nonce, err := newOIDCState(time.Now(), s.JWTSecret)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("generating oidc state")
+			return
+		}
+
+		url, err := s.OIDC.AuthURL(r.Context(), state, nonce)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting oidc auth url")
+			return
+		}
+
+		ihttp.Respond(w, authorizeResponse{URL: url, State: state}, http.StatusOK)
+	}
+}
+
+type oidcCallback struct {
+	Code       string `json:"code" validate:"required"`
+	State      string `json:"state" validate:"required"`
+	DeviceName string `json:"deviceName"`
+}
+
+// exchangeOIDCCallback exchanges the authorization code the OpenID Connect
+// provider sent a user back with for their identity. If it fails, it responds
+// with an error and returns false.
+func (s *Server) exchangeOIDCCallback(w http.ResponseWriter, r *http.Request) (oidcCallback, oidc.Identity, bool) {
+	if s.OIDC == nil {
+		ihttp.Error(w, http.StatusNotFound)
+		return oidcCallback{}, oidc.Identity{}, false
+	}
+
+	var cb oidcCallback
+	if err := json.NewDecoder(r.Body).Decode(&cb); err != nil {
+		ihttp.Error(w, http.StatusUnprocessableEntity)
+		return cb, oidc.Identity{}, false
+	}
+
+	if err := validator.New().Struct(cb); err != nil {
+		ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+		return cb, oidc.Identity{}, false
+	}
+
+	nonce, err := checkOIDCState(cb.State, time.Now(), s.JWTSecret)
+	if err != nil {
+		ihttp.Respond(w, err, http.StatusUnauthorized)
+		return cb, oidc.Identity{}, false
+	}
+
+	identity, err := s.OIDC.Exchange(r.Context(), cb.Code, nonce)
+	if errors.Is(err, oidc.ErrInvalid) {
+		s.Logger.WithError(err).WithField("ip", remoteIP(r)).Warn("failed oidc login")
+		ihttp.Error(w, http.StatusUnauthorized)
+		return cb, oidc.Identity{}, false
+	} else if err != nil {
+		ihttp.Error(w, http.StatusInternalServerError)
+		s.Logger.WithError(err).Error("exchanging oidc authorization code")
+		return cb, oidc.Identity{}, false
+	}
+
+	return cb, identity, true
+}
+
+// oidcAuthenticateHandler returns a handler to log in with the authorization
+// code the OpenID Connect provider sent a user back with. It responds with the
+// same tokens as logging in with a password.
+func (s *Server) oidcAuthenticateHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cb, identity, ok := s.exchangeOIDCCallback(w, r)
+		if !ok {
+			return
+		}
+
+		user, err := s.identityUser(r.Context(), identity)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Respond(w, errors.New("no user is linked to this account"), http.StatusForbidden)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving oidc user")
+			return
+		}
+
+		session := newSession(r, user.ID, cb.DeviceName, time.Now().Add(refreshTokenDuration))
+		session.ID, err = s.Store.CreateSession(r.Context(), session)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("creating session")
+			return
+		}
+
+		tokens, err := generateTokens(user, session, time.Now(), s.Keys)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("generating jwt signed strings")
+			return
+		}
+
+		ihttp.Respond(w, tokens, http.StatusOK)
+	}
+}
+
+// checkIdentityAccess checks that the current user can manage the identities of
+// a user. Users can manage their own identities, and users who can manage all
+// realms can manage anyone's. If not, it responds with an error and returns
+// false.
+func checkIdentityAccess(w http.ResponseWriter, r *http.Request, userID int64) bool {
+	subjectID, err := ihttp.GetSubject(r)
+	if err != nil || (subjectID != userID && !ihttp.GetPermissions(r).Has(store.PermissionManageAll)) {
+		ihttp.Error(w, http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// getUserIdentitiesHandler returns a handler to get the OpenID Connect accounts
+// linked to a user.
+func (s *Server) getUserIdentitiesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		if !checkIdentityAccess(w, r, id) {
+			return
+		}
+
+		identities, err := s.Store.GetUserIdentities(r.Context(), id)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving identities")
+			return
+		}
+
+		ihttp.Respond(w, identities, http.StatusOK)
+	}
+}
+
+// linkUserIdentityHandler returns a handler to link the OpenID Connect account
+// a user logged in to the provider with to their user, so they can log in with
+// it.
+func (s *Server) linkUserIdentityHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		if subjectID, err := ihttp.GetSubject(r); err != nil || subjectID != id {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		_, identity, ok := s.exchangeOIDCCallback(w, r)
+		if !ok {
+			return
+		}
+
+		i := store.Identity{
+			UserID:  id,
+			Issuer:  identity.Issuer,
+			Subject: identity.Subject,
+			Email:   identity.Email,
+		}
+
+		i.ID, err = s.Store.CreateIdentity(r.Context(), i)
+		if errors.Is(err, store.ErrExists{}) {
+			ihttp.Respond(w, errors.New("account is already linked to a user"), http.StatusConflict)
+			return
+		} else if errors.Is(err, store.ErrFKeyViolation{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("linking identity")
+			return
+		}
+
+		ihttp.Respond(w, i, http.StatusCreated)
+	}
+}
+
+// unlinkUserIdentityHandler returns a handler to unlink an OpenID Connect
+// account from a user.
+func (s *Server) unlinkUserIdentityHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		identityID, err := strconv.ParseInt(mux.Vars(r)["identityId"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		if !checkIdentityAccess(w, r, id) {
+			return
+		}
+
+		err = s.Store.DeleteIdentity(r.Context(), id, identityID)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("unlinking identity")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
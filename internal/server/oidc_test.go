@@ -0,0 +1,49 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOIDCState(t *testing.T) {
+	now := time.Unix(1558050528, 0)
+
+	state, nonce, err := newOIDCState(now, "i-am-secret")
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	parts := strings.Split(state, ".")
+	tampered := "other." + parts[1] + "." + parts[2]
+
+	testCases := []struct {
+		name        string
+		state       string
+		now         time.Time
+		secret      string
+		expectError bool
+	}{
+		{name: "valid state", state: state, now: now, secret: "i-am-secret"},
+		{name: "expired", state: state, now: now.Add(oidcStateDuration), secret: "i-am-secret", expectError: true},
+		{name: "wrong secret", state: state, now: now, secret: "not-secret", expectError: true},
+		{name: "tampered nonce", state: tampered, now: now, secret: "i-am-secret", expectError: true},
+		{name: "malformed", state: "foo", now: now, secret: "i-am-secret", expectError: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			actualNonce, err := checkOIDCState(tt.state, tt.now, tt.secret)
+
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+
+			if !tt.expectError && actualNonce != nonce {
+				t.Errorf("expected nonce %q but got %q", nonce, actualNonce)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/notify"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// passwordResetDuration is how long password reset tokens can be used for.
+const passwordResetDuration = time.Hour * 24
+
+// checkPassword checks that a password follows a password policy.
+func checkPassword(policy store.PasswordPolicy, password string) error {
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		return fmt.Errorf("password must be at least %d characters", policy.MinLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+
+	switch {
+	case policy.RequireMixedCase && !(upper && lower):
+		return errors.New("password must have uppercase and lowercase letters")
+	case policy.RequireDigit && !digit:
+		return errors.New("password must have a digit")
+	case policy.RequireSymbol && !symbol:
+		return errors.New("password must have a symbol")
+	}
+
+	return nil
+}
+
+// newPasswordResetToken generates a new random password reset token and returns
+// it along with the hash that should be stored.
+func newPasswordResetToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashPasswordResetToken(token), nil
+}
+
+func hashPasswordResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkCredentialsManageable checks that a user with the given permissions, who
+// can't manage all users, can change the username or password of another user.
+// They can only change the credentials of users who are only members of their
+// realm, and don't have permissions they don't have, so they can't take over
+// accounts in other realms. If not, it responds with an error and returns false.
+func (s *Server) checkCredentialsManageable(w http.ResponseWriter, r *http.Request, permissions store.Permissions, user store.User) bool {
+	if !canGrant(permissions, user.Permissions) {
+		ihttp.Respond(w, errors.New("you can't change the credentials of users with permissions you don't have"), http.StatusForbidden)
+		return false
+	}
+
+	memberships, err := s.Store.GetUserRealms(r.Context(), user.ID)
+	if err != nil {
+		s.Logger.WithError(err).Error("retrieving user realms")
+		ihttp.Error(w, http.StatusInternalServerError)
+		return false
+	}
+
+	if len(memberships) != 1 {
+		ihttp.Respond(w, errors.New("you can't change the credentials of users in other realms"), http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// createPasswordResetHandler returns a handler to create a one-time token that
+// lets a user set a new password. Users who can manage users can reset the
+// passwords of users who are only members of their realm, and don't have
+// permissions they don't have. If a notifier is configured the token is
+// delivered to the user, otherwise it is returned.
+func (s *Server) createPasswordResetHandler() http.HandlerFunc {
+	type createdPasswordReset struct {
+		store.PasswordReset
+		Token string `json:"token,omitempty"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		creatorID, err := ihttp.GetSubject(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		permissions := ihttp.GetPermissions(r)
+
+		var user store.User
+		if permissions.Has(store.PermissionManageAll) {
+			user, err = s.Store.GetUserByID(r.Context(), id)
+		} else {
+			var realmID int64
+			realmID, err = ihttp.GetRealmID(r)
+			if err != nil {
+				ihttp.Error(w, http.StatusForbidden)
+				return
+			}
+
+			user, err = s.Store.GetUserInRealm(r.Context(), id, realmID)
+		}
+
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("retrieving user")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		if !permissions.Has(store.PermissionManageAll) && !s.checkCredentialsManageable(w, r, permissions, user) {
+			return
+		}
+
+		token, hash, err := newPasswordResetToken()
+		if err != nil {
+			s.Logger.WithError(err).Error("generating password reset token")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		reset := store.PasswordReset{
+			UserID:    id,
+			CreatorID: &creatorID,
+			TokenHash: hash,
+			ExpiresAt: time.Now().Add(passwordResetDuration),
+		}
+
+		reset.ID, reset.CreatedAt, err = s.Store.CreatePasswordReset(r.Context(), reset)
+		if errors.Is(err, store.ErrFKeyViolation{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("creating password reset")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		s.Logger.WithFields(logrus.Fields{
+			"username":  user.Username,
+			"createdBy": creatorID,
+		}).Warn("created password reset")
+
+		if s.Notifier == nil {
+			ihttp.Respond(w, createdPasswordReset{PasswordReset: reset, Token: token}, http.StatusCreated)
+			return
+		}
+
+		err = s.Notifier.PasswordReset(r.Context(), notify.PasswordReset{
+			UserID:    user.ID,
+			Username:  user.Username,
+			FirstName: user.FirstName,
+			LastName:  user.LastName,
+			Token:     token,
+			ExpiresAt: reset.ExpiresAt,
+		})
+		if err != nil {
+			s.Logger.WithError(err).Error("delivering password reset")
+			ihttp.Error(w, http.StatusBadGateway)
+			return
+		}
+
+		ihttp.Respond(w, createdPasswordReset{PasswordReset: reset}, http.StatusCreated)
+	}
+}
+
+// resetPasswordHandler returns a handler to set a user's password with a
+// password reset token. The user is logged out of every device.
+func (s *Server) resetPasswordHandler() http.HandlerFunc {
+	type resetPasswordRequest struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"lte=128"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rp resetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&rp); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validator.New().Struct(rp); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		reset, err := s.Store.GetPasswordReset(r.Context(), hashPasswordResetToken(rp.Token))
+		if errors.Is(err, store.ErrNoResults{}) {
+			s.Logger.WithField("ip", remoteIP(r)).Warn("failed password reset")
+			ihttp.Error(w, http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("retrieving password reset")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		policy, err := s.Store.GetUserPasswordPolicy(r.Context(), reset.UserID)
+		if err != nil {
+			s.Logger.WithError(err).Error("retrieving password policy")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		if err := checkPassword(policy, rp.Password); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(rp.Password), bcryptCost)
+		if err != nil {
+			s.Logger.WithError(err).Error("hashing user password")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		err = s.Store.UsePasswordReset(r.Context(), reset.ID, string(hashedPassword))
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("resetting password")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		user, err := s.Store.GetUserByID(r.Context(), reset.UserID)
+		if err != nil {
+			s.Logger.WithError(err).Error("retrieving user")
+		} else if err := s.Lockout.Unlock(r.Context(), user.Username); err != nil {
+			s.Logger.WithError(err).Error("unlocking user")
+		}
+
+		s.Logger.WithField("userId", reset.UserID).Warn("reset password")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getPasswordPolicyHandler returns a handler to get the password policy of a
+// realm.
+func (s *Server) getPasswordPolicyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		if _, err := s.Store.GetRealm(r.Context(), id); errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("retrieving realm")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		policy, err := s.Store.GetPasswordPolicy(r.Context(), id)
+		if err != nil {
+			s.Logger.WithError(err).Error("retrieving password policy")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		ihttp.Respond(w, policy, http.StatusOK)
+	}
+}
+
+// putPasswordPolicyHandler returns a handler to set the password policy of a
+// realm. Existing passwords aren't checked until they are changed.
+func (s *Server) putPasswordPolicyHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if !ihttp.GetPermissions(r).Has(store.PermissionManageAll) && (err != nil || realmID != id) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		var policy store.PasswordPolicy
+		if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validator.New().Struct(policy); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		policy.RealmID = id
+
+		err = s.Store.SetPasswordPolicy(r.Context(), policy)
+		if errors.Is(err, store.ErrFKeyViolation{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("setting password policy")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
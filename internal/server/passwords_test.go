@@ -0,0 +1,58 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+)
+
+func TestCheckPassword(t *testing.T) {
+	strict := store.PasswordPolicy{MinLength: 10, RequireMixedCase: true, RequireDigit: true, RequireSymbol: true}
+
+	testCases := []struct {
+		name        string
+		policy      store.PasswordPolicy
+		password    string
+		expectError bool
+	}{
+		{name: "default policy", policy: store.DefaultPasswordPolicy, password: "password"},
+		{name: "too short", policy: store.DefaultPasswordPolicy, password: "passwor", expectError: true},
+		{name: "multibyte characters count once", policy: store.DefaultPasswordPolicy, password: "pässwör", expectError: true},
+		{name: "strict policy", policy: strict, password: "Correct-Horse-1"},
+		{name: "missing uppercase", policy: strict, password: "correct-horse-1", expectError: true},
+		{name: "missing digit", policy: strict, password: "Correct-Horse-!", expectError: true},
+		{name: "missing symbol", policy: strict, password: "CorrectHorse12", expectError: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPassword(tt.policy, tt.password)
+
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+		})
+	}
+}
+
+func TestPasswordResetToken(t *testing.T) {
+	token, hash, err := newPasswordResetToken()
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	if hashPasswordResetToken(token) != hash {
+		t.Errorf("expected hash of token to match returned hash")
+	}
+
+	other, _, err := newPasswordResetToken()
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	if token == other {
+		t.Errorf("expected tokens to be random")
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+)
+
+// validatePitReport checks that every stat in a pit report is a field in the pit
+// schema, at most once, with a value of the field's type. Fields may be left out.
+func validatePitReport(fields store.SchemaFields, data store.PitReportData) error {
+	schemaFields := make(map[string]store.SchemaField)
+	for _, field := range fields {
+		schemaFields[field.Name] = field
+	}
+
+	seen := make(map[string]bool)
+	for _, stat := range data {
+		field, ok := schemaFields[stat.Name]
+		if !ok {
+			return fmt.Errorf("%q is not in the pit schema", stat.Name)
+		}
+		if seen[stat.Name] {
+			return fmt.Errorf("duplicate pit report field %q", stat.Name)
+		}
+		seen[stat.Name] = true
+
+		switch value := stat.Value.(type) {
+		case float64:
+			if field.Type != "number" {
+				return fmt.Errorf("%q must be a %s", stat.Name, field.Type)
+			}
+		case bool:
+			if field.Type != "boolean" {
+				return fmt.Errorf("%q must be a %s", stat.Name, field.Type)
+			}
+		case string:
+			if field.Type != "string" {
+				return fmt.Errorf("%q must be a %s", stat.Name, field.Type)
+			}
+			if len(field.Options) > 0 && !contains(field.Options, value) {
+				return fmt.Errorf("%q must be one of %v", stat.Name, field.Options)
+			}
+		default:
+			return fmt.Errorf("%q must be a %s", stat.Name, field.Type)
+		}
+	}
+
+	return nil
+}
+
+// getPitReportsHandler returns a handler to get the pit reports for a team at an
+// event.
+func (s *Server) getPitReportsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventKey, teamKey := vars["eventKey"], vars["teamKey"]
+
+		var realmID *int64
+		userRealmID, err := ihttp.GetRealmID(r)
+		if err == nil {
+			realmID = &userRealmID
+		}
+
+		reports, err := s.Store.GetEventTeamPitReportsForRealm(r.Context(), eventKey, teamKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting pit reports")
+			return
+		}
+
+		ihttp.Respond(w, reports, http.StatusOK)
+	}
+}
+
+// putPitReportHandler returns a handler to submit a pit report for a team at an
+// event. The report is validated against the event's pit schema.
+func (s *Server) putPitReportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		eventKey, teamKey := vars["eventKey"], vars["teamKey"]
+
+		var report store.PitReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		reporterID, err := ihttp.GetSubject(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		report.EventKey = eventKey
+		report.TeamKey = teamKey
+		report.ReporterID = &reporterID
+		report.RealmID = &realmID
+
+		event, err := s.Store.GetEventForRealm(r.Context(), eventKey, &realmID)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event")
+			return
+		}
+
+		if _, err := s.Store.GetEventTeamForRealm(r.Context(), teamKey, eventKey, &realmID); errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving event team")
+			return
+		}
+
+		if event.PitSchemaID == nil {
+			ihttp.Respond(w, errors.New("no pit schema found"), http.StatusBadRequest)
+			return
+		}
+
+		schema, err := s.Store.GetSchemaByID(r.Context(), *event.PitSchemaID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving pit schema")
+			return
+		}
+
+		if schema.Kind != store.SchemaKindPit {
+			ihttp.Respond(w, errors.New("event pit schema is not a pit schema"), http.StatusBadRequest)
+			return
+		}
+
+		if err := validatePitReport(schema.Schema, report.Data); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		created, err := s.Store.UpsertPitReport(r.Context(), report)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("upserting pit report")
+			return
+		}
+
+		if created {
+			w.WriteHeader(http.StatusCreated)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
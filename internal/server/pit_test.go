@@ -0,0 +1,72 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+)
+
+func TestValidatePitReport(t *testing.T) {
+	fields := store.SchemaFields{
+		{FieldDescriptor: store.FieldDescriptor{Name: "Drivetrain"}, Type: "string", Options: []string{"tank", "swerve"}},
+		{FieldDescriptor: store.FieldDescriptor{Name: "Notes"}, Type: "string"},
+		{FieldDescriptor: store.FieldDescriptor{Name: "Weight"}, Type: "number"},
+		{FieldDescriptor: store.FieldDescriptor{Name: "Climber"}, Type: "boolean"},
+	}
+
+	testCases := []struct {
+		name        string
+		data        store.PitReportData
+		expectError bool
+	}{
+		{
+			name: "valid",
+			data: store.PitReportData{
+				{Name: "Drivetrain", Value: "swerve"},
+				{Name: "Notes", Value: "very fast"},
+				{Name: "Weight", Value: 112.5},
+				{Name: "Climber", Value: true},
+			},
+		},
+		{
+			name: "missing fields",
+			data: store.PitReportData{{Name: "Weight", Value: 112.5}},
+		},
+		{
+			name:        "unknown field",
+			data:        store.PitReportData{{Name: "Color", Value: "blue"}},
+			expectError: true,
+		},
+		{
+			name:        "duplicate field",
+			data:        store.PitReportData{{Name: "Weight", Value: 112.5}, {Name: "Weight", Value: 100.0}},
+			expectError: true,
+		},
+		{
+			name:        "wrong type",
+			data:        store.PitReportData{{Name: "Climber", Value: "yes"}},
+			expectError: true,
+		},
+		{
+			name:        "not an option",
+			data:        store.PitReportData{{Name: "Drivetrain", Value: "mecanum"}},
+			expectError: true,
+		},
+		{
+			name:        "null value",
+			data:        store.PitReportData{{Name: "Weight", Value: nil}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePitReport(fields, tt.data)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+		})
+	}
+}
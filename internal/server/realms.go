@@ -103,14 +103,14 @@ func (s *Server) updateRealmHandler() http.HandlerFunc {
 			return
 		}
 
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
 		userRealmID, err := ihttp.GetRealmID(r)
 		if err != nil {
 			ihttp.Error(w, http.StatusForbidden)
 			return
 		}
 
-		existed, err := editRealm(r.Context(), s.Store, roles, userRealmID, id, func(tx *sqlx.Tx) error {
+		existed, err := editRealm(r.Context(), s.Store, permissions, userRealmID, id, func(tx *sqlx.Tx) error {
 			if err := s.Store.UpdateRealmTx(r.Context(), tx, realm); err != nil {
 				return fmt.Errorf("unable to update realm %d: %w", realm.ID, err)
 			}
@@ -143,14 +143,14 @@ func (s *Server) deleteRealmHandler() http.HandlerFunc {
 			return
 		}
 
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
 		userRealmID, err := ihttp.GetRealmID(r)
 		if err != nil {
 			ihttp.Error(w, http.StatusForbidden)
 			return
 		}
 
-		existed, err := editRealm(r.Context(), s.Store, roles, userRealmID, id, func(tx *sqlx.Tx) error {
+		existed, err := editRealm(r.Context(), s.Store, permissions, userRealmID, id, func(tx *sqlx.Tx) error {
 			if err := s.Store.DeleteRealmTx(r.Context(), tx, id); err != nil {
 				return fmt.Errorf("unable to delete realm %d: %w", id, err)
 			}
@@ -174,7 +174,7 @@ func (s *Server) deleteRealmHandler() http.HandlerFunc {
 	}
 }
 
-func editRealm(ctx context.Context, sto *store.Service, roles store.Roles, userRealmID, realmID int64, editFunc func(tx *sqlx.Tx) error) (existed bool, err error) {
+func editRealm(ctx context.Context, sto *store.Service, permissions store.Permissions, userRealmID, realmID int64, editFunc func(tx *sqlx.Tx) error) (existed bool, err error) {
 	existed = true
 
 	err = sto.DoTransaction(ctx, func(tx *sqlx.Tx) error {
@@ -189,11 +189,11 @@ func editRealm(ctx context.Context, sto *store.Service, roles store.Roles, userR
 
 		existed = exists
 
-		if !roles.IsAdmin && !roles.IsSuperAdmin {
-			return forbiddenError{errors.New("only super-admins and admins can edit realms")}
+		if !permissions.Has(store.PermissionManageRealm) {
+			return forbiddenError{errors.New("only users who can manage realms can edit realms")}
 		}
 
-		if !roles.IsSuperAdmin && userRealmID != realmID {
+		if !permissions.Has(store.PermissionManageAll) && userRealmID != realmID {
 			return forbiddenError{errors.New("realm admins can only edit realms they belong to")}
 		}
 
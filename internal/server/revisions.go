@@ -0,0 +1,184 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+)
+
+// parseReportTarget parses the report or comment being viewed or modified from
+// the request. If it is unable to, it responds with an error and returns false.
+func parseReportTarget(w http.ResponseWriter, r *http.Request) (store.ReportTarget, bool) {
+	vars := mux.Vars(r)
+
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		ihttp.Error(w, http.StatusBadRequest)
+		return store.ReportTarget{}, false
+	}
+
+	realmID, err := ihttp.GetRealmID(r)
+	if err != nil {
+		ihttp.Error(w, http.StatusForbidden)
+		return store.ReportTarget{}, false
+	}
+
+	// Add eventKey as prefix to matchKey so that matchKey is globally
+	// unique and consistent with TBA match keys.
+	return store.ReportTarget{
+		ID:       id,
+		MatchKey: fmt.Sprintf("%s_%s", vars["eventKey"], vars["matchKey"]),
+		TeamKey:  vars["teamKey"],
+		RealmID:  realmID,
+	}, true
+}
+
+// canModify returns whether a user can revert or delete a report or comment.
+// Reporters who can still submit reports can modify their own reports and
+// comments, and moderators can modify any in their realm, including those of
+// deleted reporters.
+func canModify(permissions store.Permissions, subject int64, reporterID *int64) bool {
+	return permissions.Has(store.PermissionModerateReports) ||
+		(reporterID != nil && subject == *reporterID && permissions.Has(store.PermissionSubmitReports))
+}
+
+// getReportRevisionsHandler returns a handler to get every revision of a
+// report, oldest first.
+func (s *Server) getReportRevisionsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, ok := parseReportTarget(w, r)
+		if !ok {
+			return
+		}
+
+		revisions, err := s.Store.GetReportRevisions(r.Context(), target)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting report revisions")
+			return
+		}
+
+		ihttp.Respond(w, revisions, http.StatusOK)
+	}
+}
+
+// revertReportHandler returns a handler to set a report back to the data from one
+// of its revisions.
+func (s *Server) revertReportHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, ok := parseReportTarget(w, r)
+		if !ok {
+			return
+		}
+
+		revisionID, err := strconv.ParseInt(mux.Vars(r)["revisionId"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		reporterID, err := s.Store.GetReportReporterID(r.Context(), target)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting report reporter")
+			return
+		}
+
+		subject, err := ihttp.GetSubject(r)
+		if err != nil || !canModify(ihttp.GetPermissions(r), subject, reporterID) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.RevertReport(r.Context(), target, revisionID, subject)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("reverting report")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getCommentRevisionsHandler returns a handler to get every revision of a
+// comment, oldest first.
+func (s *Server) getCommentRevisionsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, ok := parseReportTarget(w, r)
+		if !ok {
+			return
+		}
+
+		revisions, err := s.Store.GetCommentRevisions(r.Context(), target)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting comment revisions")
+			return
+		}
+
+		ihttp.Respond(w, revisions, http.StatusOK)
+	}
+}
+
+// revertCommentHandler returns a handler to set a comment back to the comment from
+// one of its revisions.
+func (s *Server) revertCommentHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, ok := parseReportTarget(w, r)
+		if !ok {
+			return
+		}
+
+		revisionID, err := strconv.ParseInt(mux.Vars(r)["revisionId"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		reporterID, err := s.Store.GetCommentReporterID(r.Context(), target)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("getting comment reporter")
+			return
+		}
+
+		subject, err := ihttp.GetSubject(r)
+		if err != nil || !canModify(ihttp.GetPermissions(r), subject, reporterID) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.RevertComment(r.Context(), target, revisionID, subject)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("reverting comment")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
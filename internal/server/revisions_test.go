@@ -0,0 +1,35 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+)
+
+func TestCanModify(t *testing.T) {
+	three, four := int64(3), int64(4)
+
+	testCases := []struct {
+		name        string
+		permissions store.Permissions
+		subject     int64
+		reporterID  *int64
+		expected    bool
+	}{
+		{name: "own report", permissions: store.Permissions{store.PermissionSubmitReports}, subject: 3, reporterID: &three, expected: true},
+		{name: "own report without submit", subject: 3, reporterID: &three, expected: false},
+		{name: "other report", permissions: store.Permissions{store.PermissionSubmitReports}, subject: 3, reporterID: &four, expected: false},
+		{name: "deleted reporter", permissions: store.Permissions{store.PermissionSubmitReports}, subject: 3, expected: false},
+		{name: "moderator", permissions: store.Permissions{store.PermissionModerateReports}, subject: 3, reporterID: &four, expected: true},
+		{name: "moderator deleted reporter", permissions: store.Permissions{store.PermissionModerateReports}, subject: 3, expected: true},
+		{name: "manage all", permissions: store.Permissions{store.PermissionManageAll}, subject: 3, reporterID: &four, expected: true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canModify(tt.permissions, tt.subject, tt.reporterID); got != tt.expected {
+				t.Errorf("expected canModify to be %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// validatePermissions checks that every permission is a known permission.
+func validatePermissions(permissions store.Permissions) error {
+	for _, p := range permissions {
+		if !contains(permissionNames(store.AllPermissions), string(p)) {
+			return fmt.Errorf("unknown permission %q", p)
+		}
+	}
+
+	return nil
+}
+
+func permissionNames(permissions store.Permissions) []string {
+	names := make([]string, len(permissions))
+	for i, p := range permissions {
+		names[i] = string(p)
+	}
+
+	return names
+}
+
+// canGrant returns whether a user with the granter's permissions can grant
+// every one of the given permissions. Users can't grant permissions they
+// don't have themselves.
+func canGrant(granter store.Permissions, permissions store.Permissions) bool {
+	for _, p := range permissions {
+		if !granter.Has(p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkGrantableRoles checks that the roles with the given names exist in a
+// realm, and that a user with the given permissions can grant them. If not, it
+// responds with an error and returns false.
+func (s *Server) checkGrantableRoles(w http.ResponseWriter, r *http.Request, permissions store.Permissions, realmID int64, names []string) bool {
+	if len(names) == 0 {
+		return true
+	}
+
+	roles, err := s.Store.GetRolesByName(r.Context(), realmID, names)
+	if errors.Is(err, store.ErrNoResults{}) {
+		ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+		return false
+	} else if err != nil {
+		ihttp.Error(w, http.StatusInternalServerError)
+		s.Logger.WithError(err).Error("retrieving roles")
+		return false
+	}
+
+	for _, role := range roles {
+		if !canGrant(permissions, role.Permissions) {
+			ihttp.Respond(w, fmt.Errorf("you can't grant the %q role", role.Name), http.StatusForbidden)
+			return false
+		}
+	}
+
+	return true
+}
+
+// getRolesHandler returns a handler to get the roles available in the current
+// realm, both built-in and defined by the realm.
+func (s *Server) getRolesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		roles, err := s.Store.GetRoles(r.Context(), realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving roles")
+			return
+		}
+
+		ihttp.Respond(w, roles, http.StatusOK)
+	}
+}
+
+// createRoleHandler returns a handler to define a role in the current realm.
+func (s *Server) createRoleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var role store.Role
+		if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validator.New().Struct(role); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validatePermissions(role.Permissions); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if !canGrant(ihttp.GetPermissions(r), role.Permissions) {
+			ihttp.Respond(w, errors.New("roles can't have permissions you don't have"), http.StatusForbidden)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+		role.RealmID = &realmID
+
+		if role.Permissions == nil {
+			role.Permissions = store.Permissions{}
+		}
+
+		role.ID, err = s.Store.CreateRole(r.Context(), role)
+		if errors.Is(err, store.ErrExists{}) {
+			ihttp.Error(w, http.StatusConflict)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("creating role")
+			return
+		}
+
+		ihttp.Respond(w, role, http.StatusCreated)
+	}
+}
+
+// patchRoleHandler returns a handler to set the permissions of a role defined by
+// the current realm.
+func (s *Server) patchRoleHandler() http.HandlerFunc {
+	type patchRole struct {
+		Permissions store.Permissions `json:"permissions"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		var pr patchRole
+		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil || pr.Permissions == nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validatePermissions(pr.Permissions); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if !canGrant(ihttp.GetPermissions(r), pr.Permissions) {
+			ihttp.Respond(w, errors.New("roles can't have permissions you don't have"), http.StatusForbidden)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.UpdateRolePermissions(r.Context(), realmID, id, pr.Permissions)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("updating role")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// deleteRoleHandler returns a handler to delete a role defined by the current
+// realm, removing it from every user.
+func (s *Server) deleteRoleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.DeleteRole(r.Context(), realmID, id)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("deleting role")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// putUserRolesHandler returns a handler to replace the roles of a user. Users
+// who can manage users can only change the roles of users in their realm, and
+// can't grant roles with permissions they don't have.
+func (s *Server) putUserRolesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		var names []string
+		if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+		names = uniqueStrings(names)
+
+		permissions := ihttp.GetPermissions(r)
+
+		// roles are set in the current realm, or the user's default realm for super
+		// admins who aren't members of the current realm
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil && !permissions.Has(store.PermissionManageAll) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		var user store.User
+		if realmID != 0 {
+			user, err = s.Store.GetUserInRealm(r.Context(), id, realmID)
+		}
+		if realmID == 0 || (errors.Is(err, store.ErrNoResults{}) && permissions.Has(store.PermissionManageAll)) {
+			user, err = s.Store.GetUserByID(r.Context(), id)
+		}
+
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving user")
+			return
+		}
+
+		// users can't take away permissions they couldn't have granted either
+		if !canGrant(permissions, user.Permissions) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		if !s.checkGrantableRoles(w, r, permissions, user.RealmID, names) {
+			return
+		}
+
+		err = s.Store.SetUserRoles(r.Context(), id, user.RealmID, names)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("setting user roles")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
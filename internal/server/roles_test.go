@@ -0,0 +1,85 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+)
+
+func TestValidatePermissions(t *testing.T) {
+	testCases := []struct {
+		name        string
+		permissions store.Permissions
+		expectError bool
+	}{
+		{
+			name: "no permissions",
+		},
+		{
+			name:        "known permissions",
+			permissions: store.Permissions{store.PermissionViewReports, store.PermissionManageRealm},
+		},
+		{
+			name:        "unknown permission",
+			permissions: store.Permissions{store.PermissionViewReports, "reports:destroy"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePermissions(tt.permissions)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+		})
+	}
+}
+
+func TestCanGrant(t *testing.T) {
+	testCases := []struct {
+		name        string
+		granter     store.Permissions
+		permissions store.Permissions
+		expected    bool
+	}{
+		{
+			name:     "no permissions",
+			expected: true,
+		},
+		{
+			name:        "subset",
+			granter:     store.Permissions{store.PermissionViewReports, store.PermissionSubmitReports, store.PermissionManageUsers},
+			permissions: store.Permissions{store.PermissionSubmitReports},
+			expected:    true,
+		},
+		{
+			name:        "permission granter lacks",
+			granter:     store.Permissions{store.PermissionViewReports, store.PermissionManageUsers},
+			permissions: store.Permissions{store.PermissionViewReports, store.PermissionModerateReports},
+			expected:    false,
+		},
+		{
+			name:        "manage all",
+			granter:     store.Permissions{store.PermissionManageAll},
+			permissions: store.Permissions{store.PermissionManageAll, store.PermissionManageRealm},
+			expected:    true,
+		},
+		{
+			name:        "manage all without it",
+			granter:     store.Permissions{store.PermissionManageUsers, store.PermissionManageRealm},
+			permissions: store.Permissions{store.PermissionManageAll},
+			expected:    false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canGrant(tt.granter, tt.permissions); got != tt.expected {
+				t.Errorf("expected canGrant to be %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
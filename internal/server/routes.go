@@ -4,6 +4,7 @@ import (
 	"time"
 
 	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
 	"github.com/gorilla/mux"
 )
 
@@ -12,50 +13,126 @@ func (s *Server) registerRoutes() *mux.Router {
 
 	r.Handle("/", healthHandler(s.uptime, s.TBA, s.Store)).Methods("GET")
 	r.Handle("/openapi.yaml", openAPIHandler(openAPI)).Methods("GET")
+	r.Handle("/.well-known/jwks.json", jwksHandler(s.Keys)).Methods("GET")
 
-	r.Handle("/authenticate", authenticateHandler(s.Logger, time.Now, s.Store, s.JWTSecret)).Methods("POST")
-	r.Handle("/refresh", refreshHandler(s.Logger, time.Now, s.Store, s.JWTSecret)).Methods("POST")
+	r.Handle("/authenticate", authenticateHandler(s.Logger, time.Now, s.Store, s.Store, s.Lockout, s.Keys)).Methods("POST")
+	r.Handle("/refresh", refreshHandler(s.Logger, time.Now, s.Store, s.Store, s.Keys)).Methods("POST")
+	r.Handle("/logout", s.logoutHandler()).Methods("POST")
+	r.Handle("/oidc/authorize", s.oidcAuthorizeHandler()).Methods("GET")
+	r.Handle("/oidc/authenticate", s.oidcAuthenticateHandler()).Methods("POST")
+	r.Handle("/password-reset", s.resetPasswordHandler()).Methods("POST")
+	r.Handle("/switch-realm", ihttp.ACL(ihttp.NoAPIKey(s.switchRealmHandler()))).Methods("POST")
 
 	r.Handle("/users", s.createUserHandler()).Methods("POST")
-	r.Handle("/users", ihttp.ACL(s.getUsersHandler(), false, false, true)).Methods("GET")
-	r.Handle("/users/{id}", ihttp.ACL(s.getUserByIDHandler(), false, false, true)).Methods("GET")
-	r.Handle("/users/{id}", ihttp.ACL(s.patchUserHandler(), false, false, true)).Methods("PATCH")
-	r.Handle("/users/{id}", ihttp.ACL(s.deleteUserHandler(), false, false, true)).Methods("DELETE")
-
-	r.Handle("/schemas", ihttp.ACL(s.getSchemasHandler(), false, false, false)).Methods("GET")
-	r.Handle("/schemas", ihttp.ACL(s.createSchemaHandler(), true, true, true)).Methods("POST")
-	r.Handle("/schemas/{id}", ihttp.ACL(s.getSchemaByIDHandler(), false, false, false)).Methods("GET")
+	r.Handle("/users", ihttp.ACL(s.getUsersHandler())).Methods("GET")
+	r.Handle("/users/{id}", ihttp.ACL(s.getUserByIDHandler())).Methods("GET")
+	r.Handle("/users/{id}", ihttp.ACL(ihttp.NoAPIKey(s.patchUserHandler()))).Methods("PATCH")
+	r.Handle("/users/{id}", ihttp.ACL(ihttp.NoAPIKey(s.deleteUserHandler()))).Methods("DELETE")
+	r.Handle("/users/{id}/calendar-token", ihttp.ACL(ihttp.NoAPIKey(s.createCalendarTokenHandler()))).Methods("POST")
+	r.Handle("/users/{id}/calendar-token", ihttp.ACL(ihttp.NoAPIKey(s.deleteCalendarTokenHandler()))).Methods("DELETE")
+	r.Handle("/users/{id}/identities", ihttp.ACL(ihttp.NoAPIKey(s.getUserIdentitiesHandler()))).Methods("GET")
+	r.Handle("/users/{id}/identities", ihttp.ACL(ihttp.NoAPIKey(s.linkUserIdentityHandler()))).Methods("POST")
+	r.Handle("/users/{id}/identities/{identityId}", ihttp.ACL(ihttp.NoAPIKey(s.unlinkUserIdentityHandler()))).Methods("DELETE")
+	r.Handle("/users/{id}/password-reset", ihttp.ACL(ihttp.NoAPIKey(s.createPasswordResetHandler()), store.PermissionManageUsers)).Methods("POST")
+	r.Handle("/users/{id}/lockout", ihttp.ACL(s.unlockUserHandler(), store.PermissionManageUsers)).Methods("DELETE")
+	r.Handle("/users/{id}/roles", ihttp.ACL(s.putUserRolesHandler(), store.PermissionManageUsers)).Methods("PUT")
+	r.Handle("/users/{id}/sessions", ihttp.ACL(ihttp.NoAPIKey(s.getUserSessionsHandler()))).Methods("GET")
+	r.Handle("/users/{id}/sessions", ihttp.ACL(ihttp.NoAPIKey(s.revokeUserSessionsHandler()))).Methods("DELETE")
+	r.Handle("/users/{id}/sessions/{sessionId}", ihttp.ACL(ihttp.NoAPIKey(s.revokeUserSessionHandler()))).Methods("DELETE")
+	r.Handle("/users/{id}/realms", ihttp.ACL(s.getUserRealmsHandler())).Methods("GET")
+	r.Handle("/users/{id}/realms", ihttp.ACL(ihttp.NoAPIKey(s.joinRealmHandler()))).Methods("POST")
+	r.Handle("/users/{id}/realms/{realmId}", ihttp.ACL(ihttp.NoAPIKey(s.leaveRealmHandler()))).Methods("DELETE")
+
+	r.Handle("/realm-invites", ihttp.ACL(s.getRealmInvitesHandler(), store.PermissionManageUsers)).Methods("GET")
+	r.Handle("/realm-invites", ihttp.ACL(s.createRealmInviteHandler(), store.PermissionManageUsers)).Methods("POST")
+	r.Handle("/realm-invites/{id}", ihttp.ACL(s.deleteRealmInviteHandler(), store.PermissionManageUsers)).Methods("DELETE")
+
+	r.Handle("/membership-requests", ihttp.ACL(s.getMembershipRequestsHandler(), store.PermissionManageUsers)).Methods("GET")
+	r.Handle("/membership-requests/{userId}/approve", ihttp.ACL(s.approveMembershipRequestHandler(), store.PermissionManageUsers)).Methods("POST")
+	r.Handle("/membership-requests/{userId}", ihttp.ACL(s.rejectMembershipRequestHandler(), store.PermissionManageUsers)).Methods("DELETE")
+
+	r.Handle("/api-keys", ihttp.ACL(s.getAPIKeysHandler(), store.PermissionManageRealm)).Methods("GET")
+	r.Handle("/api-keys", ihttp.ACL(ihttp.NoAPIKey(s.createAPIKeyHandler()), store.PermissionManageRealm)).Methods("POST")
+	r.Handle("/api-keys/{id}", ihttp.ACL(s.revokeAPIKeyHandler(), store.PermissionManageRealm)).Methods("DELETE")
+
+	r.Handle("/roles", ihttp.ACL(s.getRolesHandler())).Methods("GET")
+	r.Handle("/roles", ihttp.ACL(s.createRoleHandler(), store.PermissionManageRealm)).Methods("POST")
+	r.Handle("/roles/{id}", ihttp.ACL(s.patchRoleHandler(), store.PermissionManageRealm)).Methods("PATCH")
+	r.Handle("/roles/{id}", ihttp.ACL(s.deleteRoleHandler(), store.PermissionManageRealm)).Methods("DELETE")
+
+	r.Handle("/schemas", s.getSchemasHandler()).Methods("GET")
+	r.Handle("/schemas", ihttp.ACL(s.createSchemaHandler(), store.PermissionManageSchemas)).Methods("POST")
+	r.Handle("/schemas/{id}", s.getSchemaByIDHandler()).Methods("GET")
 
 	r.Handle("/events", s.eventsHandler()).Methods("GET")
-	r.Handle("/events/{eventKey}", ihttp.ACL(s.upsertEventHandler(), true, true, true)).Methods("PUT")
+	r.Handle("/events/{eventKey}", ihttp.ACL(s.upsertEventHandler(), store.PermissionManageEvents)).Methods("PUT")
 	r.Handle("/events/{eventKey}", s.eventHandler()).Methods("GET")
 
 	r.Handle("/events/{eventKey}/stats", s.eventStats()).Methods("GET")
+	r.Handle("/events/{eventKey}/reliability", ihttp.ACL(s.eventReliabilityHandler(), store.PermissionViewReports)).Methods("GET")
+	r.Handle("/events/{eventKey}/awards", s.eventAwardsHandler()).Methods("GET")
+	r.Handle("/events/{eventKey}/calendar.ics", s.eventCalendarHandler()).Methods("GET")
+
+	r.Handle("/events/{eventKey}/scouts", ihttp.ACL(s.getEventScoutsHandler(), store.PermissionManageScouting)).Methods("GET")
+	r.Handle("/events/{eventKey}/scouts", ihttp.ACL(s.putEventScoutsHandler(), store.PermissionManageScouting)).Methods("PUT")
+	r.Handle("/events/{eventKey}/assignments", ihttp.ACL(s.getAssignmentsHandler(false), store.PermissionViewReports)).Methods("GET")
+	r.Handle("/events/{eventKey}/assignments", ihttp.ACL(s.generateAssignmentsHandler(), store.PermissionManageScouting)).Methods("POST")
+	r.Handle("/events/{eventKey}/assignments/me", ihttp.ACL(s.getAssignmentsHandler(true), store.PermissionViewReports)).Methods("GET")
+	r.Handle("/events/{eventKey}/coverage", ihttp.ACL(s.coverageHandler(), store.PermissionViewReports)).Methods("GET")
 
 	r.Handle("/events/{eventKey}/matches", s.matchesHandler()).Methods("GET")
 	r.Handle("/events/{eventKey}/matches/{matchKey}", s.matchHandler()).Methods("GET")
-	r.Handle("/events/{eventKey}/matches/{matchKey}", ihttp.ACL(s.upsertMatchHandler(), true, true, true)).Methods("PUT")
-	r.Handle("/events/{eventKey}/matches/{matchKey}", ihttp.ACL(s.deleteMatchHandler(), true, true, true)).Methods("DELETE")
+	r.Handle("/events/{eventKey}/matches/{matchKey}", ihttp.ACL(s.upsertMatchHandler(), store.PermissionManageEvents)).Methods("PUT")
+	r.Handle("/events/{eventKey}/matches/{matchKey}", ihttp.ACL(s.deleteMatchHandler(), store.PermissionManageEvents)).Methods("DELETE")
 
 	r.Handle("/events/{eventKey}/teams", s.eventTeamsHandler()).Methods("GET")
 	r.Handle("/events/{eventKey}/teams/{teamKey}", s.eventTeamHandler()).Methods("GET")
-	r.Handle("/events/{eventKey}/teams/{teamKey}/comments", ihttp.ACL(s.getEventComments(), false, false, false)).Methods("GET")
-
-	r.Handle("/events/{eventKey}/matches/{matchKey}/reports/{teamKey}", ihttp.ACL(s.getReports(), false, false, false)).Methods("GET")
-	r.Handle("/events/{eventKey}/matches/{matchKey}/reports/{teamKey}", ihttp.ACL(s.putReport(), false, true, true)).Methods("PUT")
+	r.Handle("/events/{eventKey}/teams/{teamKey}/comments", s.getEventComments()).Methods("GET")
+	r.Handle("/events/{eventKey}/teams/{teamKey}/pit-reports", s.getPitReportsHandler()).Methods("GET")
+	r.Handle("/events/{eventKey}/teams/{teamKey}/pit-reports", ihttp.ACL(s.putPitReportHandler(), store.PermissionSubmitReports)).Methods("PUT")
+	r.Handle("/events/{eventKey}/teams/{teamKey}/pit-reports/media", ihttp.ACL(s.uploadPitReportMediaHandler(), store.PermissionSubmitReports)).Methods("POST").Name(pitReportMediaRoute)
+	r.Handle("/events/{eventKey}/teams/{teamKey}/media", s.getEventTeamMediaHandler()).Methods("GET")
+
+	r.Handle("/events/{eventKey}/matches/{matchKey}/reports/{teamKey}", s.getReports()).Methods("GET")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/reports/{teamKey}", ihttp.ACL(s.putReport(), store.PermissionSubmitReports)).Methods("PUT")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/reports/{teamKey}/{id}", ihttp.ACL(s.deleteReportHandler())).Methods("DELETE")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/reports/{teamKey}/{id}", ihttp.ACL(s.patchReportHandler(), store.PermissionModerateReports)).Methods("PATCH")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/reports/{teamKey}/{id}/revisions", ihttp.ACL(s.getReportRevisionsHandler(), store.PermissionViewReports)).Methods("GET")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/reports/{teamKey}/{id}/revisions/{revisionId}/revert", ihttp.ACL(s.revertReportHandler())).Methods("POST")
 
 	r.Handle("/events/{eventKey}/matches/{matchKey}/teams/{teamKey}/stats", s.matchTeamStats()).Methods("GET")
 
-	r.Handle("/events/{eventKey}/matches/{matchKey}/comments/{teamKey}", ihttp.ACL(s.getMatchTeamComments(), false, false, false)).Methods("GET")
-	r.Handle("/events/{eventKey}/matches/{matchKey}/comments/{teamKey}", ihttp.ACL(s.putMatchTeamComment(), false, true, true)).Methods("PUT")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/comments/{teamKey}", s.getMatchTeamComments()).Methods("GET")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/comments/{teamKey}", ihttp.ACL(s.putMatchTeamComment(), store.PermissionSubmitReports)).Methods("PUT")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/comments/{teamKey}/media", ihttp.ACL(s.uploadCommentMediaHandler(), store.PermissionSubmitReports)).Methods("POST").Name(commentMediaRoute)
+	r.Handle("/events/{eventKey}/matches/{matchKey}/comments/{teamKey}/{id}", ihttp.ACL(s.deleteCommentHandler())).Methods("DELETE")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/comments/{teamKey}/{id}", ihttp.ACL(s.patchCommentHandler(), store.PermissionModerateReports)).Methods("PATCH")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/comments/{teamKey}/{id}/revisions", ihttp.ACL(s.getCommentRevisionsHandler(), store.PermissionViewReports)).Methods("GET")
+	r.Handle("/events/{eventKey}/matches/{matchKey}/comments/{teamKey}/{id}/revisions/{revisionId}/revert", ihttp.ACL(s.revertCommentHandler())).Methods("POST")
+
+	r.Handle("/comments/search", s.searchCommentsHandler()).Methods("GET")
+	r.Handle("/comment-tags", ihttp.ACL(s.getCommentTagsHandler())).Methods("GET")
+	r.Handle("/comment-tags", ihttp.ACL(s.createCommentTagHandler(), store.PermissionManageRealm)).Methods("POST")
+	r.Handle("/comment-tags/{id}", ihttp.ACL(s.deleteCommentTagHandler(), store.PermissionManageRealm)).Methods("DELETE")
 
 	r.Handle("/leaderboard", s.leaderboardHandler()).Methods("GET")
 
+	r.Handle("/media/{id}", s.getMediaHandler(false)).Methods("GET").Name(mediaRoute)
+	r.Handle("/media/{id}", ihttp.ACL(s.deleteMediaHandler())).Methods("DELETE")
+	r.Handle("/media/{id}/thumbnail", s.getMediaHandler(true)).Methods("GET")
+
 	r.Handle("/realms", s.realmsHandler()).Methods("GET")
 	r.Handle("/realms", s.createRealmHandler()).Methods("POST")
 	r.Handle("/realms/{id}", s.realmHandler()).Methods("GET")
-	r.Handle("/realms/{id}", ihttp.ACL(s.updateRealmHandler(), true, true, true)).Methods("POST")
-	r.Handle("/realms/{id}", ihttp.ACL(s.deleteRealmHandler(), true, true, true)).Methods("DELETE")
+	r.Handle("/realms/{id}", ihttp.ACL(s.updateRealmHandler(), store.PermissionManageRealm)).Methods("POST")
+	r.Handle("/realms/{id}", ihttp.ACL(s.deleteRealmHandler(), store.PermissionManageRealm)).Methods("DELETE")
+	r.Handle("/realms/{id}/password-policy", s.getPasswordPolicyHandler()).Methods("GET")
+	r.Handle("/realms/{id}/password-policy", ihttp.ACL(s.putPasswordPolicyHandler(), store.PermissionManageRealm)).Methods("PUT")
+
+	r.Handle("/sharing-agreements", ihttp.ACL(s.getSharingAgreementsHandler())).Methods("GET")
+	r.Handle("/sharing-agreements", ihttp.ACL(s.createSharingAgreementHandler(), store.PermissionManageRealm)).Methods("POST")
+	r.Handle("/sharing-agreements/{id}", ihttp.ACL(s.deleteSharingAgreementHandler(), store.PermissionManageRealm)).Methods("DELETE")
+	r.Handle("/sharing-agreements/{id}/accept", ihttp.ACL(s.acceptSharingAgreementHandler(), store.PermissionManageRealm)).Methods("POST")
 
 	r.Handle("/teams/{teamKey}", s.teamHandler()).Methods("GET")
 
@@ -3,6 +3,7 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -11,6 +12,45 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// pitFieldTypes are the types a pit schema field can have.
+var pitFieldTypes = map[string]bool{"number": true, "boolean": true, "string": true}
+
+// validateSchema checks that a schema has a valid kind, defaulting to a match
+// schema, and that the fields of a pit schema are valid.
+func validateSchema(schema *store.Schema) error {
+	switch schema.Kind {
+	case "":
+		schema.Kind = store.SchemaKindMatch
+	case store.SchemaKindMatch, store.SchemaKindPit:
+	default:
+		return fmt.Errorf("invalid schema kind %q", schema.Kind)
+	}
+
+	if schema.Kind != store.SchemaKindPit {
+		return nil
+	}
+
+	names := make(map[string]bool)
+	for _, field := range schema.Schema {
+		if field.Name == "" {
+			return errors.New("pit schema fields must have a name")
+		}
+		if names[field.Name] {
+			return fmt.Errorf("duplicate pit schema field %q", field.Name)
+		}
+		names[field.Name] = true
+
+		if !pitFieldTypes[field.Type] {
+			return fmt.Errorf("pit schema field %q has invalid type %q", field.Name, field.Type)
+		}
+		if len(field.Options) > 0 && field.Type != "string" {
+			return fmt.Errorf("pit schema field %q has options but isn't a string", field.Name)
+		}
+	}
+
+	return nil
+}
+
 func (s *Server) createSchemaHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var schema store.Schema
@@ -19,8 +59,13 @@ func (s *Server) createSchemaHandler() http.HandlerFunc {
 			return
 		}
 
-		roles := ihttp.GetRoles(r)
-		if schema.Year != nil && !roles.IsSuperAdmin {
+		if err := validateSchema(&schema); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		permissions := ihttp.GetPermissions(r)
+		if schema.Year != nil && !permissions.Has(store.PermissionManageAll) {
 			ihttp.Error(w, http.StatusForbidden)
 			return
 		}
@@ -61,7 +106,12 @@ func (s *Server) getSchemasHandler() http.HandlerFunc {
 				return
 			}
 
-			schema, err := s.Store.GetSchemaByYear(r.Context(), year)
+			kind := r.URL.Query().Get("kind")
+			if kind == "" {
+				kind = store.SchemaKindMatch
+			}
+
+			schema, err := s.Store.GetSchemaByYear(r.Context(), year, kind)
 			if errors.Is(err, store.ErrNoResults{}) {
 				ihttp.Error(w, http.StatusNotFound)
 				return
@@ -110,7 +160,7 @@ func (s *Server) getSchemaByIDHandler() http.HandlerFunc {
 			return
 		}
 
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
 
 		var realmID *int64
 		userRealmID, err := ihttp.GetRealmID(r)
@@ -118,7 +168,7 @@ func (s *Server) getSchemaByIDHandler() http.HandlerFunc {
 			realmID = &userRealmID
 		}
 
-		if schema.Year == nil && !roles.IsSuperAdmin && schema.RealmID != realmID {
+		if schema.Year == nil && !permissions.Has(store.PermissionManageAll) && schema.RealmID != realmID {
 			ihttp.Error(w, http.StatusForbidden)
 			return
 		}
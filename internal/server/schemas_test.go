@@ -0,0 +1,80 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+)
+
+func TestValidateSchema(t *testing.T) {
+	testCases := []struct {
+		name         string
+		schema       store.Schema
+		expectedKind string
+		expectError  bool
+	}{
+		{
+			name:         "default kind",
+			schema:       store.Schema{Schema: store.SchemaFields{{ReportReference: "Cargo"}}},
+			expectedKind: store.SchemaKindMatch,
+		},
+		{
+			name: "pit schema",
+			schema: store.Schema{Kind: store.SchemaKindPit, Schema: store.SchemaFields{
+				{FieldDescriptor: store.FieldDescriptor{Name: "Drivetrain"}, Type: "string", Options: []string{"tank", "swerve"}},
+				{FieldDescriptor: store.FieldDescriptor{Name: "Weight"}, Type: "number"},
+				{FieldDescriptor: store.FieldDescriptor{Name: "Climber"}, Type: "boolean"},
+			}},
+			expectedKind: store.SchemaKindPit,
+		},
+		{
+			name:        "invalid kind",
+			schema:      store.Schema{Kind: "stand"},
+			expectError: true,
+		},
+		{
+			name: "pit field without name",
+			schema: store.Schema{Kind: store.SchemaKindPit, Schema: store.SchemaFields{
+				{Type: "number"},
+			}},
+			expectError: true,
+		},
+		{
+			name: "duplicate pit field",
+			schema: store.Schema{Kind: store.SchemaKindPit, Schema: store.SchemaFields{
+				{FieldDescriptor: store.FieldDescriptor{Name: "Weight"}, Type: "number"},
+				{FieldDescriptor: store.FieldDescriptor{Name: "Weight"}, Type: "number"},
+			}},
+			expectError: true,
+		},
+		{
+			name: "pit field with invalid type",
+			schema: store.Schema{Kind: store.SchemaKindPit, Schema: store.SchemaFields{
+				{FieldDescriptor: store.FieldDescriptor{Name: "Weight"}, Type: "kilograms"},
+			}},
+			expectError: true,
+		},
+		{
+			name: "options on number field",
+			schema: store.Schema{Kind: store.SchemaKindPit, Schema: store.SchemaFields{
+				{FieldDescriptor: store.FieldDescriptor{Name: "Weight"}, Type: "number", Options: []string{"heavy"}},
+			}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSchema(&tt.schema)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+
+			if !tt.expectError && tt.schema.Kind != tt.expectedKind {
+				t.Errorf("expected kind %q, got %q", tt.expectedKind, tt.schema.Kind)
+			}
+		})
+	}
+}
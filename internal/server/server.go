@@ -1,14 +1,21 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/NYTimes/gziphandler"
+	"github.com/Pigmice2733/peregrine-backend/internal/blob"
 	"github.com/Pigmice2733/peregrine-backend/internal/config"
 	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/jwtkeys"
+	"github.com/Pigmice2733/peregrine-backend/internal/lockout"
+	"github.com/Pigmice2733/peregrine-backend/internal/notify"
+	"github.com/Pigmice2733/peregrine-backend/internal/oidc"
 	"github.com/Pigmice2733/peregrine-backend/internal/store"
 	"github.com/Pigmice2733/peregrine-backend/internal/tba"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,26 +26,86 @@ var openAPI []byte
 type Server struct {
 	config.Server
 
-	TBA    *tba.Service
-	Store  *store.Service
-	Logger *logrus.Logger
-	start  time.Time
+	TBA     *tba.Service
+	Store   *store.Service
+	Logger  *logrus.Logger
+	Media   config.Media
+	Blobs   blob.Store
+	Lockout *lockout.Limiter
+	start   time.Time
+
+	// Keys signs and verifies JWTs. Its public keys are published at
+	// /.well-known/jwks.json.
+	Keys *jwtkeys.Set
+
+	// OIDC is the OpenID Connect provider users can log in with, if any. Users
+	// with verified email addresses in OIDCRealmDomains join its realm when
+	// they first log in.
+	OIDC             *oidc.Provider
+	OIDCRealmDomains map[string]int64
+
+	// Notifier delivers password reset tokens to users, if any. Otherwise they
+	// are shown to the admin who creates them.
+	Notifier notify.Notifier
 }
 
 func (s *Server) uptime() time.Duration {
 	return time.Since(s.start)
 }
 
+// matchesRoute returns whether a request is to one of the named routes.
+func matchesRoute(router *mux.Router, r *http.Request, names map[string]bool) bool {
+	var match mux.RouteMatch
+	return router.Match(r, &match) && names[match.Route.GetName()]
+}
+
+// bodyLimit returns the limit for request bodies, which is larger for media
+// uploads.
+func (s *Server) bodyLimit(router *mux.Router) func(*http.Request) int64 {
+	return func(r *http.Request) int64 {
+		if matchesRoute(router, r, mediaUploadRoutes) {
+			if s.Media.MaxVideoBytes > s.Media.MaxImageBytes {
+				return s.Media.MaxVideoBytes
+			}
+			return s.Media.MaxImageBytes
+		}
+
+		return ihttp.DefaultBodyLimit
+	}
+}
+
+// transferTimeout gives media uploads and downloads longer than the server's
+// timeouts, since large videos can take minutes to transfer.
+func transferTimeout(router *mux.Router) func(*http.Request) time.Duration {
+	return func(r *http.Request) time.Duration {
+		if matchesRoute(router, r, mediaTransferRoutes) {
+			return mediaTimeout
+		}
+
+		return 0
+	}
+}
+
+// compressedContentTypes are the content types of responses that are gzipped.
+// Media is already compressed, and ranges of it are served, so it isn't.
+var compressedContentTypes = []string{"application/json", "application/x-yaml", "text/calendar", "text/plain"}
+
 // Run starts the server, and returns if it runs into an error
 func (s *Server) Run() error {
 	router := s.registerRoutes()
 
+	gzip, err := gziphandler.GzipHandlerWithOpts(gziphandler.ContentTypes(compressedContentTypes))
+	if err != nil {
+		return fmt.Errorf("unable to create gzip handler: %w", err)
+	}
+
 	var handler http.Handler = router
-	handler = ihttp.LimitBody(handler)
-	handler = gziphandler.GzipHandler(handler)
+	handler = ihttp.LimitBody(handler, s.bodyLimit(router))
+	handler = gzip(handler)
 	handler = ihttp.Log(handler, s.Logger)
-	handler = ihttp.Auth(handler, s.JWTSecret)
+	handler = ihttp.Auth(handler, s.Keys.Keyfunc, s.Store, s.Store)
 	handler = ihttp.CORS(handler, s.Origin)
+	handler = ihttp.Deadline(handler, transferTimeout(router))
 
 	httpServer := &http.Server{
 		Addr:              s.Listen,
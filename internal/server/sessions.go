@@ -0,0 +1,129 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+)
+
+// checkSessionAccess checks that the current user can manage the sessions of
+// another user. Users can manage their own sessions, and users who can manage
+// users can manage the sessions of users in their realm. If not, it responds
+// with an error and returns false.
+func (s *Server) checkSessionAccess(w http.ResponseWriter, r *http.Request, userID int64) bool {
+	subjectID, err := ihttp.GetSubject(r)
+	if err != nil {
+		ihttp.Error(w, http.StatusForbidden)
+		return false
+	}
+
+	permissions := ihttp.GetPermissions(r)
+	if subjectID == userID || permissions.Has(store.PermissionManageAll) {
+		return true
+	}
+
+	realmID, err := ihttp.GetRealmID(r)
+	if err != nil || !permissions.Has(store.PermissionManageUsers) {
+		ihttp.Error(w, http.StatusForbidden)
+		return false
+	}
+
+	_, err = s.Store.GetUserInRealm(r.Context(), userID, realmID)
+	if errors.Is(err, store.ErrNoResults{}) {
+		ihttp.Error(w, http.StatusNotFound)
+		return false
+	} else if err != nil {
+		ihttp.Error(w, http.StatusInternalServerError)
+		s.Logger.WithError(err).Error("retrieving user")
+		return false
+	}
+
+	return true
+}
+
+// getUserSessionsHandler returns a handler to get the devices a user is logged
+// in on.
+func (s *Server) getUserSessionsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		if !s.checkSessionAccess(w, r, id) {
+			return
+		}
+
+		sessions, err := s.Store.GetUserSessions(r.Context(), id)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving sessions")
+			return
+		}
+
+		ihttp.Respond(w, sessions, http.StatusOK)
+	}
+}
+
+// revokeUserSessionHandler returns a handler to log a user out of a device.
+// Access tokens that were already issued for the session stay valid until they
+// expire.
+func (s *Server) revokeUserSessionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		sessionID, err := strconv.ParseInt(mux.Vars(r)["sessionId"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		if !s.checkSessionAccess(w, r, id) {
+			return
+		}
+
+		err = s.Store.RevokeSession(r.Context(), id, sessionID)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("revoking session")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// revokeUserSessionsHandler returns a handler to log a user out of every device.
+func (s *Server) revokeUserSessionsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		if !s.checkSessionAccess(w, r, id) {
+			return
+		}
+
+		if err := s.Store.RevokeUserSessions(r.Context(), id); err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("revoking sessions")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// validateSharingAgreement checks that an agreement invited by the given realm is
+// with another realm and shares at least one kind of data.
+func validateSharingAgreement(realmID int64, a store.SharingAgreement) error {
+	if a.PartnerRealmID == realmID {
+		return errors.New("realms can't have sharing agreements with themselves")
+	}
+
+	if !a.ShareReports && !a.ShareComments && !a.SharePitData {
+		return errors.New("sharing agreements must share reports, comments, or pit data")
+	}
+
+	return nil
+}
+
+// getSharingAgreementsHandler returns a handler to get the sharing agreements the
+// current realm is part of, including pending invitations.
+func (s *Server) getSharingAgreementsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		agreements, err := s.Store.GetSharingAgreements(r.Context(), realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving sharing agreements")
+			return
+		}
+
+		ihttp.Respond(w, agreements, http.StatusOK)
+	}
+}
+
+// createSharingAgreementHandler returns a handler to invite another realm to a
+// sharing agreement with the current realm.
+func (s *Server) createSharingAgreementHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var a store.SharingAgreement
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validator.New().Struct(a); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		if err := validateSharingAgreement(realmID, a); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		a.RealmID = realmID
+		a.Accepted = false
+
+		a.ID, err = s.Store.CreateSharingAgreement(r.Context(), a)
+		if errors.Is(err, store.ErrFKeyViolation{}) {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("creating sharing agreement")
+			return
+		}
+
+		ihttp.Respond(w, a, http.StatusCreated)
+	}
+}
+
+// acceptSharingAgreementHandler returns a handler to accept an invitation to a
+// sharing agreement sent to the current realm.
+func (s *Server) acceptSharingAgreementHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		a, err := s.Store.AcceptSharingAgreement(r.Context(), realmID, id)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("accepting sharing agreement")
+			return
+		}
+
+		ihttp.Respond(w, a, http.StatusOK)
+	}
+}
+
+// deleteSharingAgreementHandler returns a handler to end a sharing agreement the
+// current realm is part of, or to withdraw or decline an invitation.
+func (s *Server) deleteSharingAgreementHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.DeleteSharingAgreement(r.Context(), realmID, id)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("deleting sharing agreement")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+)
+
+func TestValidateSharingAgreement(t *testing.T) {
+	testCases := []struct {
+		name        string
+		agreement   store.SharingAgreement
+		expectError bool
+	}{
+		{
+			name:      "shares reports",
+			agreement: store.SharingAgreement{PartnerRealmID: 2, ShareReports: true},
+		},
+		{
+			name:      "shares comments and pit data",
+			agreement: store.SharingAgreement{PartnerRealmID: 2, ShareComments: true, SharePitData: true},
+		},
+		{
+			name:        "same realm",
+			agreement:   store.SharingAgreement{PartnerRealmID: 1, ShareReports: true},
+			expectError: true,
+		},
+		{
+			name:        "shares nothing",
+			agreement:   store.SharingAgreement{PartnerRealmID: 2},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSharingAgreement(1, tt.agreement)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 
 	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
 	"github.com/Pigmice2733/peregrine-backend/internal/store"
@@ -11,11 +14,114 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// eventStats analyzes the event-wide statistics of every team at an event with submitted reports
+// errNoSchema is returned when summarizing an event that has no schema.
+var errNoSchema = errors.New("no schema found")
+
+// getEventTeamMatches retrieves the schema for an event and the matches of every
+// team at the event with reports from the given realm (and realms sharing reports).
+// If the event has no schema errNoSchema is returned.
+func (s *Server) getEventTeamMatches(ctx context.Context, eventKey string, realmID *int64) (summary.Schema, map[string][]summary.Match, error) {
+	event, err := s.Store.GetEventForRealm(ctx, eventKey, realmID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve event: %w", err)
+	}
+
+	if event.SchemaID == nil {
+		return nil, nil, errNoSchema
+	}
+
+	reports, err := s.Store.GetEventReportsForRealm(ctx, eventKey, realmID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve reports: %w", err)
+	}
+
+	storeSchema, err := s.Store.GetSchemaByID(ctx, *event.SchemaID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve event schema: %w", err)
+	}
+
+	storeMatches, err := s.Store.GetEventAnalysisInfoForRealm(ctx, eventKey, realmID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to retrieve match analysis info: %w", err)
+	}
+
+	return storeSummaryToSummarySchema(storeSchema), selectTeamMatches(storeMatches, reports), nil
+}
+
+// eventTeamMatches is like getEventTeamMatches for the current realm, but if it is
+// unable to retrieve the matches it responds with an error and returns false.
+func (s *Server) eventTeamMatches(w http.ResponseWriter, r *http.Request, eventKey string) (summary.Schema, map[string][]summary.Match, bool) {
+	var realmID *int64
+	userRealmID, err := ihttp.GetRealmID(r)
+	if err == nil {
+		realmID = &userRealmID
+	}
+
+	schema, teamToMatches, err := s.getEventTeamMatches(r.Context(), eventKey, realmID)
+	if errors.Is(err, store.ErrNoResults{}) {
+		ihttp.Error(w, http.StatusNotFound)
+		return nil, nil, false
+	} else if errors.Is(err, errNoSchema) {
+		ihttp.Respond(w, err, http.StatusBadRequest)
+		return nil, nil, false
+	} else if err != nil {
+		ihttp.Error(w, http.StatusInternalServerError)
+		s.Logger.WithError(err).Error("retrieving event team matches")
+		return nil, nil, false
+	}
+
+	return schema, teamToMatches, true
+}
+
+// analyzeReliability analyzes the reliability of every reporter at an event.
+func analyzeReliability(schema summary.Schema, teamToMatches map[string][]summary.Match) (map[int64]summary.Reliability, error) {
+	var matches []summary.Match
+	for _, teamMatches := range teamToMatches {
+		matches = append(matches, teamMatches...)
+	}
+
+	return summary.AnalyzeReliability(schema, matches)
+}
+
+// eventStats analyzes the event-wide statistics of every team at an event with submitted
+// reports. If weighted is true, reports are weighted by the reliability of their reporter.
 func (s *Server) eventStats() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		eventKey := vars["eventKey"]
+		weighted, _ := strconv.ParseBool(r.URL.Query().Get("weighted"))
+
+		schema, teamToMatches, ok := s.eventTeamMatches(w, r, eventKey)
+		if !ok {
+			return
+		}
+
+		var weights map[int64]float64
+		if weighted {
+			reliabilities, err := analyzeReliability(schema, teamToMatches)
+			if err != nil {
+				ihttp.Error(w, http.StatusInternalServerError)
+				s.Logger.WithError(err).Error("analyzing reporter reliability")
+				return
+			}
+
+			weights = make(map[int64]float64)
+			for reporterID, reliability := range reliabilities {
+				weights[reporterID] = reliability.Weight()
+			}
+		}
+
+		teamAnalyses := make([]teamAnalysis, 0)
+		for team, teamToMatch := range teamToMatches {
+			summary, err := summary.SummarizeTeamWeighted(schema, teamToMatch, weights)
+			if err != nil {
+				ihttp.Error(w, http.StatusInternalServerError)
+				s.Logger.WithError(err).WithField("team", team).Error("retrieving match summary")
+				return
+			}
+
+			teamAnalyses = append(teamAnalyses, teamAnalysisFromSummary(summary, team))
+		}
 
 		var realmID *int64
 		userRealmID, err := ihttp.GetRealmID(r)
@@ -23,61 +129,73 @@ func (s *Server) eventStats() http.HandlerFunc {
 			realmID = &userRealmID
 		}
 
-		event, err := s.Store.GetEventForRealm(r.Context(), eventKey, realmID)
-		if errors.Is(err, store.ErrNoResults{}) {
-			ihttp.Error(w, http.StatusNotFound)
-			return
-		} else if err != nil {
+		tagCounts, err := s.Store.GetTagCountsForRealm(r.Context(), eventKey, nil, realmID)
+		if err != nil {
 			ihttp.Error(w, http.StatusInternalServerError)
-			s.Logger.WithError(err).Error("retrieving event")
+			s.Logger.WithError(err).Error("retrieving comment tag counts")
 			return
 		}
 
-		if event.SchemaID == nil {
-			ihttp.Respond(w, errors.New("no schema found"), http.StatusBadRequest)
-			return
-		}
+		ihttp.Respond(w, addTagCounts(teamAnalyses, tagCounts), http.StatusOK)
+	}
+}
 
-		reports, err := s.Store.GetEventReportsForRealm(r.Context(), eventKey, realmID)
-		if err != nil {
-			ihttp.Error(w, http.StatusInternalServerError)
-			s.Logger.WithError(err).Error("retrieving reports")
-			return
-		}
+type reporterReliability struct {
+	ReporterID           int64   `json:"reporterId"`
+	TBAComparisons       int     `json:"tbaComparisons"`
+	TBAErrors            int     `json:"tbaErrors"`
+	TBAErrorRate         float64 `json:"tbaErrorRate"`
+	PeerComparisons      int     `json:"peerComparisons"`
+	PeerDisagreements    int     `json:"peerDisagreements"`
+	PeerDisagreementRate float64 `json:"peerDisagreementRate"`
+	ErrorRate            float64 `json:"errorRate"`
+	Weight               float64 `json:"weight"`
+}
 
-		storeSchema, err := s.Store.GetSchemaByID(r.Context(), *event.SchemaID)
-		if errors.Is(err, store.ErrNoResults{}) {
-			ihttp.Error(w, http.StatusNotFound)
-			return
-		} else if err != nil {
-			ihttp.Error(w, http.StatusInternalServerError)
-			s.Logger.WithError(err).Error("retrieving event schema")
+// eventReliabilityHandler returns a handler to get how reliable each reporter at an event
+// is, by comparing their reports to TBA score breakdowns and to other reports of the same
+// robot.
+func (s *Server) eventReliabilityHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventKey := mux.Vars(r)["eventKey"]
+
+		schema, teamToMatches, ok := s.eventTeamMatches(w, r, eventKey)
+		if !ok {
 			return
 		}
 
-		storeMatches, err := s.Store.GetEventAnalysisInfoForRealm(r.Context(), eventKey, realmID)
+		reliabilities, err := analyzeReliability(schema, teamToMatches)
 		if err != nil {
 			ihttp.Error(w, http.StatusInternalServerError)
-			s.Logger.WithError(err).Error("retrieving match analysis info")
+			s.Logger.WithError(err).Error("analyzing reporter reliability")
 			return
 		}
 
-		schema := storeSummaryToSummarySchema(storeSchema)
-		teamToMatches := selectTeamMatches(storeMatches, reports)
-
-		teamAnalyses := make([]teamAnalysis, 0)
-		for team, teamToMatch := range teamToMatches {
-			summary, err := summary.SummarizeTeam(schema, teamToMatch)
-			if err != nil {
-				ihttp.Error(w, http.StatusInternalServerError)
-				s.Logger.WithError(err).WithField("team", team).Error("retrieving match summary")
-				return
+		reporters := make([]reporterReliability, 0)
+		for reporterID, reliability := range reliabilities {
+			// reports by deleted users can't be attributed to anyone
+			if reporterID == 0 {
+				continue
 			}
 
-			teamAnalyses = append(teamAnalyses, teamAnalysisFromSummary(summary, team))
+			reporters = append(reporters, reporterReliability{
+				ReporterID:           reporterID,
+				TBAComparisons:       reliability.TBAComparisons,
+				TBAErrors:            reliability.TBAErrors,
+				TBAErrorRate:         reliability.TBAErrorRate(),
+				PeerComparisons:      reliability.PeerComparisons,
+				PeerDisagreements:    reliability.PeerDisagreements,
+				PeerDisagreementRate: reliability.PeerDisagreementRate(),
+				ErrorRate:            reliability.ErrorRate(),
+				Weight:               reliability.Weight(),
+			})
 		}
 
-		ihttp.Respond(w, teamAnalyses, http.StatusOK)
+		sort.Slice(reporters, func(i, j int) bool {
+			return reporters[i].ReporterID < reporters[j].ReporterID
+		})
+
+		ihttp.Respond(w, reporters, http.StatusOK)
 	}
 }
 
@@ -149,14 +267,32 @@ func (s *Server) matchTeamStats() http.HandlerFunc {
 			return
 		}
 
+		tagCounts, err := s.Store.GetTagCountsForRealm(r.Context(), eventKey, &matchKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving comment tag counts")
+			return
+		}
+
 		teamAnalysis := teamAnalysisFromSummary(summary, teamKey)
+		if counts, ok := tagCounts[teamKey]; ok {
+			teamAnalysis.Tags = counts
+		}
+
 		ihttp.Respond(w, teamAnalysis, http.StatusOK)
 	}
 }
 
+// selectTeamMatches groups reports by team and match, leaving out excluded reports.
+// Reports by deleted users have a reporter ID of 0.
 func selectTeamMatches(storeMatches []store.Match, reports []store.Report) map[string][]summary.Match {
 	teamToMatchToReports := make(map[string]map[string][]summary.Report)
+	teamToMatchToReporters := make(map[string]map[string][]int64)
 	for _, report := range reports {
+		if report.Excluded {
+			continue
+		}
+
 		var summaryReport summary.Report
 
 		for _, stat := range report.Data {
@@ -169,9 +305,16 @@ func selectTeamMatches(storeMatches []store.Match, reports []store.Report) map[s
 		_, ok := teamToMatchToReports[report.TeamKey]
 		if !ok {
 			teamToMatchToReports[report.TeamKey] = make(map[string][]summary.Report)
+			teamToMatchToReporters[report.TeamKey] = make(map[string][]int64)
+		}
+
+		var reporterID int64
+		if report.ReporterID != nil {
+			reporterID = *report.ReporterID
 		}
 
 		teamToMatchToReports[report.TeamKey][report.MatchKey] = append(teamToMatchToReports[report.TeamKey][report.MatchKey], summaryReport)
+		teamToMatchToReporters[report.TeamKey][report.MatchKey] = append(teamToMatchToReporters[report.TeamKey][report.MatchKey], reporterID)
 	}
 
 	teamToMatches := make(map[string][]summary.Match)
@@ -189,6 +332,7 @@ func selectTeamMatches(storeMatches []store.Match, reports []store.Report) map[s
 				RobotPosition:  position,
 				ScoreBreakdown: summary.ScoreBreakdown(breakdown),
 				Reports:        teamToMatchToReports[team][storeMatch.Key],
+				ReporterIDs:    teamToMatchToReporters[team][storeMatch.Key],
 			}
 
 			teamToMatches[team] = append(teamToMatches[team], match)
@@ -203,9 +347,11 @@ func storeSummaryToSummarySchema(storeSchema store.Schema) summary.Schema {
 
 	for _, statDescription := range storeSchema.Schema {
 		field := summary.SchemaField{
-			FieldDescriptor: summary.FieldDescriptor{Name: statDescription.FieldDescriptor.Name},
-			ReportReference: statDescription.ReportReference,
-			TBAReference:    statDescription.TBAReference,
+			FieldDescriptor:    summary.FieldDescriptor{Name: statDescription.FieldDescriptor.Name},
+			ReportReference:    statDescription.ReportReference,
+			TBAReference:       statDescription.TBAReference,
+			VerifyTBAReference: statDescription.VerifyTBAReference,
+			VerifyValues:       statDescription.VerifyValues,
 		}
 
 		for _, v := range statDescription.Sum {
@@ -226,8 +372,9 @@ func storeSummaryToSummarySchema(storeSchema store.Schema) summary.Schema {
 }
 
 type teamAnalysis struct {
-	Team    string        `json:"team"`
-	Summary []summaryStat `json:"summary"`
+	Team    string           `json:"team"`
+	Summary []summaryStat    `json:"summary"`
+	Tags    []store.TagCount `json:"tags"`
 }
 
 type summaryStat struct {
@@ -249,5 +396,26 @@ func teamAnalysisFromSummary(summary summary.Summary, team string) teamAnalysis
 	return teamAnalysis{
 		Team:    team,
 		Summary: stats,
+		Tags:    []store.TagCount{},
 	}
 }
+
+// addTagCounts sets the comment tag counts of each team analysis, adding analyses
+// for teams that have tagged comments but no reports.
+func addTagCounts(analyses []teamAnalysis, tagCounts map[string][]store.TagCount) []teamAnalysis {
+	seen := make(map[string]bool)
+	for i, analysis := range analyses {
+		seen[analysis.Team] = true
+		if counts, ok := tagCounts[analysis.Team]; ok {
+			analyses[i].Tags = counts
+		}
+	}
+
+	for team, counts := range tagCounts {
+		if !seen[team] {
+			analyses = append(analyses, teamAnalysis{Team: team, Summary: []summaryStat{}, Tags: counts})
+		}
+	}
+
+	return analyses
+}
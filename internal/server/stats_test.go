@@ -0,0 +1,63 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/Pigmice2733/peregrine-backend/internal/summary"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSelectTeamMatches(t *testing.T) {
+	reporterA, reporterB := int64(1), int64(2)
+
+	matches := []store.Match{
+		{
+			Key:                "2019orwil_qm1",
+			RedAlliance:        []string{"frc2733", "frc1418", "frc254"},
+			BlueAlliance:       []string{"frc1678", "frc4488", "frc2471"},
+			RedScoreBreakdown:  store.ScoreBreakdown{"endgameRobot1": "HabLevel3"},
+			BlueScoreBreakdown: store.ScoreBreakdown{"endgameRobot3": "None"},
+		},
+	}
+
+	reports := []store.Report{
+		{MatchKey: "2019orwil_qm1", TeamKey: "frc2733", ReporterID: &reporterA, Data: store.ReportData{{Name: "Cargo", Value: 3}}},
+		{MatchKey: "2019orwil_qm1", TeamKey: "frc2733", ReporterID: &reporterB, Data: store.ReportData{{Name: "Cargo", Value: 30}}, Excluded: true},
+		{MatchKey: "2019orwil_qm1", TeamKey: "frc2471", Data: store.ReportData{{Name: "Cargo", Value: 1}}},
+	}
+
+	teamToMatches := selectTeamMatches(matches, reports)
+
+	expected2733 := []summary.Match{
+		{
+			Key:            "2019orwil_qm1",
+			RobotPosition:  1,
+			ScoreBreakdown: summary.ScoreBreakdown{"endgameRobot1": "HabLevel3"},
+			Reports:        []summary.Report{{{Name: "Cargo", Value: 3}}},
+			ReporterIDs:    []int64{1},
+		},
+	}
+
+	if !cmp.Equal(expected2733, teamToMatches["frc2733"]) {
+		t.Errorf("expected frc2733 matches to match expected matches, but got diff: %s", cmp.Diff(expected2733, teamToMatches["frc2733"]))
+	}
+
+	expected2471 := []summary.Match{
+		{
+			Key:            "2019orwil_qm1",
+			RobotPosition:  3,
+			ScoreBreakdown: summary.ScoreBreakdown{"endgameRobot3": "None"},
+			Reports:        []summary.Report{{{Name: "Cargo", Value: 1}}},
+			ReporterIDs:    []int64{0},
+		},
+	}
+
+	if !cmp.Equal(expected2471, teamToMatches["frc2471"]) {
+		t.Errorf("expected frc2471 matches to match expected matches, but got diff: %s", cmp.Diff(expected2471, teamToMatches["frc2471"]))
+	}
+
+	if len(teamToMatches) != 6 {
+		t.Errorf("expected matches for 6 teams, got %d", len(teamToMatches))
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/gorilla/mux"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// validateCommentTags checks that every tag is in the realm's tag catalog.
+func validateCommentTags(catalog []store.CommentTag, tags []string) error {
+	inCatalog := make(map[string]bool)
+	for _, tag := range catalog {
+		inCatalog[tag.Name] = true
+	}
+
+	for _, tag := range tags {
+		if !inCatalog[tag] {
+			return fmt.Errorf("%q is not a comment tag in this realm", tag)
+		}
+	}
+
+	return nil
+}
+
+// uniqueStrings returns strs with duplicates removed, keeping the first of each.
+func uniqueStrings(strs []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(strs))
+	for _, s := range strs {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+
+	return unique
+}
+
+// getCommentTagsHandler returns a handler to get the comment tag catalog of the
+// current realm.
+func (s *Server) getCommentTagsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		tags, err := s.Store.GetCommentTags(r.Context(), realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving comment tags")
+			return
+		}
+
+		ihttp.Respond(w, tags, http.StatusOK)
+	}
+}
+
+// createCommentTagHandler returns a handler for admins to add a tag to the
+// comment tag catalog of their realm.
+func (s *Server) createCommentTagHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var tag store.CommentTag
+		if err := json.NewDecoder(r.Body).Decode(&tag); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validator.New().Struct(tag); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+		tag.RealmID = realmID
+
+		tag.ID, err = s.Store.CreateCommentTag(r.Context(), tag)
+		if errors.Is(err, store.ErrExists{}) {
+			ihttp.Error(w, http.StatusConflict)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("creating comment tag")
+			return
+		}
+
+		ihttp.Respond(w, tag, http.StatusCreated)
+	}
+}
+
+// deleteCommentTagHandler returns a handler for admins to remove a tag from the
+// comment tag catalog of their realm, which also removes it from every comment.
+func (s *Server) deleteCommentTagHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		realmID, err := ihttp.GetRealmID(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		err = s.Store.DeleteCommentTag(r.Context(), realmID, id)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("deleting comment tag")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Pigmice2733/peregrine-backend/internal/store"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidateCommentTags(t *testing.T) {
+	catalog := []store.CommentTag{{ID: 1, Name: "defense"}, {ID: 2, Name: "brownout"}}
+
+	testCases := []struct {
+		name        string
+		tags        []string
+		expectError bool
+	}{
+		{
+			name: "no tags",
+		},
+		{
+			name: "tags in catalog",
+			tags: []string{"brownout", "defense"},
+		},
+		{
+			name:        "tag not in catalog",
+			tags:        []string{"defense", "fast cycles"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCommentTags(catalog, tt.tags)
+			if !tt.expectError && err != nil {
+				t.Errorf("did not expect error but got: %v", err)
+			} else if tt.expectError && err == nil {
+				t.Errorf("expected error but didn't get one")
+			}
+		})
+	}
+}
+
+func TestAddTagCounts(t *testing.T) {
+	analyses := []teamAnalysis{
+		{Team: "frc1", Summary: []summaryStat{{Name: "Cargo", Max: 4, Average: 2}}, Tags: []store.TagCount{}},
+		{Team: "frc2", Summary: []summaryStat{}, Tags: []store.TagCount{}},
+	}
+
+	tagCounts := map[string][]store.TagCount{
+		"frc1": {{TeamKey: "frc1", Name: "defense", Count: 3}},
+		"frc3": {{TeamKey: "frc3", Name: "brownout", Count: 1}},
+	}
+
+	expected := []teamAnalysis{
+		{Team: "frc1", Summary: []summaryStat{{Name: "Cargo", Max: 4, Average: 2}}, Tags: []store.TagCount{{TeamKey: "frc1", Name: "defense", Count: 3}}},
+		{Team: "frc2", Summary: []summaryStat{}, Tags: []store.TagCount{}},
+		{Team: "frc3", Summary: []summaryStat{}, Tags: []store.TagCount{{TeamKey: "frc3", Name: "brownout", Count: 1}}},
+	}
+
+	got := addTagCounts(analyses, tagCounts)
+	if !cmp.Equal(expected, got) {
+		t.Errorf("expected analyses to match expected analyses, but got diff: %s", cmp.Diff(expected, got))
+	}
+}
+
+func TestUniqueStrings(t *testing.T) {
+	got := uniqueStrings([]string{"defense", "brownout", "defense"})
+	expected := []string{"defense", "brownout"}
+
+	if !cmp.Equal(expected, got) {
+		t.Errorf("expected strings to match expected strings, but got diff: %s", cmp.Diff(expected, got))
+	}
+}
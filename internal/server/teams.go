@@ -29,7 +29,14 @@ func (s *Server) teamHandler() http.HandlerFunc {
 	}
 }
 
-// eventTeamHandler returns a handler to get a specific team at a specific event.
+// eventTeam is a team at an event along with its pit reports.
+type eventTeam struct {
+	store.EventTeam
+	PitReports []store.PitReport `json:"pitReports"`
+}
+
+// eventTeamHandler returns a handler to get a specific team at a specific event,
+// including its pit reports.
 func (s *Server) eventTeamHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
@@ -51,7 +58,14 @@ func (s *Server) eventTeamHandler() http.HandlerFunc {
 			return
 		}
 
-		ihttp.Respond(w, team, http.StatusOK)
+		pitReports, err := s.Store.GetEventTeamPitReportsForRealm(r.Context(), eventKey, teamKey, realmID)
+		if err != nil {
+			ihttp.Error(w, http.StatusInternalServerError)
+			s.Logger.WithError(err).Error("retrieving pit reports")
+			return
+		}
+
+		ihttp.Respond(w, eventTeam{EventTeam: team, PitReports: pitReports}, http.StatusOK)
 	}
 }
 
@@ -5,11 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
 
 	ihttp "github.com/Pigmice2733/peregrine-backend/internal/http"
+	"github.com/Pigmice2733/peregrine-backend/internal/jwtkeys"
 	"github.com/Pigmice2733/peregrine-backend/internal/store"
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
@@ -25,28 +28,54 @@ type baseUser struct {
 
 type requestUser struct {
 	baseUser
-	RealmID   int64       `json:"realmId" validate:"required"`
-	FirstName string      `json:"firstName" validate:"required"`
-	LastName  string      `json:"lastName" validate:"required"`
-	Roles     store.Roles `json:"roles"`
-	Stars     []string    `json:"stars"`
+	RealmID    int64    `json:"realmId" validate:"required_without=InviteCode"`
+	InviteCode *string  `json:"inviteCode"`
+	FirstName  string   `json:"firstName" validate:"required"`
+	LastName   string   `json:"lastName" validate:"required"`
+	Roles      []string `json:"roles"`
+	Stars      []string `json:"stars"`
 }
 
 const (
 	accessTokenDuration  = time.Minute * 5        // 5 minutes
 	refreshTokenDuration = time.Hour * 24 * 7 * 4 // 4 weeks
 	bcryptCost           = 13
+	maxDeviceNameLength  = 64
 )
 
-func generateAccessToken(user store.User, expires time.Time, secret string) (string, error) {
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, &ihttp.Claims{
+func generateAccessToken(user store.User, sessionID int64, expires time.Time, keys *jwtkeys.Set) (string, error) {
+	// pending users aren't members of their realm yet, so they can't see its data
+	realmID := user.RealmID
+	if user.Pending {
+		realmID = 0
+	}
+
+	return keys.Sign(&ihttp.Claims{
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expires.Unix(),
 			Subject:   strconv.FormatInt(user.ID, 10),
 		},
-		Roles:   user.Roles,
-		RealmID: user.RealmID,
-	}).SignedString([]byte(secret))
+		Type:        ihttp.AccessTokenType,
+		Permissions: user.Permissions,
+		RealmID:     realmID,
+		SessionID:   sessionID,
+	})
+}
+
+// generateRefreshToken generates the refresh token for the current generation of
+// a session, for access tokens scoped to the user's realm.
+func generateRefreshToken(user store.User, session store.Session, keys *jwtkeys.Set) (string, error) {
+	return keys.Sign(&ihttp.RefreshClaims{
+		StandardClaims: jwt.StandardClaims{
+			ExpiresAt: session.ExpiresAt.Unix(),
+			Subject:   strconv.FormatInt(user.ID, 10),
+		},
+		Type:            ihttp.RefreshTokenType,
+		PasswordChanged: user.PasswordChanged.Unix(),
+		RealmID:         user.RealmID,
+		SessionID:       session.ID,
+		Generation:      session.Generation,
+	})
 }
 
 type authenticateResponse struct {
@@ -54,30 +83,130 @@ type authenticateResponse struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
+// generateTokens generates an access token and a refresh token for a session.
+func generateTokens(user store.User, session store.Session, now time.Time, keys *jwtkeys.Set) (authenticateResponse, error) {
+	accessToken, err := generateAccessToken(user, session.ID, now.Add(accessTokenDuration), keys)
+	if err != nil {
+		return authenticateResponse{}, fmt.Errorf("unable to generate access token: %w", err)
+	}
+
+	refreshToken, err := generateRefreshToken(user, session, keys)
+	if err != nil {
+		return authenticateResponse{}, fmt.Errorf("unable to generate refresh token: %w", err)
+	}
+
+	return authenticateResponse{accessToken, refreshToken}, nil
+}
+
+// remoteIP returns the IP address a request was made from.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// newSession returns a session for a user logging in with a request. The device
+// name defaults to the request's user agent.
+func newSession(r *http.Request, userID int64, deviceName string, expires time.Time) store.Session {
+	if deviceName == "" {
+		deviceName = r.Header.Get("User-Agent")
+	}
+	if len(deviceName) > maxDeviceNameLength {
+		deviceName = deviceName[:maxDeviceNameLength]
+	}
+
+	return store.Session{
+		UserID:     userID,
+		DeviceName: deviceName,
+		IP:         remoteIP(r),
+		ExpiresAt:  expires,
+	}
+}
+
 // UserByNameGetter is used for retrieving users from a store by username. It should
 // return store.ErrNoResults if there was no associated user.
 type UserByNameGetter interface {
 	GetUserByUsername(ctx context.Context, username string) (user store.User, err error)
 }
 
-func authenticateHandler(logger *logrus.Logger, now func() time.Time, userStore UserByNameGetter, secret string) http.HandlerFunc {
+// SessionStore is used for creating sessions when users log in, and rotating
+// their refresh tokens. RotateSession should return store.ErrNoResults if the
+// session doesn't exist, was revoked, or the refresh token was already used.
+type SessionStore interface {
+	CreateSession(ctx context.Context, session store.Session) (id int64, err error)
+	RotateSession(ctx context.Context, userID, id, generation int64, ip string, expiresAt time.Time) (newGeneration int64, err error)
+}
+
+// LoginLimiter is used for locking out usernames and IP addresses after repeated
+// failed logins. Check and Fail return when the lockout ends, or the zero time
+// if there isn't one.
+type LoginLimiter interface {
+	Check(ctx context.Context, username, ip string, now time.Time) (lockedUntil time.Time, err error)
+	Fail(ctx context.Context, username, ip string, now time.Time) (lockedUntil time.Time, err error)
+	Unlock(ctx context.Context, username string) error
+}
+
+// retryAfter responds that a login is locked out until the given time.
+func retryAfter(w http.ResponseWriter, now, lockedUntil time.Time) {
+	seconds := int64(math.Ceil(lockedUntil.Sub(now).Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	ihttp.Error(w, http.StatusTooManyRequests)
+}
+
+type authenticateRequest struct {
+	baseUser
+	DeviceName string `json:"deviceName"`
+}
+
+func authenticateHandler(logger *logrus.Logger, now func() time.Time, userStore UserByNameGetter, sessions SessionStore, limiter LoginLimiter, keys *jwtkeys.Set) http.HandlerFunc {
 	validate := validator.New()
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		var ru baseUser
-		if err := json.NewDecoder(r.Body).Decode(&ru); err != nil {
+		var ar authenticateRequest
+		if err := json.NewDecoder(r.Body).Decode(&ar); err != nil {
 			ihttp.Error(w, http.StatusUnprocessableEntity)
 			return
 		}
 
+		ru := ar.baseUser
 		if err := validate.Struct(ru); err != nil {
 			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
 			return
 		}
 
+		ip := remoteIP(r)
+		securityLogger := logger.WithFields(logrus.Fields{"username": ru.Username, "ip": ip})
+
+		lockedUntil, err := limiter.Check(r.Context(), ru.Username, ip, now())
+		if err != nil {
+			logger.WithError(err).Error("checking login lockout")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		} else if !lockedUntil.IsZero() {
+			securityLogger.WithField("lockedUntil", lockedUntil).Warn("rejected login while locked out")
+			retryAfter(w, now(), lockedUntil)
+			return
+		}
+
+		fail := func() {
+			securityLogger.Warn("failed login")
+
+			lockedUntil, err := limiter.Fail(r.Context(), ru.Username, ip, now())
+			if err != nil {
+				logger.WithError(err).Error("recording failed login")
+			} else if !lockedUntil.IsZero() {
+				securityLogger.WithField("lockedUntil", lockedUntil).Warn("locked out login")
+			}
+
+			ihttp.Error(w, http.StatusUnauthorized)
+		}
+
 		user, err := userStore.GetUserByUsername(r.Context(), ru.Username)
 		if errors.Is(err, store.ErrNoResults{}) {
-			ihttp.Error(w, http.StatusUnauthorized)
+			fail()
 			return
 		} else if err != nil {
 			logger.WithError(err).Error("retrieving user from database")
@@ -85,9 +214,10 @@ func authenticateHandler(logger *logrus.Logger, now func() time.Time, userStore
 			return
 		}
 
+		// users who only log in with OpenID Connect have no password
 		err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(ru.Password))
-		if err == bcrypt.ErrMismatchedHashAndPassword {
-			ihttp.Error(w, http.StatusUnauthorized)
+		if err == bcrypt.ErrMismatchedHashAndPassword || err == bcrypt.ErrHashTooShort {
+			fail()
 			return
 		} else if err != nil {
 			logger.WithError(err).Error("comparing user hash and password")
@@ -95,45 +225,68 @@ func authenticateHandler(logger *logrus.Logger, now func() time.Time, userStore
 			return
 		}
 
-		accessToken, err := generateAccessToken(user, now().Add(accessTokenDuration), secret)
+		if err := limiter.Unlock(r.Context(), ru.Username); err != nil {
+			logger.WithError(err).Error("resetting failed logins")
+		}
+
+		session := newSession(r, user.ID, ar.DeviceName, now().Add(refreshTokenDuration))
+		session.ID, err = sessions.CreateSession(r.Context(), session)
 		if err != nil {
-			logger.WithError(err).Error("generating jwt access token signed string")
+			logger.WithError(err).Error("creating session")
 			ihttp.Error(w, http.StatusInternalServerError)
 			return
 		}
 
-		refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &ihttp.RefreshClaims{
-			StandardClaims: jwt.StandardClaims{
-				ExpiresAt: now().Add(refreshTokenDuration).Unix(),
-				Subject:   strconv.FormatInt(user.ID, 10),
-			},
-			PasswordChanged: user.PasswordChanged.Unix(),
-		}).SignedString([]byte(secret))
+		tokens, err := generateTokens(user, session, now(), keys)
 		if err != nil {
-			logger.WithError(err).Error("generating jwt refresh token signed string")
+			logger.WithError(err).Error("generating jwt signed strings")
 			ihttp.Error(w, http.StatusInternalServerError)
 			return
 		}
 
-		ihttp.Respond(w, authenticateResponse{accessToken, refreshToken}, http.StatusOK)
+		ihttp.Respond(w, tokens, http.StatusOK)
 	}
 }
 
-// UserByIDGetter is used for retrieving users by ID. It should return store.ErrNoResults
-// if there is no associated user.
+// UserByIDGetter is used for retrieving users by ID, optionally with their roles and
+// permissions in a specific realm. It should return store.ErrNoResults if there is
+// no associated user, or the user isn't a member of the realm.
 type UserByIDGetter interface {
 	GetUserByID(ctx context.Context, id int64) (user store.User, err error)
+	GetUserInRealm(ctx context.Context, id, realmID int64) (user store.User, err error)
 }
 
 type refreshRequest struct {
 	RefreshToken string `json:"refreshToken"`
 }
 
-type refreshResponse struct {
-	AccessToken string `json:"accessToken"`
+// parseRefreshToken parses and validates a refresh token, and returns its claims
+// and the ID of the user it was issued to.
+func parseRefreshToken(refreshToken string, keys *jwtkeys.Set) (*ihttp.RefreshClaims, int64, error) {
+	token, err := jwt.ParseWithClaims(refreshToken, &ihttp.RefreshClaims{}, keys.Keyfunc)
+	if err != nil {
+		return nil, 0, err
+	} else if !token.Valid {
+		return nil, 0, errors.New("invalid refresh token")
+	}
+
+	claims, ok := token.Claims.(*ihttp.RefreshClaims)
+	if !ok || claims.Type != ihttp.RefreshTokenType {
+		return nil, 0, errors.New("invalid refresh token claims")
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, 0, errors.New("invalid refresh token subject")
+	}
+
+	return claims, userID, nil
 }
 
-func refreshHandler(logger *logrus.Logger, now func() time.Time, userStore UserByIDGetter, secret string) http.HandlerFunc {
+// refreshHandler returns a handler that exchanges a refresh token for a new
+// access token and refresh token. Each refresh token can only be used once, and
+// using one again revokes its session.
+func refreshHandler(logger *logrus.Logger, now func() time.Time, userStore UserByIDGetter, sessions SessionStore, keys *jwtkeys.Set) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var rr refreshRequest
 		if err := json.NewDecoder(r.Body).Decode(&rr); err != nil {
@@ -141,36 +294,129 @@ func refreshHandler(logger *logrus.Logger, now func() time.Time, userStore UserB
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(rr.RefreshToken, &ihttp.RefreshClaims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
+		claims, userID, err := parseRefreshToken(rr.RefreshToken, keys)
+		if err != nil {
+			ihttp.Error(w, http.StatusUnauthorized)
+			return
+		}
 
-			return []byte(secret), nil
-		})
-		if err != nil || !token.Valid {
+		// refresh tokens issued before sessions were tracked can't be revoked
+		if claims.SessionID == 0 {
 			ihttp.Error(w, http.StatusUnauthorized)
 			return
 		}
 
-		claims, ok := token.Claims.(*ihttp.RefreshClaims)
-		if !ok {
+		var user store.User
+		if claims.RealmID != 0 {
+			user, err = userStore.GetUserInRealm(r.Context(), userID, claims.RealmID)
+		} else {
+			user, err = userStore.GetUserByID(r.Context(), userID)
+		}
+		if errors.Is(err, store.ErrNoResults{}) {
 			ihttp.Error(w, http.StatusUnauthorized)
 			return
+		} else if err != nil {
+			logger.WithError(err).Error("retrieving user from database")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		// user password has been updated since refresh token was issued
+		if user.PasswordChanged.Unix() != claims.PasswordChanged {
+			ihttp.Error(w, http.StatusForbidden)
+			return
+		}
+
+		session := store.Session{ID: claims.SessionID, ExpiresAt: now().Add(refreshTokenDuration)}
+		session.Generation, err = sessions.RotateSession(r.Context(), userID, session.ID, claims.Generation, remoteIP(r), session.ExpiresAt)
+		if errors.Is(err, store.ErrNoResults{}) {
+			logger.WithField("sessionId", session.ID).Warn("refresh token for revoked session or reused")
+			ihttp.Error(w, http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			logger.WithError(err).Error("rotating session")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		tokens, err := generateTokens(user, session, now(), keys)
+		if err != nil {
+			logger.WithError(err).Error("generating jwt signed strings")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
 		}
 
-		userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+		ihttp.Respond(w, tokens, http.StatusOK)
+	}
+}
+
+// logoutHandler returns a handler that revokes the session a refresh token
+// belongs to.
+func (s *Server) logoutHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rr refreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&rr); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		claims, userID, err := parseRefreshToken(rr.RefreshToken, s.Keys)
 		if err != nil {
 			ihttp.Error(w, http.StatusUnauthorized)
 			return
 		}
 
-		user, err := userStore.GetUserByID(r.Context(), userID)
-		if errors.Is(err, store.ErrNoResults{}) {
+		err = s.Store.RevokeSession(r.Context(), userID, claims.SessionID)
+		if err != nil && !errors.Is(err, store.ErrNoResults{}) {
+			s.Logger.WithError(err).Error("revoking session")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// switchRealmHandler returns a handler to get new access and refresh tokens
+// scoped to another realm the user is a member of. The current refresh token is
+// rotated the same way as when refreshing, so an access token alone can't be
+// used to get a new refresh token.
+func (s *Server) switchRealmHandler() http.HandlerFunc {
+	type switchRealmRequest struct {
+		RealmID      int64  `json:"realmId" validate:"required"`
+		RefreshToken string `json:"refreshToken" validate:"required"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var sr switchRealmRequest
+		if err := json.NewDecoder(r.Body).Decode(&sr); err != nil {
+			ihttp.Error(w, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if err := validator.New().Struct(sr); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		userID, err := ihttp.GetSubject(r)
+		if err != nil {
+			ihttp.Error(w, http.StatusUnauthorized)
+			return
+		}
+
+		claims, refreshUserID, err := parseRefreshToken(sr.RefreshToken, s.Keys)
+		if err != nil || refreshUserID != userID || claims.SessionID == 0 {
 			ihttp.Error(w, http.StatusUnauthorized)
 			return
+		}
+
+		user, err := s.Store.GetUserInRealm(r.Context(), userID, sr.RealmID)
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusForbidden)
+			return
 		} else if err != nil {
-			logger.WithError(err).Error("retrieving user from database")
+			s.Logger.WithError(err).Error("retrieving user from database")
 			ihttp.Error(w, http.StatusInternalServerError)
 			return
 		}
@@ -181,14 +427,26 @@ func refreshHandler(logger *logrus.Logger, now func() time.Time, userStore UserB
 			return
 		}
 
-		accessToken, err := generateAccessToken(user, now().Add(accessTokenDuration), secret)
+		session := store.Session{ID: claims.SessionID, ExpiresAt: time.Now().Add(refreshTokenDuration)}
+		session.Generation, err = s.Store.RotateSession(r.Context(), userID, session.ID, claims.Generation, remoteIP(r), session.ExpiresAt)
+		if errors.Is(err, store.ErrNoResults{}) {
+			s.Logger.WithField("sessionId", session.ID).Warn("refresh token for revoked session or reused")
+			ihttp.Error(w, http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("rotating session")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		tokens, err := generateTokens(user, session, time.Now(), s.Keys)
 		if err != nil {
-			logger.WithError(err).Error("generating jwt access token signed string")
+			s.Logger.WithError(err).Error("generating jwt signed strings")
 			ihttp.Error(w, http.StatusInternalServerError)
 			return
 		}
 
-		ihttp.Respond(w, refreshResponse{accessToken}, http.StatusOK)
+		ihttp.Respond(w, tokens, http.StatusOK)
 	}
 }
 
@@ -200,31 +458,56 @@ func (s *Server) createUserHandler() http.HandlerFunc {
 			return
 		}
 
-		// If the creator user isn't an admin, reset their roles
-		roles := ihttp.GetRoles(r)
-		if !roles.IsAdmin && !roles.IsSuperAdmin {
-			ru.Roles = store.Roles{}
+		if err := validator.New().Struct(ru); err != nil {
+			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+			return
 		}
 
-		// Only super-admins can create super-admins
-		if !roles.IsSuperAdmin {
-			ru.Roles.IsSuperAdmin = false
-		}
+		pending := false
+
+		if ru.InviteCode != nil {
+			// Users joining with an invite get the invite's realm and roles
+			invite, err := s.Store.GetRealmInviteByCode(r.Context(), *ru.InviteCode)
+			if errors.Is(err, store.ErrNoResults{}) {
+				ihttp.Respond(w, errors.New("invite code is invalid or expired"), http.StatusUnprocessableEntity)
+				return
+			} else if err != nil {
+				s.Logger.WithError(err).Error("retrieving realm invite")
+				ihttp.Error(w, http.StatusInternalServerError)
+				return
+			}
+
+			ru.RealmID = invite.RealmID
+			ru.Roles = invite.Roles
+		} else {
+			// Only users who can manage users in the new user's realm can give them
+			// roles. Otherwise the new user asks to join the realm, and has no roles
+			// until their request is approved.
+			permissions := ihttp.GetPermissions(r)
+			realmID, err := ihttp.GetRealmID(r)
+			if !permissions.Has(store.PermissionManageAll) && (err != nil || realmID != ru.RealmID || !permissions.Has(store.PermissionManageUsers)) {
+				ru.Roles = nil
+				pending = true
+			}
 
-		// Only super-admins can create verified users in realms other than their own.
-		if !roles.IsSuperAdmin {
-			if id, err := ihttp.GetRealmID(r); err != nil || id != ru.RealmID {
-				ru.Roles.IsVerified = false
-				ru.Roles.IsAdmin = false
+			if !s.checkGrantableRoles(w, r, permissions, ru.RealmID, ru.Roles) {
+				return
 			}
 		}
 
-		if err := validator.New().Struct(ru); err != nil {
+		policy, err := s.Store.GetPasswordPolicy(r.Context(), ru.RealmID)
+		if err != nil {
+			s.Logger.WithError(err).Error("retrieving password policy")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		if err := checkPassword(policy, ru.Password); err != nil {
 			ihttp.Respond(w, err, http.StatusUnprocessableEntity)
 			return
 		}
 
-		err := s.Store.CheckSimilarUsernameExists(r.Context(), ru.Username, nil)
+		err = s.Store.CheckSimilarUsernameExists(r.Context(), ru.Username, nil)
 		if errors.Is(err, store.ErrExists{}) {
 			ihttp.Error(w, http.StatusConflict)
 			return
@@ -234,7 +517,7 @@ func (s *Server) createUserHandler() http.HandlerFunc {
 			return
 		}
 
-		u := store.User{Username: ru.Username, RealmID: ru.RealmID, Roles: ru.Roles, Stars: ru.Stars, FirstName: ru.FirstName, LastName: ru.LastName}
+		u := store.User{Username: ru.Username, RealmID: ru.RealmID, Roles: ru.Roles, Pending: pending, Stars: ru.Stars, FirstName: ru.FirstName, LastName: ru.LastName}
 
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(ru.Password), bcryptCost)
 		if err != nil {
@@ -250,7 +533,7 @@ func (s *Server) createUserHandler() http.HandlerFunc {
 		if errors.Is(err, store.ErrExists{}) {
 			ihttp.Error(w, http.StatusConflict)
 			return
-		} else if errors.Is(err, store.ErrFKeyViolation{}) {
+		} else if errors.Is(err, store.ErrFKeyViolation{}) || errors.Is(err, store.ErrNoResults{}) {
 			ihttp.Error(w, http.StatusUnprocessableEntity)
 			return
 		} else if err != nil {
@@ -265,12 +548,12 @@ func (s *Server) createUserHandler() http.HandlerFunc {
 
 func (s *Server) getUsersHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
 
 		var users []store.User
 		var err error
 
-		if roles.IsSuperAdmin {
+		if permissions.Has(store.PermissionManageAll) {
 			users, err = s.Store.GetUsers(r.Context())
 		} else {
 			var realmID int64
@@ -300,15 +583,30 @@ func (s *Server) getUserByIDHandler() http.HandlerFunc {
 			return
 		}
 
-		realmID, err := ihttp.GetSubject(r)
+		subjectID, err := ihttp.GetSubject(r)
 		if err != nil {
 			ihttp.Error(w, http.StatusForbidden)
 			return
 		}
 
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
+
+		// only allow users to get other users within their realm if they aren't a super
+		// admin
+		var user store.User
+		if permissions.Has(store.PermissionManageAll) || subjectID == id {
+			user, err = s.Store.GetUserByID(r.Context(), id)
+		} else {
+			var realmID int64
+			realmID, err = ihttp.GetRealmID(r)
+			if err != nil {
+				ihttp.Error(w, http.StatusNotFound)
+				return
+			}
+
+			user, err = s.Store.GetUserInRealm(r.Context(), id, realmID)
+		}
 
-		user, err := s.Store.GetUserByID(r.Context(), id)
 		if errors.Is(err, store.ErrNoResults{}) {
 			ihttp.Error(w, http.StatusNotFound)
 			return
@@ -318,27 +616,17 @@ func (s *Server) getUserByIDHandler() http.HandlerFunc {
 			return
 		}
 
-		// only allow users to get other users within their realm if they aren't a super
-		// admin
-		if !roles.IsSuperAdmin && realmID != user.RealmID {
-			if realmID, err := ihttp.GetRealmID(r); err != nil || realmID != user.RealmID {
-				ihttp.Error(w, http.StatusNotFound)
-				return
-			}
-		}
-
 		ihttp.Respond(w, user, http.StatusOK)
 	}
 }
 
 func (s *Server) patchUserHandler() http.HandlerFunc {
 	type patchUser struct {
-		Username  *string      `json:"username" validate:"omitempty,gte=4,lte=32,alphanum"`
-		Password  *string      `json:"password" validate:"omitempty,gte=8,lte=128"`
-		FirstName *string      `json:"firstName" validate:"omitempty,gte=0"`
-		LastName  *string      `json:"lastName" validate:"omitempty,gte=0"`
-		Roles     *store.Roles `json:"roles"`
-		Stars     []string     `json:"stars"`
+		Username  *string  `json:"username" validate:"omitempty,gte=4,lte=32,alphanum"`
+		Password  *string  `json:"password" validate:"omitempty,gte=8,lte=128"`
+		FirstName *string  `json:"firstName" validate:"omitempty,gte=0"`
+		LastName  *string  `json:"lastName" validate:"omitempty,gte=0"`
+		Stars     []string `json:"stars"`
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -353,9 +641,9 @@ func (s *Server) patchUserHandler() http.HandlerFunc {
 			ihttp.Error(w, http.StatusForbidden)
 			return
 		}
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
 
-		if targetID != subjectID && !roles.IsAdmin && !roles.IsSuperAdmin {
+		if targetID != subjectID && !permissions.Has(store.PermissionManageUsers) {
 			ihttp.Error(w, http.StatusForbidden)
 			return
 		}
@@ -366,28 +654,28 @@ func (s *Server) patchUserHandler() http.HandlerFunc {
 			return
 		}
 
-		// Admins can only patch users in the same realm
-		if targetID != subjectID && !roles.IsSuperAdmin {
-			targetUser, err := s.Store.GetUserByID(r.Context(), targetID)
+		// Users who can manage users can only patch users in the same realm, and
+		// can only change the credentials of users who are only in their realm
+		if targetID != subjectID && !permissions.Has(store.PermissionManageAll) {
+			realmID, err := ihttp.GetRealmID(r)
 			if err != nil {
-				s.Logger.WithError(err).Error("getting user")
-				ihttp.Error(w, http.StatusInternalServerError)
+				ihttp.Error(w, http.StatusForbidden)
 				return
 			}
-			if realmID, err := ihttp.GetRealmID(r); err != nil || realmID != targetUser.RealmID {
+
+			user, err := s.Store.GetUserInRealm(r.Context(), targetID, realmID)
+			if errors.Is(err, store.ErrNoResults{}) {
 				ihttp.Error(w, http.StatusForbidden)
 				return
+			} else if err != nil {
+				s.Logger.WithError(err).Error("getting user")
+				ihttp.Error(w, http.StatusInternalServerError)
+				return
 			}
-		}
 
-		// If the creator user isn't an admin, reset roles
-		if !roles.IsAdmin && !roles.IsSuperAdmin {
-			ru.Roles = nil
-		}
-
-		// Only super-admins can create super-admins
-		if ru.Roles != nil && !roles.IsSuperAdmin {
-			ru.Roles.IsSuperAdmin = false
+			if (ru.Username != nil || ru.Password != nil) && !s.checkCredentialsManageable(w, r, permissions, user) {
+				return
+			}
 		}
 
 		if err := validator.New().Struct(ru); err != nil {
@@ -407,9 +695,21 @@ func (s *Server) patchUserHandler() http.HandlerFunc {
 			}
 		}
 
-		u := store.PatchUser{ID: targetID, Username: ru.Username, Roles: ru.Roles, FirstName: ru.FirstName, LastName: ru.LastName, Stars: ru.Stars}
+		u := store.PatchUser{ID: targetID, Username: ru.Username, FirstName: ru.FirstName, LastName: ru.LastName, Stars: ru.Stars}
 
 		if ru.Password != nil {
+			policy, err := s.Store.GetUserPasswordPolicy(r.Context(), targetID)
+			if err != nil {
+				s.Logger.WithError(err).Error("retrieving password policy")
+				ihttp.Error(w, http.StatusInternalServerError)
+				return
+			}
+
+			if err := checkPassword(policy, *ru.Password); err != nil {
+				ihttp.Respond(w, err, http.StatusUnprocessableEntity)
+				return
+			}
+
 			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*ru.Password), bcryptCost)
 			if err != nil {
 				s.Logger.WithError(err).Error("hashing user password")
@@ -452,15 +752,17 @@ func (s *Server) deleteUserHandler() http.HandlerFunc {
 			return
 		}
 
-		roles := ihttp.GetRoles(r)
+		permissions := ihttp.GetPermissions(r)
 
-		// only allow people to delete themselves if they're not an admin or super admin
-		if id != requesterSubject && !roles.IsAdmin && !roles.IsSuperAdmin {
+		// only allow people to delete themselves if they can't manage users
+		if id != requesterSubject && !permissions.Has(store.PermissionManageUsers) {
 			ihttp.Error(w, http.StatusForbidden)
 			return
 		}
 
-		if roles.IsSuperAdmin {
+		// users deleting themselves delete their account, other users are only
+		// removed from the current realm
+		if permissions.Has(store.PermissionManageAll) || id == requesterSubject {
 			err = s.Store.DeleteUserByID(r.Context(), id)
 		} else {
 			var realmID int64
@@ -485,3 +787,53 @@ func (s *Server) deleteUserHandler() http.HandlerFunc {
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
+
+// unlockUserHandler returns a handler to unlock a user who was locked out after
+// repeated failed logins. Users who can manage users can unlock users in their
+// realm.
+func (s *Server) unlockUserHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			ihttp.Error(w, http.StatusBadRequest)
+			return
+		}
+
+		var user store.User
+		if ihttp.GetPermissions(r).Has(store.PermissionManageAll) {
+			user, err = s.Store.GetUserByID(r.Context(), id)
+		} else {
+			var realmID int64
+			realmID, err = ihttp.GetRealmID(r)
+			if err != nil {
+				ihttp.Error(w, http.StatusForbidden)
+				return
+			}
+
+			user, err = s.Store.GetUserInRealm(r.Context(), id, realmID)
+		}
+
+		if errors.Is(err, store.ErrNoResults{}) {
+			ihttp.Error(w, http.StatusNotFound)
+			return
+		} else if err != nil {
+			s.Logger.WithError(err).Error("retrieving user")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		if err := s.Lockout.Unlock(r.Context(), user.Username); err != nil {
+			s.Logger.WithError(err).Error("unlocking user")
+			ihttp.Error(w, http.StatusInternalServerError)
+			return
+		}
+
+		requesterSubject, _ := ihttp.GetSubject(r)
+		s.Logger.WithFields(logrus.Fields{
+			"username":   user.Username,
+			"unlockedBy": requesterSubject,
+		}).Warn("unlocked login")
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
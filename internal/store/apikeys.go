@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKey lets integrations such as scripts and bots call the API without a
+// user's password. Keys act as their creator in a single realm, but only with
+// the permissions in Scopes that the creator still has there. Only the hash of
+// the key is stored.
+type APIKey struct {
+	ID                 int64       `json:"id" db:"id"`
+	RealmID            int64       `json:"realmId" db:"realm_id"`
+	CreatorID          int64       `json:"creatorId" db:"creator_id"`
+	Name               string      `json:"name" db:"name" validate:"gte=1,lte=64"`
+	KeyHash            string      `json:"-" db:"key_hash"`
+	Scopes             Permissions `json:"scopes" db:"scopes"`
+	CreatorPermissions Permissions `json:"-" db:"creator_permissions"`
+	LastUsedAt         *time.Time  `json:"lastUsedAt" db:"last_used_at"`
+	RevokedAt          *time.Time  `json:"revokedAt" db:"revoked_at"`
+	CreatedAt          time.Time   `json:"createdAt" db:"created_at"`
+}
+
+// GetAPIKeys returns the API keys for a realm, including revoked ones, newest
+// first.
+func (s *Service) GetAPIKeys(ctx context.Context, realmID int64) ([]APIKey, error) {
+	keys := []APIKey{}
+	err := s.db.SelectContext(ctx, &keys, `
+	SELECT id, realm_id, creator_id, name, key_hash, scopes, last_used_at, revoked_at, created_at
+	FROM api_keys
+	WHERE realm_id = $1
+	ORDER BY created_at DESC, id DESC
+	`, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// CreateAPIKey creates an API key and returns its ID and when it was created. If
+// the creator isn't a member of the realm ErrFKeyViolation is returned, and if a
+// key with the same hash already exists ErrExists is returned.
+func (s *Service) CreateAPIKey(ctx context.Context, key APIKey) (int64, time.Time, error) {
+	var created struct {
+		ID        int64     `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+
+	err := s.db.GetContext(ctx, &created, `
+	INSERT INTO api_keys (realm_id, creator_id, name, key_hash, scopes)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at
+	`, key.RealmID, key.CreatorID, key.Name, key.KeyHash, key.Scopes)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgExists {
+		return 0, created.CreatedAt, ErrExists{fmt.Errorf("api key already exists: %w", err)}
+	} else if ok && pqErr.Code == pgFKeyViolation {
+		return 0, created.CreatedAt, ErrFKeyViolation{fmt.Errorf("api key fk violation: %w", err)}
+	} else if err != nil {
+		return 0, created.CreatedAt, fmt.Errorf("unable to insert api key: %w", err)
+	}
+
+	return created.ID, created.CreatedAt, nil
+}
+
+// RevokeAPIKey revokes an API key so it can no longer be used. If the key doesn't
+// exist in the realm or is already revoked ErrNoResults is returned.
+func (s *Service) RevokeAPIKey(ctx context.Context, realmID, id int64) error {
+	result, err := s.db.ExecContext(ctx, `
+	UPDATE api_keys
+	SET revoked_at = now()
+	WHERE realm_id = $1 AND id = $2 AND revoked_at IS NULL
+	`, realmID, id)
+	if err != nil {
+		return fmt.Errorf("unable to revoke api key: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("api key does not exist")}
+	}
+
+	return nil
+}
+
+// UseAPIKey retrieves the API key with the given hash along with its creator's
+// current permissions in its realm, and records that it was used. If the key
+// doesn't exist or has been revoked ErrNoResults is returned.
+func (s *Service) UseAPIKey(ctx context.Context, keyHash string) (APIKey, error) {
+	var key APIKey
+	err := s.db.GetContext(ctx, &key, `
+	WITH used AS (
+		UPDATE api_keys
+		SET last_used_at = now()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING *
+	)
+	SELECT
+		used.*,
+		ARRAY(
+			SELECT DISTINCT unnest(roles.permissions)
+			FROM user_roles
+			INNER JOIN roles
+				ON roles.id = user_roles.role_id
+			WHERE user_roles.user_id = used.creator_id AND user_roles.realm_id = used.realm_id
+		) AS creator_permissions
+	FROM used
+	`, keyHash)
+	if err == sql.ErrNoRows {
+		return key, ErrNoResults{fmt.Errorf("api key does not exist: %w", err)}
+	} else if err != nil {
+		return key, fmt.Errorf("unable to use api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// Permissions returns the permissions requests made with the key have, which are
+// the key's scopes that its creator has.
+func (k APIKey) Permissions() Permissions {
+	permissions := Permissions{}
+	for _, p := range k.Scopes {
+		if k.CreatorPermissions.Has(p) {
+			permissions = append(permissions, p)
+		}
+	}
+
+	return permissions
+}
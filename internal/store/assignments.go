@@ -0,0 +1,288 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ScoutShift is a period of time a scout is available at an event.
+type ScoutShift struct {
+	UserID int64     `json:"-" db:"user_id"`
+	Start  time.Time `json:"start" db:"start_time"`
+	End    time.Time `json:"end" db:"end_time"`
+}
+
+// EventScout is a user that is available to scout an event for a realm. A scout
+// with no shifts is available for the whole event.
+type EventScout struct {
+	UserID int64        `json:"userId" db:"user_id"`
+	Shifts []ScoutShift `json:"shifts"`
+}
+
+// ScoutAssignment assigns a user to scout a team in a match. Time is the actual
+// time of the match if it has been played, otherwise the predicted or scheduled
+// time.
+type ScoutAssignment struct {
+	MatchKey string     `json:"matchKey" db:"match_key"`
+	TeamKey  string     `json:"teamKey" db:"team_key"`
+	UserID   int64      `json:"userId" db:"user_id"`
+	Time     *time.Time `json:"time,omitempty" db:"match_time"`
+}
+
+// ScheduleMatch holds the information needed to assign scouts to a match.
+type ScheduleMatch struct {
+	Key   string         `db:"key"`
+	Time  *time.Time     `db:"match_time"`
+	Teams pq.StringArray `db:"teams"`
+}
+
+// Slot is a single team in a single match, along with who was assigned to
+// scout it.
+type Slot struct {
+	MatchKey string `db:"match_key"`
+	TeamKey  string `db:"team_key"`
+	ScoutID  *int64 `db:"scout_id"`
+	Played   bool   `db:"played"`
+}
+
+// GetEventScouts returns all scouts and their shifts for an event in a realm.
+func (s *Service) GetEventScouts(ctx context.Context, eventKey string, realmID int64) ([]EventScout, error) {
+	scouts := []EventScout{}
+	err := s.db.SelectContext(ctx, &scouts, `
+	SELECT user_id
+	FROM scouts
+	WHERE event_key = $1 AND realm_id = $2
+	ORDER BY user_id
+	`, eventKey, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select scouts: %w", err)
+	}
+
+	shifts := []ScoutShift{}
+	err = s.db.SelectContext(ctx, &shifts, `
+	SELECT user_id, start_time, end_time
+	FROM scout_shifts
+	WHERE event_key = $1 AND realm_id = $2
+	ORDER BY start_time
+	`, eventKey, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select scout shifts: %w", err)
+	}
+
+	for i := range scouts {
+		scouts[i].Shifts = []ScoutShift{}
+		for _, shift := range shifts {
+			if shift.UserID == scouts[i].UserID {
+				scouts[i].Shifts = append(scouts[i].Shifts, shift)
+			}
+		}
+	}
+
+	return scouts, nil
+}
+
+// SetEventScouts replaces the scouts and shifts for an event in a realm. If any
+// of the scouts are not members of the realm an ErrFKeyViolation is returned.
+func (s *Service) SetEventScouts(ctx context.Context, eventKey string, realmID int64, scouts []EventScout) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		userIDs := make([]int64, len(scouts))
+		for i, scout := range scouts {
+			userIDs[i] = scout.UserID
+		}
+
+		var realmUsers int
+		err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM realm_memberships
+		WHERE user_id = ANY($1) AND realm_id = $2 AND pending = false
+		`, pq.Array(userIDs), realmID).Scan(&realmUsers)
+		if err != nil {
+			return fmt.Errorf("unable to count realm users: %w", err)
+		}
+
+		if realmUsers != len(userIDs) {
+			return ErrFKeyViolation{fmt.Errorf("scouts must be unique users in realm %d", realmID)}
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM scouts WHERE event_key = $1 AND realm_id = $2", eventKey, realmID); err != nil {
+			return fmt.Errorf("unable to remove scouts: %w", err)
+		}
+
+		scoutStmt, err := tx.PrepareContext(ctx, "INSERT INTO scouts (event_key, realm_id, user_id) VALUES ($1, $2, $3)")
+		if err != nil {
+			return fmt.Errorf("unable to prepare scouts insert statement: %w", err)
+		}
+		defer scoutStmt.Close()
+
+		shiftStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO scout_shifts (event_key, realm_id, user_id, start_time, end_time)
+		VALUES ($1, $2, $3, $4, $5)
+		`)
+		if err != nil {
+			return fmt.Errorf("unable to prepare scout shifts insert statement: %w", err)
+		}
+		defer shiftStmt.Close()
+
+		for _, scout := range scouts {
+			if _, err := scoutStmt.ExecContext(ctx, eventKey, realmID, scout.UserID); err != nil {
+				if err, ok := err.(*pq.Error); ok && err.Code == pgFKeyViolation {
+					return ErrFKeyViolation{fmt.Errorf("scout fk violation: %w", err)}
+				}
+				return fmt.Errorf("unable to insert scout: %w", err)
+			}
+
+			for _, shift := range scout.Shifts {
+				if _, err := shiftStmt.ExecContext(ctx, eventKey, realmID, scout.UserID, shift.Start, shift.End); err != nil {
+					return fmt.Errorf("unable to insert scout shift: %w", err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetUnplayedQualificationMatches returns the qualification matches at an event
+// that have not been played yet, in the order they will be played.
+func (s *Service) GetUnplayedQualificationMatches(ctx context.Context, eventKey string) ([]ScheduleMatch, error) {
+	matches := []ScheduleMatch{}
+	err := s.db.SelectContext(ctx, &matches, `
+	SELECT
+		matches.key,
+		COALESCE(matches.predicted_time, matches.scheduled_time) AS match_time,
+		r.team_keys || b.team_keys AS teams
+	FROM
+		matches
+	INNER JOIN
+		alliances r
+		ON
+			matches.key = r.match_key AND r.is_blue = false
+	INNER JOIN
+		alliances b
+		ON
+			matches.key = b.match_key AND b.is_blue = true
+	WHERE
+		matches.event_key = $1 AND
+		matches.key LIKE $1 || '\_qm%' AND
+		matches.actual_time IS NULL AND
+		NOT matches.tba_deleted
+	ORDER BY match_time NULLS LAST, length(matches.key), matches.key
+	`, eventKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select unplayed qualification matches: %w", err)
+	}
+
+	return matches, nil
+}
+
+// ReplaceScoutAssignments replaces all scout assignments for the given matches
+// in a realm with new assignments.
+func (s *Service) ReplaceScoutAssignments(ctx context.Context, eventKey string, realmID int64, matchKeys []string, assignments []ScoutAssignment) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+		DELETE FROM scout_assignments
+		WHERE realm_id = $1 AND match_key = ANY($2)
+		`, realmID, pq.Array(matchKeys))
+		if err != nil {
+			return fmt.Errorf("unable to remove scout assignments: %w", err)
+		}
+
+		stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO scout_assignments (event_key, realm_id, match_key, team_key, user_id)
+		VALUES ($1, $2, $3, $4, $5)
+		`)
+		if err != nil {
+			return fmt.Errorf("unable to prepare scout assignments insert statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, a := range assignments {
+			if _, err := stmt.ExecContext(ctx, eventKey, realmID, a.MatchKey, a.TeamKey, a.UserID); err != nil {
+				return fmt.Errorf("unable to insert scout assignment: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetScoutAssignments returns the scout assignments for an event in a realm in
+// match order. If userID is not nil only assignments for that user are returned,
+// and if upcoming is true only assignments for matches that haven't been played
+// are returned.
+func (s *Service) GetScoutAssignments(ctx context.Context, eventKey string, realmID int64, userID *int64, upcoming bool) ([]ScoutAssignment, error) {
+	query := `
+	SELECT
+		scout_assignments.match_key,
+		scout_assignments.team_key,
+		scout_assignments.user_id,
+		COALESCE(matches.actual_time, matches.predicted_time, matches.scheduled_time) AS match_time
+	FROM
+		scout_assignments
+	INNER JOIN
+		matches
+		ON
+			matches.key = scout_assignments.match_key
+	WHERE
+		scout_assignments.event_key = $1 AND
+		scout_assignments.realm_id = $2 AND
+		($3::INTEGER IS NULL OR scout_assignments.user_id = $3)`
+
+	if upcoming {
+		query += " AND matches.actual_time IS NULL"
+	}
+
+	query += " ORDER BY match_time NULLS LAST, length(matches.key), matches.key, scout_assignments.team_key"
+
+	assignments := []ScoutAssignment{}
+	if err := s.db.SelectContext(ctx, &assignments, query, eventKey, realmID, userID); err != nil {
+		return nil, fmt.Errorf("unable to select scout assignments: %w", err)
+	}
+
+	return assignments, nil
+}
+
+// GetEventSlots returns every team in every match at an event in match order,
+// along with who in the realm was assigned to scout them.
+func (s *Service) GetEventSlots(ctx context.Context, eventKey string, realmID int64) ([]Slot, error) {
+	slots := []Slot{}
+	err := s.db.SelectContext(ctx, &slots, `
+	SELECT
+		matches.key AS match_key,
+		slots.team_key,
+		scout_assignments.user_id AS scout_id,
+		(matches.actual_time IS NOT NULL OR matches.red_score IS NOT NULL) AS played
+	FROM
+		matches
+	INNER JOIN
+		alliances
+		ON
+			alliances.match_key = matches.key
+	CROSS JOIN LATERAL
+		unnest(alliances.team_keys) WITH ORDINALITY AS slots(team_key, position)
+	LEFT JOIN
+		scout_assignments
+		ON
+			scout_assignments.realm_id = $2 AND
+			scout_assignments.match_key = matches.key AND
+			scout_assignments.team_key = slots.team_key
+	WHERE
+		matches.event_key = $1 AND
+		NOT matches.tba_deleted
+	ORDER BY
+		COALESCE(matches.actual_time, matches.predicted_time, matches.scheduled_time) NULLS LAST,
+		length(matches.key),
+		matches.key,
+		alliances.is_blue,
+		slots.position
+	`, eventKey, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select event slots: %w", err)
+	}
+
+	return slots, nil
+}
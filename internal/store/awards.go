@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Award holds a single award given at an event. Awards given to multiple teams
+// (e.g. winners) have one Award per recipient.
+type Award struct {
+	EventKey  string  `json:"-" db:"event_key"`
+	Name      string  `json:"name" db:"name"`
+	AwardType int     `json:"awardType" db:"award_type"`
+	TeamKey   *string `json:"teamKey,omitempty" db:"team_key"`
+	Awardee   *string `json:"awardee,omitempty" db:"awardee"`
+}
+
+// GetEventAwardsForRealm retrieves all awards from an event specified by eventKey with a null
+// or matching realm ID.
+func (s *Service) GetEventAwardsForRealm(ctx context.Context, eventKey string, realmID *int64) ([]Award, error) {
+	awards := []Award{}
+
+	err := s.db.SelectContext(ctx, &awards, `
+	SELECT awards.event_key, awards.name, awards.award_type, awards.team_key, awards.awardee
+	FROM awards
+	INNER JOIN events
+		ON events.key = awards.event_key
+	WHERE
+		awards.event_key = $1 AND
+		(events.realm_id IS NULL OR events.realm_id = $2)
+	ORDER BY awards.award_type, awards.id`, eventKey, realmID)
+	if err != nil {
+		return awards, fmt.Errorf("unable to retrieve awards: %w", err)
+	}
+
+	return awards, nil
+}
+
+// UpdateEventAwards replaces all awards for an event with the given awards.
+func (s *Service) UpdateEventAwards(ctx context.Context, eventKey string, awards []Award) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM awards WHERE event_key = $1", eventKey); err != nil {
+			return fmt.Errorf("unable to remove existing awards: %w", err)
+		}
+
+		stmt, err := tx.PrepareNamedContext(ctx, `
+		INSERT INTO awards (event_key, name, award_type, team_key, awardee)
+		VALUES (:event_key, :name, :award_type, :team_key, :awardee)
+		`)
+		if err != nil {
+			return fmt.Errorf("unable to prepare awards insert statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, award := range awards {
+			award.EventKey = eventKey
+			if _, err := stmt.ExecContext(ctx, award); err != nil {
+				return fmt.Errorf("unable to insert award: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
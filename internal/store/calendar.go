@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SetCalendarToken sets the hash of the calendar token for a user in a realm,
+// replacing any existing token. The token is deleted when the user leaves the
+// realm. If the user isn't an approved member of the realm ErrNoResults is
+// returned.
+func (s *Service) SetCalendarToken(ctx context.Context, userID, realmID int64, tokenHash string) error {
+	res, err := s.db.ExecContext(ctx, `
+	INSERT INTO calendar_tokens (user_id, realm_id, token_hash)
+	SELECT user_id, realm_id, $3
+	FROM realm_memberships
+	WHERE user_id = $1 AND realm_id = $2 AND pending = false
+	ON CONFLICT (user_id)
+	DO
+		UPDATE
+			SET
+				realm_id = $2,
+				token_hash = $3,
+				created_at = now()
+	`, userID, realmID, tokenHash)
+	if err != nil {
+		return fmt.Errorf("unable to set calendar token for user %d: %w", userID, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNoResults{fmt.Errorf("user %d is not a member of realm %d", userID, realmID)}
+	}
+
+	return nil
+}
+
+// DeleteCalendarToken deletes the calendar token for a user.
+func (s *Service) DeleteCalendarToken(ctx context.Context, userID int64) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM calendar_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("unable to delete calendar token for user %d: %w", userID, err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNoResults{errors.New("got 0 affected rows")}
+	}
+
+	return nil
+}
+
+// GetCalendarTokenRealmID retrieves the ID of the realm a calendar token hash
+// was created for. If the token doesn't exist, or its user is no longer an
+// approved member of the realm, ErrNoResults is returned.
+func (s *Service) GetCalendarTokenRealmID(ctx context.Context, tokenHash string) (int64, error) {
+	var realmID int64
+
+	err := s.db.GetContext(ctx, &realmID, `
+	SELECT calendar_tokens.realm_id
+	FROM calendar_tokens
+	INNER JOIN
+		realm_memberships
+	ON
+		realm_memberships.user_id = calendar_tokens.user_id AND
+		realm_memberships.realm_id = calendar_tokens.realm_id
+	WHERE calendar_tokens.token_hash = $1 AND realm_memberships.pending = false
+	`, tokenHash)
+	if err == sql.ErrNoRows {
+		return 0, ErrNoResults{fmt.Errorf("calendar token does not exist: %w", err)}
+	} else if err != nil {
+		return 0, fmt.Errorf("unable to select calendar token realm: %w", err)
+	}
+
+	return realmID, nil
+}
@@ -2,90 +2,287 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Comment defines a comment on a robots performance during a match. It is the
-// qualitative equivalent of a report.
+// qualitative equivalent of a report. Excluded comments have been flagged by an
+// admin, but are still returned for review. Tags are names of tags from the
+// catalog of the comment's realm.
 type Comment struct {
-	ID         int64  `json:"id" db:"id"`
-	EventKey   string `json:"-" db:"event_key"`
-	MatchKey   string `json:"matchKey" db:"match_key"`
-	TeamKey    string `json:"-" db:"team_key"`
-	ReporterID *int64 `json:"reporterId" db:"reporter_id"`
-	RealmID    *int64 `json:"-" db:"realm_id"`
-	Comment    string `json:"comment" db:"comment"`
+	ID         int64          `json:"id" db:"id"`
+	EventKey   string         `json:"-" db:"event_key"`
+	MatchKey   string         `json:"matchKey" db:"match_key"`
+	TeamKey    string         `json:"-" db:"team_key"`
+	ReporterID *int64         `json:"reporterId" db:"reporter_id"`
+	RealmID    *int64         `json:"-" db:"realm_id"`
+	Comment    string         `json:"comment" db:"comment"`
+	Excluded   bool           `json:"excluded" db:"excluded"`
+	Tags       pq.StringArray `json:"tags" db:"tags"`
 }
 
 // UpsertMatchTeamComment will upsert a comment for a team in a match. There can only be one comment
-// per reporter per team per match per event.
+// per reporter per team per match per event per realm. Either way a revision is recorded with the reporter as
+// the editor, and if c.Tags is non-nil the comment's tags are replaced with it. Tags that aren't in
+// the catalog of the comment's realm are ignored.
 func (s *Service) UpsertMatchTeamComment(ctx context.Context, c Comment) (created bool, err error) {
 	var existed bool
 
 	err = s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
-		err = tx.QueryRow(`
-			SELECT EXISTS(
-				SELECT FROM comments
-					WHERE
-						event_key = $1 AND
-						match_key = $2 AND
-						team_key = $3 AND
-						reporter_id = $4
-			)
-			`, c.EventKey, c.MatchKey, c.TeamKey, c.ReporterID).Scan(&existed)
-		if err != nil {
+		var oldComment *string
+		err := tx.QueryRowContext(ctx, `
+			SELECT comment
+			FROM comments
+			WHERE
+				event_key = $1 AND
+				match_key = $2 AND
+				team_key = $3 AND
+				reporter_id = $4 AND
+				realm_id = $5
+			FOR UPDATE
+			`, c.EventKey, c.MatchKey, c.TeamKey, c.ReporterID, c.RealmID).Scan(&oldComment)
+		if err != nil && err != sql.ErrNoRows {
 			return fmt.Errorf("unable to check if comment exists: %w", err)
 		}
+		existed = err == nil
 
-		_, err = tx.NamedExecContext(ctx, `
+		query, args, err := tx.BindNamed(`
 		INSERT INTO
 			comments (event_key, match_key, team_key, reporter_id, realm_id, comment)
 		VALUES (:event_key, :match_key, :team_key, :reporter_id, :realm_id, :comment)
-		ON CONFLICT (event_key, match_key, team_key, reporter_id)
-			DO UPDATE SET comment = :comment, realm_id = :realm_id
+		ON CONFLICT (event_key, match_key, team_key, reporter_id, realm_id)
+			DO UPDATE SET comment = :comment
+		RETURNING id
 		`, c)
 		if err != nil {
+			return fmt.Errorf("unable to bind comment upsert: %w", err)
+		}
+
+		var commentID int64
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&commentID); err != nil {
 			return fmt.Errorf("unable to upsert comment: %w", err)
 		}
 
-		return nil
+		if err := insertCommentRevision(ctx, tx, commentID, c.ReporterID, oldComment, c.Comment); err != nil {
+			return err
+		}
+
+		if c.RealmID == nil || c.Tags == nil {
+			return nil
+		}
+
+		return setCommentTagsTx(ctx, tx, commentID, *c.RealmID, c.Tags)
 	})
 
 	return !existed, err
 }
 
 // GetMatchTeamCommentsForRealm gets all comments for a given team in a match, filtering to only retrieve comments for realms
-// that are sharing reports or have a matching realm ID.
+// that are sharing them with the realm or have a matching realm ID.
 func (s *Service) GetMatchTeamCommentsForRealm(ctx context.Context, matchKey, teamKey string, realmID *int64) (comments []Comment, err error) {
-	const query = `
-	SELECT comments.*
+	query := `
+	SELECT comments.*,` + commentTagsColumn + `
 	FROM comments
 	INNER JOIN realms
 		ON realms.id = comments.realm_id
 	WHERE
 		comments.match_key = $1 AND
 		comments.team_key = $2 AND
-		(realms.share_reports = true OR realms.id = $3)`
+		` + sharedWith("$3", "comments.event_key", sharesComments)
 
 	comments = make([]Comment, 0)
 	return comments, s.db.SelectContext(ctx, &comments, query, matchKey, teamKey, realmID)
 }
 
 // GetEventTeamCommentsForRealm gets all comments for a given team in an event, filtering to only retrieve comments for realms
-// that are sharing reports or have a matching realm ID.
-func (s *Service) GetEventTeamCommentsForRealm(ctx context.Context, eventKey, teamKey string, realmID *int64) (comments []Comment, err error) {
-	const query = `
-	SELECT comments.*
+// that are sharing them with the realm or have a matching realm ID. If any tags are given, only comments with every one of the tags are
+// retrieved.
+func (s *Service) GetEventTeamCommentsForRealm(ctx context.Context, eventKey, teamKey string, realmID *int64, tags []string) (comments []Comment, err error) {
+	query := `
+	SELECT comments.*,` + commentTagsColumn + `
 	FROM comments
 	INNER JOIN realms
 		ON realms.id = comments.realm_id
 	WHERE
 		comments.event_key = $1 AND
 		comments.team_key = $2 AND
-		(realms.share_reports = true OR realms.id = $3)`
+		` + sharedWith("$3", "comments.event_key", sharesComments) + ` AND
+		(COALESCE(cardinality($4::TEXT[]), 0) = 0 OR comments.id IN (
+			SELECT comments_comment_tags.comment_id
+			FROM comments_comment_tags
+			INNER JOIN comment_tags
+				ON comment_tags.id = comments_comment_tags.tag_id
+			WHERE comment_tags.name = ANY($4)
+			GROUP BY comments_comment_tags.comment_id
+			HAVING COUNT(DISTINCT comment_tags.name) = cardinality($4::TEXT[])
+		))`
 
 	comments = []Comment{}
-	return comments, s.db.SelectContext(ctx, &comments, query, eventKey, teamKey, realmID)
+	return comments, s.db.SelectContext(ctx, &comments, query, eventKey, teamKey, realmID, pq.StringArray(tags))
+}
+
+// GetEventCommentKeys returns the keys of all comments made by a realm at an event.
+func (s *Service) GetEventCommentKeys(ctx context.Context, eventKey string, realmID int64) ([]ReportKey, error) {
+	keys := []ReportKey{}
+	err := s.db.SelectContext(ctx, &keys, `
+	SELECT match_key, team_key, reporter_id
+	FROM comments
+	WHERE event_key = $1 AND realm_id = $2
+	`, eventKey, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select event comment keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// GetCommentReporterID returns the ID of the reporter of a comment in a realm,
+// which is nil if the reporter has been deleted. If the comment doesn't exist
+// ErrNoResults is returned.
+func (s *Service) GetCommentReporterID(ctx context.Context, target ReportTarget) (*int64, error) {
+	var reporterID *int64
+	err := s.db.GetContext(ctx, &reporterID, `
+	SELECT reporter_id
+	FROM comments
+	WHERE id = $1 AND match_key = $2 AND team_key = $3 AND realm_id = $4
+	`, target.ID, target.MatchKey, target.TeamKey, target.RealmID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoResults{fmt.Errorf("comment does not exist: %w", err)}
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to select comment reporter: %w", err)
+	}
+
+	return reporterID, nil
+}
+
+// DeleteComment deletes a comment in a realm, along with its revisions. If the
+// comment doesn't exist ErrNoResults is returned.
+func (s *Service) DeleteComment(ctx context.Context, target ReportTarget) error {
+	result, err := s.db.ExecContext(ctx, `
+	DELETE FROM comments
+	WHERE id = $1 AND match_key = $2 AND team_key = $3 AND realm_id = $4
+	`, target.ID, target.MatchKey, target.TeamKey, target.RealmID)
+	if err != nil {
+		return fmt.Errorf("unable to delete comment: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("comment does not exist")}
+	}
+
+	return nil
+}
+
+// SetCommentExcluded sets whether a comment in a realm is excluded. If the
+// comment doesn't exist ErrNoResults is returned.
+func (s *Service) SetCommentExcluded(ctx context.Context, target ReportTarget, excluded bool) error {
+	result, err := s.db.ExecContext(ctx, `
+	UPDATE comments
+	SET excluded = $5
+	WHERE id = $1 AND match_key = $2 AND team_key = $3 AND realm_id = $4
+	`, target.ID, target.MatchKey, target.TeamKey, target.RealmID, excluded)
+	if err != nil {
+		return fmt.Errorf("unable to update comment: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("comment does not exist")}
+	}
+
+	return nil
+}
+
+// CommentSearchFilter describes which comments to search. Nil fields are not
+// filtered on.
+type CommentSearchFilter struct {
+	// Query is a web search style query: words are matched by their stems,
+	// quoted phrases match words in order, "or" matches either side, and a
+	// leading - excludes a word.
+	Query      string
+	EventKey   *string
+	TeamKey    *string
+	ReporterID *int64
+	// Limit is the maximum number of results to return.
+	Limit int
+}
+
+// CommentSearchResult is a comment matching a search, along with an HTML
+// snippet of the comment with matching words wrapped in <mark> tags and how
+// relevant the comment is to the search.
+type CommentSearchResult struct {
+	ID         int64   `json:"id" db:"id"`
+	EventKey   string  `json:"eventKey" db:"event_key"`
+	MatchKey   string  `json:"matchKey" db:"match_key"`
+	TeamKey    string  `json:"teamKey" db:"team_key"`
+	ReporterID *int64  `json:"reporterId" db:"reporter_id"`
+	Comment    string  `json:"comment" db:"comment"`
+	Excluded   bool    `json:"excluded" db:"excluded"`
+	Snippet    string  `json:"snippet" db:"snippet"`
+	Rank       float64 `json:"rank" db:"rank"`
+}
+
+// htmlEscapedComment is the comment HTML-escaped, so snippets of it with <mark>
+// tags can be rendered as HTML.
+const htmlEscapedComment = `replace(replace(replace(replace(comments.comment, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;')`
+
+// SearchCommentsForRealm searches comments with Postgres full-text search,
+// filtering to only retrieve comments for realms that are sharing them with the
+// realm or have a matching realm ID. Results are ordered by relevance, most relevant first.
+func (s *Service) SearchCommentsForRealm(ctx context.Context, realmID *int64, filter CommentSearchFilter) ([]CommentSearchResult, error) {
+	args := []interface{}{realmID, filter.Query}
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	query := `
+	SELECT
+		comments.id,
+		comments.event_key,
+		comments.match_key,
+		comments.team_key,
+		comments.reporter_id,
+		comments.comment,
+		comments.excluded,
+		ts_headline('english', ` + htmlEscapedComment + `, search.query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=3') AS snippet,
+		ts_rank(to_tsvector('english', comments.comment), search.query) AS rank
+	FROM comments
+	INNER JOIN realms
+		ON realms.id = comments.realm_id
+	CROSS JOIN websearch_to_tsquery('english', $2) AS search(query)
+	WHERE
+		to_tsvector('english', comments.comment) @@ search.query AND
+		` + sharedWith("$1", "comments.event_key", sharesComments)
+
+	if filter.EventKey != nil {
+		query += " AND comments.event_key = " + arg(*filter.EventKey)
+	}
+	if filter.TeamKey != nil {
+		query += " AND comments.team_key = " + arg(*filter.TeamKey)
+	}
+	if filter.ReporterID != nil {
+		query += " AND comments.reporter_id = " + arg(*filter.ReporterID)
+	}
+
+	query += "\n\tORDER BY rank DESC, comments.id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT " + arg(filter.Limit)
+	}
+
+	results := []CommentSearchResult{}
+	if err := s.db.SelectContext(ctx, &results, query, args...); err != nil {
+		return nil, fmt.Errorf("unable to search comments: %w", err)
+	}
+
+	return results, nil
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -13,25 +15,46 @@ import (
 // Event holds information about an FRC event such as webcast associated with
 // it, the location, its start date, and more.
 type Event struct {
-	Key          string         `json:"key" db:"key"`
-	RealmID      *int64         `json:"realmId,omitempty" db:"realm_id"`
-	SchemaID     *int64         `json:"schemaId,omitempty" db:"schema_id"`
-	Name         string         `json:"name" db:"name"`
-	District     *string        `json:"district,omitempty" db:"district"`
-	FullDistrict *string        `json:"fullDistrict,omitempty" db:"full_district"`
-	Week         *int           `json:"week,omitempty" db:"week"`
-	StartDate    time.Time      `json:"startDate" db:"start_date"`
-	EndDate      time.Time      `json:"endDate" db:"end_date"`
-	Webcasts     pq.StringArray `json:"webcasts" db:"webcasts"`
-	LocationName string         `json:"locationName" db:"location_name"`
-	GMapsURL     *string        `json:"gmapsUrl" db:"gmaps_url"`
-	Lat          float64        `json:"lat" db:"lat"`
-	Lon          float64        `json:"lon" db:"lon"`
-	TBADeleted   bool           `json:"tbaDeleted" db:"tba_deleted"`
+	Key            string         `json:"key" db:"key"`
+	RealmID        *int64         `json:"realmId,omitempty" db:"realm_id"`
+	SchemaID       *int64         `json:"schemaId,omitempty" db:"schema_id"`
+	PitSchemaID    *int64         `json:"pitSchemaId,omitempty" db:"pit_schema_id"`
+	Name           string         `json:"name" db:"name"`
+	District       *string        `json:"district,omitempty" db:"district"`
+	FullDistrict   *string        `json:"fullDistrict,omitempty" db:"full_district"`
+	Week           *int           `json:"week,omitempty" db:"week"`
+	StartDate      time.Time      `json:"startDate" db:"start_date"`
+	EndDate        time.Time      `json:"endDate" db:"end_date"`
+	Webcasts       pq.StringArray `json:"webcasts" db:"webcasts"`
+	LocationName   string         `json:"locationName" db:"location_name"`
+	GMapsURL       *string        `json:"gmapsUrl" db:"gmaps_url"`
+	Lat            float64        `json:"lat" db:"lat"`
+	Lon            float64        `json:"lon" db:"lon"`
+	TBADeleted     bool           `json:"tbaDeleted" db:"tba_deleted"`
+	Type           *string        `json:"type,omitempty" db:"event_type"`
+	PlayoffType    *string        `json:"playoffType,omitempty" db:"playoff_type"`
+	ParentEventKey *string        `json:"parentEventKey,omitempty" db:"parent_event_key"`
+	DivisionKeys   pq.StringArray `json:"divisionKeys,omitempty" db:"division_keys"`
+	// Distance is the distance to the event in kilometers. It is only set when
+	// searching for events near a location.
+	Distance *float64 `json:"distance,omitempty" db:"distance"`
 }
 
-const eventsQuery = `
-SELECT
+// Event types as stored in the event_type column.
+const (
+	EventTypeRegional                     = "regional"
+	EventTypeDistrict                     = "district"
+	EventTypeDistrictChampionship         = "district_championship"
+	EventTypeDistrictChampionshipDivision = "district_championship_division"
+	EventTypeChampionshipDivision         = "championship_division"
+	EventTypeChampionship                 = "championship"
+	EventTypeFestivalOfChampions          = "festival_of_champions"
+	EventTypeRemote                       = "remote"
+	EventTypeOffseason                    = "offseason"
+	EventTypePreseason                    = "preseason"
+)
+
+const eventsColumns = `
 	key,
 	name,
 	district,
@@ -45,14 +68,28 @@ SELECT
 	lat,
 	lon,
 	tba_deleted,
+	event_type,
+	playoff_type,
+	parent_event_key,
+	division_keys,
 	events.realm_id,
-	COALESCE(schema_id, s.id) AS schema_id
+	COALESCE(schema_id, s.id) AS schema_id,
+	COALESCE(pit_schema_id, ps.id) AS pit_schema_id`
+
+const eventsFrom = `
 FROM
 	events
 LEFT JOIN
 	schemas s
 ON
-	s.year = EXTRACT(YEAR FROM start_date)`
+	s.year = EXTRACT(YEAR FROM start_date) AND s.kind = 'match'
+LEFT JOIN
+	schemas ps
+ON
+	ps.year = EXTRACT(YEAR FROM start_date) AND ps.kind = 'pit'`
+
+const eventsQuery = `
+SELECT` + eventsColumns + eventsFrom
 
 // GetEvents returns all events from the database. event.Webcasts and schemaID will be nil for every event.
 // If tbaDeleted is true, events that have been deleted from TBA will be returned in addition to events that
@@ -68,22 +105,216 @@ func (s *Service) GetEvents(ctx context.Context, tbaDeleted bool) (events []Even
 	return events, s.db.SelectContext(ctx, &events, query)
 }
 
-const eventsRealmQuery = eventsQuery + `WHERE (events.realm_id IS NULL OR events.realm_id = $1)`
+const eventsRealmWhere = `WHERE (events.realm_id IS NULL OR events.realm_id = $1)`
 
-// GetEventsForRealm returns all events from a specific realm. Additionally all
-// TBA events will be retrieved. If no realm is specified (nil) then just the TBA
-// events will be retrieved. event.Webcasts and schemaID will be nil for every event.
-// If tbaDeleted is true, events that have been deleted from TBA will be returned in addition to events that
-// have not been deleted. Otherwise, only events that have not been deleted will be returned.
-func (s *Service) GetEventsForRealm(ctx context.Context, tbaDeleted bool, realmID *int64) (events []Event, err error) {
-	query := eventsRealmQuery
+const eventsRealmQuery = eventsQuery + eventsRealmWhere
 
-	if !tbaDeleted {
+// EventSortField is a column events can be sorted by.
+type EventSortField string
+
+// Fields events can be sorted by.
+const (
+	EventSortStartDate EventSortField = "start_date"
+	EventSortEndDate   EventSortField = "end_date"
+	EventSortName      EventSortField = "name"
+	// EventSortDistance is only valid when filtering by EventFilter.Near.
+	EventSortDistance EventSortField = "distance"
+)
+
+// Event sources for filtering between custom realm events and TBA events.
+const (
+	EventSourceRealm = "realm"
+	EventSourceTBA   = "tba"
+)
+
+// EventCursor identifies the last event of a page of events so that the next
+// page can be retrieved. Value is the sort column value of the last event.
+type EventCursor struct {
+	Value string `json:"v"`
+	Key   string `json:"k"`
+}
+
+// Coordinates is a point on the earth in degrees.
+type Coordinates struct {
+	Lat float64
+	Lon float64
+}
+
+// EventFilter describes which events to retrieve and how to order them. Nil or
+// empty fields are not filtered on.
+type EventFilter struct {
+	TBADeleted bool
+	District   *string
+	Week       *int
+	// From and To select events that overlap with the given date range.
+	From      *time.Time
+	To        *time.Time
+	Types     []string
+	StarredBy *int64
+	// Source is one of EventSourceRealm or EventSourceTBA.
+	Source string
+	// Search is matched case insensitively against event names and locations.
+	Search string
+	// Near computes the distance of each event to the given location. If Radius
+	// (in kilometers) is also set, only events within the radius are returned.
+	Near   *Coordinates
+	Radius *float64
+
+	Sort       EventSortField
+	Descending bool
+	Cursor     *EventCursor
+	// Limit is the maximum number of events to return, or 0 for no limit.
+	Limit int
+}
+
+// NextCursor returns the cursor for the page following events, or nil if
+// events was the last page.
+func (f EventFilter) NextCursor(events []Event) *EventCursor {
+	if f.Limit == 0 || len(events) < f.Limit {
+		return nil
+	}
+
+	last := events[len(events)-1]
+	cursor := &EventCursor{Key: last.Key}
+
+	switch f.sort() {
+	case EventSortName:
+		cursor.Value = last.Name
+	case EventSortEndDate:
+		cursor.Value = last.EndDate.Format(time.RFC3339Nano)
+	case EventSortDistance:
+		if last.Distance != nil {
+			cursor.Value = strconv.FormatFloat(*last.Distance, 'g', -1, 64)
+		}
+	default:
+		cursor.Value = last.StartDate.Format(time.RFC3339Nano)
+	}
+
+	return cursor
+}
+
+func (f EventFilter) sort() EventSortField {
+	switch f.Sort {
+	case EventSortName, EventSortEndDate:
+		return f.Sort
+	case EventSortDistance:
+		if f.Near != nil {
+			return f.Sort
+		}
+	}
+
+	return EventSortStartDate
+}
+
+// escapeLike escapes the LIKE pattern characters in s.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// GetEventsForRealm returns events from a specific realm matching the given filter.
+// Additionally all TBA events will be retrieved. If no realm is specified (nil) then
+// just the TBA events will be retrieved. event.Webcasts and schemaID will be nil for
+// every event. If filter.TBADeleted is true, events that have been deleted from TBA
+// will be returned in addition to events that have not been deleted. Otherwise, only
+// events that have not been deleted will be returned. If filter.Near is set, the
+// distance to each event is computed using the events_location_idx index.
+func (s *Service) GetEventsForRealm(ctx context.Context, realmID *int64, filter EventFilter) (events []Event, err error) {
+	args := []interface{}{realmID}
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	query := "SELECT" + eventsColumns
+
+	var origin, distance string
+	if filter.Near != nil {
+		origin = fmt.Sprintf("ll_to_earth(%s, %s)", arg(filter.Near.Lat), arg(filter.Near.Lon))
+		distance = fmt.Sprintf("(earth_distance(%s, ll_to_earth(events.lat, events.lon)) / 1000)", origin)
+		query += ",\n\t" + distance + " AS distance"
+	}
+
+	query += eventsFrom + " " + eventsRealmWhere
+
+	if filter.Near != nil && filter.Radius != nil {
+		// earth_box uses events_location_idx but is a bounding cube, so the exact
+		// distance has to be checked as well.
+		radius := arg(*filter.Radius * 1000)
+		query += fmt.Sprintf(" AND earth_box(%s, %s) @> ll_to_earth(events.lat, events.lon)", origin, radius)
+		query += fmt.Sprintf(" AND earth_distance(%s, ll_to_earth(events.lat, events.lon)) <= %s", origin, radius)
+	}
+
+	if !filter.TBADeleted {
 		query += " AND NOT tba_deleted"
 	}
 
+	if filter.District != nil {
+		query += " AND events.district = " + arg(*filter.District)
+	}
+
+	if filter.Week != nil {
+		query += " AND events.week = " + arg(*filter.Week)
+	}
+
+	if filter.From != nil {
+		query += " AND events.end_date >= " + arg(*filter.From)
+	}
+
+	if filter.To != nil {
+		query += " AND events.start_date <= " + arg(*filter.To)
+	}
+
+	if len(filter.Types) != 0 {
+		query += " AND events.event_type = ANY(" + arg(pq.StringArray(filter.Types)) + ")"
+	}
+
+	if filter.StarredBy != nil {
+		query += " AND EXISTS(SELECT FROM stars WHERE stars.event_key = events.key AND stars.user_id = " + arg(*filter.StarredBy) + ")"
+	}
+
+	switch filter.Source {
+	case EventSourceRealm:
+		query += " AND events.realm_id IS NOT NULL"
+	case EventSourceTBA:
+		query += " AND events.realm_id IS NULL"
+	}
+
+	if filter.Search != "" {
+		pattern := arg("%" + escapeLike(filter.Search) + "%")
+		query += " AND (events.name ILIKE " + pattern + " OR events.location_name ILIKE " + pattern + ")"
+	}
+
+	column := "events." + string(filter.sort())
+	if filter.sort() == EventSortDistance {
+		column = distance
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if filter.Cursor != nil {
+		value := arg(filter.Cursor.Value)
+		switch filter.sort() {
+		case EventSortDistance:
+			value += "::float8"
+		case EventSortStartDate, EventSortEndDate:
+			value += "::timestamptz"
+		}
+
+		query += fmt.Sprintf(" AND (%s, events.key) %s (%s, %s)", column, comparison, value, arg(filter.Cursor.Key))
+	}
+
+	query += fmt.Sprintf(" ORDER BY %s %s, events.key %s", column, direction, direction)
+
+	if filter.Limit != 0 {
+		query += " LIMIT " + arg(filter.Limit)
+	}
+
 	events = make([]Event, 0)
-	return events, s.db.SelectContext(ctx, &events, query, realmID)
+	return events, s.db.SelectContext(ctx, &events, query, args...)
 }
 
 // GetEventForRealm retrieves a specific event in a specific realm (or no realm for TBA events).
@@ -114,14 +345,23 @@ func (s *Service) GetActiveEvents(ctx context.Context, tbaDeleted bool) ([]Event
 		lat,
 		lon,
 		tba_deleted,
+		event_type,
+		playoff_type,
+		parent_event_key,
+		division_keys,
 		events.realm_id,
-		COALESCE(schema_id, s.id) AS schema_id
+		COALESCE(schema_id, s.id) AS schema_id,
+		COALESCE(pit_schema_id, ps.id) AS pit_schema_id
 	FROM
 		events
 	LEFT JOIN
 		schemas s
 	ON
-		s.year = EXTRACT(YEAR FROM start_date)
+		s.year = EXTRACT(YEAR FROM start_date) AND s.kind = 'match'
+	LEFT JOIN
+		schemas ps
+	ON
+		ps.year = EXTRACT(YEAR FROM start_date) AND ps.kind = 'pit'
 	WHERE
 		start_date <= CURRENT_DATE
 		AND end_date >= CURRENT_DATE`
@@ -135,12 +375,13 @@ func (s *Service) GetActiveEvents(ctx context.Context, tbaDeleted bool) ([]Event
 }
 
 // EventsUpsert upserts multiple events into the database. It will set tba_deleted
-// to false for all updated events. schema_id will only be updated if null.
+// to false for all updated events. schema_id and pit_schema_id will only be updated
+// if null.
 func (s *Service) EventsUpsert(ctx context.Context, events []Event) error {
 	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
 		eventStmt, err := tx.PrepareNamedContext(ctx, `
-		INSERT INTO events (key, name, district, full_district, week, start_date, end_date, webcasts, location_name, gmaps_url, lat, lon, realm_id, schema_id, tba_deleted)
-		VALUES (:key, :name, :district, :full_district, :week, :start_date, :end_date, :webcasts, :location_name, :gmaps_url, :lat, :lon, :realm_id, :schema_id, :tba_deleted)
+		INSERT INTO events (key, name, district, full_district, week, start_date, end_date, webcasts, location_name, gmaps_url, lat, lon, realm_id, schema_id, pit_schema_id, tba_deleted, event_type, playoff_type, parent_event_key, division_keys)
+		VALUES (:key, :name, :district, :full_district, :week, :start_date, :end_date, :webcasts, :location_name, :gmaps_url, :lat, :lon, :realm_id, :schema_id, :pit_schema_id, :tba_deleted, :event_type, :playoff_type, :parent_event_key, :division_keys)
 		ON CONFLICT (key)
 		DO
 			UPDATE
@@ -158,7 +399,12 @@ func (s *Service) EventsUpsert(ctx context.Context, events []Event) error {
 					lon = :lon,
 					realm_id = :realm_id,
 					schema_id = COALESCE(events.schema_id, :schema_id),
-					tba_deleted = false
+					pit_schema_id = COALESCE(events.pit_schema_id, :pit_schema_id),
+					tba_deleted = false,
+					event_type = :event_type,
+					playoff_type = :playoff_type,
+					parent_event_key = :parent_event_key,
+					division_keys = :division_keys
 		`)
 		if err != nil {
 			return fmt.Errorf("unable to prepare events upsert statemant: %w", err)
@@ -232,8 +478,8 @@ func (s *Service) GetEventRealmIDTx(ctx context.Context, tx *sqlx.Tx, eventKey s
 // the event was created or updated.
 func (s *Service) UpsertEventTx(ctx context.Context, tx *sqlx.Tx, event Event) error {
 	_, err := tx.NamedExecContext(ctx, `
-			INSERT INTO events (key, name, district, full_district, week, start_date, end_date, webcasts, location_name, gmaps_url, lat, lon, realm_id, schema_id, tba_deleted)
-				VALUES (:key, :name, :district, :full_district, :week, :start_date, :end_date, :webcasts, :location_name, :gmaps_url, :lat, :lon, :realm_id, :schema_id, :tba_deleted)
+			INSERT INTO events (key, name, district, full_district, week, start_date, end_date, webcasts, location_name, gmaps_url, lat, lon, realm_id, schema_id, pit_schema_id, tba_deleted, event_type, playoff_type, parent_event_key, division_keys)
+				VALUES (:key, :name, :district, :full_district, :week, :start_date, :end_date, :webcasts, :location_name, :gmaps_url, :lat, :lon, :realm_id, :schema_id, :pit_schema_id, :tba_deleted, :event_type, :playoff_type, :parent_event_key, :division_keys)
 			ON CONFLICT (key) DO
 				UPDATE
 					SET
@@ -250,7 +496,12 @@ func (s *Service) UpsertEventTx(ctx context.Context, tx *sqlx.Tx, event Event) e
 						lon = :lon,
 						realm_id = :realm_id,
 						schema_id = :schema_id,
-						tba_deleted = :tba_deleted
+						pit_schema_id = :pit_schema_id,
+						tba_deleted = :tba_deleted,
+						event_type = :event_type,
+						playoff_type = :playoff_type,
+						parent_event_key = :parent_event_key,
+						division_keys = :division_keys
 		`, event)
 	if err != nil {
 		return fmt.Errorf("unable to upsert event: %w", err)
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Identity is an account with an external OpenID Connect provider that a user
+// can log in with. Subject uniquely identifies the account for the issuer.
+type Identity struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"userId" db:"user_id"`
+	Issuer    string    `json:"issuer" db:"issuer"`
+	Subject   string    `json:"subject" db:"subject"`
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// GetUserIdentities returns the identities linked to a user.
+func (s *Service) GetUserIdentities(ctx context.Context, userID int64) ([]Identity, error) {
+	identities := []Identity{}
+	err := s.db.SelectContext(ctx, &identities, `
+	SELECT id, user_id, issuer, subject, email, created_at
+	FROM user_identities
+	WHERE user_id = $1
+	ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+// GetIdentityUserID returns the ID of the user an identity is linked to. If it
+// isn't linked to a user ErrNoResults is returned.
+func (s *Service) GetIdentityUserID(ctx context.Context, issuer, subject string) (int64, error) {
+	var userID int64
+	err := s.db.GetContext(ctx, &userID, `
+	SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2
+	`, issuer, subject)
+	if err == sql.ErrNoRows {
+		return 0, ErrNoResults{fmt.Errorf("identity is not linked to a user: %w", err)}
+	} else if err != nil {
+		return 0, fmt.Errorf("unable to select identity: %w", err)
+	}
+
+	return userID, nil
+}
+
+func createIdentityTx(ctx context.Context, tx *sqlx.Tx, identity Identity) (int64, error) {
+	var id int64
+	err := tx.GetContext(ctx, &id, `
+	INSERT INTO user_identities (user_id, issuer, subject, email)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id
+	`, identity.UserID, identity.Issuer, identity.Subject, identity.Email)
+	if err, ok := err.(*pq.Error); ok {
+		if err.Code == pgExists {
+			return 0, ErrExists{fmt.Errorf("identity is already linked to a user: %w", err)}
+		}
+		if err.Code == pgFKeyViolation {
+			return 0, ErrFKeyViolation{fmt.Errorf("identity fk violation on user ID %d: %w", identity.UserID, err)}
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to insert identity: %w", err)
+	}
+
+	return id, nil
+}
+
+// CreateIdentity links an identity to a user and returns its ID. If the
+// identity is already linked to a user ErrExists is returned.
+func (s *Service) CreateIdentity(ctx context.Context, identity Identity) (id int64, err error) {
+	err = s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		id, err = createIdentityTx(ctx, tx, identity)
+		return err
+	})
+
+	return id, err
+}
+
+// CreateIdentityUser creates a user like CreateUser, linked to an identity, and
+// returns the user's ID. If the identity is already linked to a user or the
+// username exists ErrExists is returned.
+func (s *Service) CreateIdentityUser(ctx context.Context, u User, identity Identity) (userID int64, err error) {
+	err = s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		userID, err = createUserTx(ctx, tx, u)
+		if err != nil {
+			return err
+		}
+
+		identity.UserID = userID
+		_, err = createIdentityTx(ctx, tx, identity)
+		return err
+	})
+
+	return userID, err
+}
+
+// DeleteIdentity unlinks an identity from a user. If the identity doesn't
+// exist ErrNoResults is returned.
+func (s *Service) DeleteIdentity(ctx context.Context, userID, id int64) error {
+	result, err := s.db.ExecContext(ctx, `
+	DELETE FROM user_identities WHERE user_id = $1 AND id = $2
+	`, userID, id)
+	if err != nil {
+		return fmt.Errorf("unable to delete identity: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("identity does not exist")}
+	}
+
+	return nil
+}
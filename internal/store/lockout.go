@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetLoginLockout returns when the lockout of a username or IP address key
+// ends, or the zero time if it was never locked out.
+func (s *Service) GetLoginLockout(ctx context.Context, key string) (time.Time, error) {
+	var lockedUntil *time.Time
+	err := s.db.GetContext(ctx, &lockedUntil, `
+	SELECT locked_until FROM login_failures WHERE key = $1
+	`, key)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("unable to select login lockout: %w", err)
+	}
+
+	if lockedUntil == nil {
+		return time.Time{}, nil
+	}
+
+	return *lockedUntil, nil
+}
+
+// RecordLoginFailure records a failed login for a username or IP address key
+// at now, and returns the number of failures. If the last failure was before
+// since the earlier failures are forgotten.
+func (s *Service) RecordLoginFailure(ctx context.Context, key string, now, since time.Time) (int, error) {
+	var failures int
+	err := s.db.GetContext(ctx, &failures, `
+	INSERT INTO login_failures (key, failures, last_failure_at)
+	VALUES ($1, 1, $2)
+	ON CONFLICT (key) DO UPDATE
+	SET
+		failures = CASE
+			WHEN login_failures.last_failure_at < $3 THEN 1
+			ELSE login_failures.failures + 1
+		END,
+		last_failure_at = $2
+	RETURNING failures
+	`, key, now, since)
+	if err != nil {
+		return 0, fmt.Errorf("unable to record login failure: %w", err)
+	}
+
+	return failures, nil
+}
+
+// LockLogin locks out a username or IP address key until the given time.
+func (s *Service) LockLogin(ctx context.Context, key string, until time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+	UPDATE login_failures SET locked_until = $2 WHERE key = $1
+	`, key, until)
+	if err != nil {
+		return fmt.Errorf("unable to lock login: %w", err)
+	}
+
+	return nil
+}
+
+// ResetLoginFailures forgets the failed logins and lockout of a username or IP
+// address key.
+func (s *Service) ResetLoginFailures(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `
+	DELETE FROM login_failures WHERE key = $1
+	`, key)
+	if err != nil {
+		return fmt.Errorf("unable to reset login failures: %w", err)
+	}
+
+	return nil
+}
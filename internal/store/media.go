@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Media is a photo or video attached to either a pit report or a comment. The
+// blobs for the media and its thumbnail (images only) are stored separately
+// under BlobKey and ThumbnailKey.
+type Media struct {
+	ID           int64     `json:"id" db:"id"`
+	BlobKey      string    `json:"-" db:"blob_key"`
+	ThumbnailKey *string   `json:"-" db:"thumbnail_key"`
+	HasThumbnail bool      `json:"hasThumbnail" db:"has_thumbnail"`
+	ContentType  string    `json:"contentType" db:"content_type"`
+	Size         int64     `json:"size" db:"size"`
+	UploaderID   *int64    `json:"uploaderId" db:"uploader_id"`
+	RealmID      *int64    `json:"-" db:"realm_id"`
+	EventKey     string    `json:"-" db:"event_key"`
+	TeamKey      string    `json:"teamKey" db:"team_key"`
+	MatchKey     *string   `json:"matchKey,omitempty" db:"match_key"`
+	PitReportID  *int64    `json:"pitReportId,omitempty" db:"pit_report_id"`
+	CommentID    *int64    `json:"commentId,omitempty" db:"comment_id"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+}
+
+const mediaQuery = `
+	SELECT
+		media.id,
+		media.blob_key,
+		media.thumbnail_key,
+		media.thumbnail_key IS NOT NULL AS has_thumbnail,
+		media.content_type,
+		media.size,
+		media.uploader_id,
+		media.realm_id,
+		media.event_key,
+		media.team_key,
+		comments.match_key,
+		media.pit_report_id,
+		media.comment_id,
+		media.created_at
+	FROM media
+	INNER JOIN realms
+		ON realms.id = media.realm_id
+	LEFT JOIN comments
+		ON comments.id = media.comment_id`
+
+// mediaShares is the condition on sharing agreements for sharing media, which is
+// shared along with the comment or pit report it's attached to.
+const mediaShares = "CASE WHEN media.comment_id IS NULL THEN " + sharesPitData + " ELSE " + sharesComments + " END"
+
+// GetPitReportID returns the ID of a reporter's pit report for a team at an
+// event. If the pit report doesn't exist ErrNoResults is returned.
+func (s *Service) GetPitReportID(ctx context.Context, eventKey, teamKey string, reporterID int64) (int64, error) {
+	var id int64
+	err := s.db.GetContext(ctx, &id, `
+	SELECT id
+	FROM pit_reports
+	WHERE event_key = $1 AND team_key = $2 AND reporter_id = $3
+	`, eventKey, teamKey, reporterID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNoResults{fmt.Errorf("pit report does not exist: %w", err)}
+	} else if err != nil {
+		return 0, fmt.Errorf("unable to select pit report: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetCommentID returns the ID of a reporter's comment on a team in a match. If
+// the comment doesn't exist ErrNoResults is returned.
+func (s *Service) GetCommentID(ctx context.Context, matchKey, teamKey string, reporterID int64) (int64, error) {
+	var id int64
+	err := s.db.GetContext(ctx, &id, `
+	SELECT id
+	FROM comments
+	WHERE match_key = $1 AND team_key = $2 AND reporter_id = $3
+	`, matchKey, teamKey, reporterID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNoResults{fmt.Errorf("comment does not exist: %w", err)}
+	} else if err != nil {
+		return 0, fmt.Errorf("unable to select comment: %w", err)
+	}
+
+	return id, nil
+}
+
+// CreateMedia records uploaded media and returns its ID.
+func (s *Service) CreateMedia(ctx context.Context, m Media) (int64, error) {
+	var id int64
+
+	query, args, err := s.db.BindNamed(`
+	INSERT INTO media (blob_key, thumbnail_key, content_type, size, uploader_id, realm_id, event_key, team_key, pit_report_id, comment_id)
+	VALUES (:blob_key, :thumbnail_key, :content_type, :size, :uploader_id, :realm_id, :event_key, :team_key, :pit_report_id, :comment_id)
+	RETURNING id
+	`, m)
+	if err != nil {
+		return 0, fmt.Errorf("unable to bind media insert: %w", err)
+	}
+
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&id); err != nil {
+		return 0, fmt.Errorf("unable to insert media: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetMediaForRealm retrieves media by ID, filtering to only retrieve media from
+// realms that are sharing it with the realm or have a matching realm ID. If the media
+// doesn't exist or isn't visible ErrNoResults is returned.
+func (s *Service) GetMediaForRealm(ctx context.Context, id int64, realmID *int64) (Media, error) {
+	var m Media
+	err := s.db.GetContext(ctx, &m, mediaQuery+`
+	WHERE
+		media.id = $1 AND
+		`+sharedWith("$2", "media.event_key", mediaShares)+`
+	`, id, realmID)
+	if err == sql.ErrNoRows {
+		return m, ErrNoResults{fmt.Errorf("media %d does not exist: %w", id, err)}
+	} else if err != nil {
+		return m, fmt.Errorf("unable to select media: %w", err)
+	}
+
+	return m, nil
+}
+
+// GetEventTeamMediaForRealm retrieves all media for a team at an event, oldest
+// first, filtering to only retrieve media from realms that are sharing it with the
+// realm or have a matching realm ID.
+func (s *Service) GetEventTeamMediaForRealm(ctx context.Context, eventKey, teamKey string, realmID *int64) ([]Media, error) {
+	media := []Media{}
+	err := s.db.SelectContext(ctx, &media, mediaQuery+`
+	WHERE
+		media.event_key = $1 AND
+		media.team_key = $2 AND
+		`+sharedWith("$3", "media.event_key", mediaShares)+`
+	ORDER BY media.id
+	`, eventKey, teamKey, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select media: %w", err)
+	}
+
+	return media, nil
+}
+
+// DeleteMedia deletes the record of media by ID. The caller is responsible for
+// deleting its blobs. If the media doesn't exist ErrNoResults is returned.
+func (s *Service) DeleteMedia(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM media WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("unable to delete media: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("media does not exist")}
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// RealmInvite is a code that lets people join a realm without waiting for
+// approval. Users who join with the code are given the roles named by Roles. If
+// ExpiresAt is not nil the code can't be used after then.
+type RealmInvite struct {
+	ID        int64          `json:"id" db:"id"`
+	RealmID   int64          `json:"realmId" db:"realm_id"`
+	Code      string         `json:"code" db:"code"`
+	Roles     pq.StringArray `json:"roles" db:"roles"`
+	ExpiresAt *time.Time     `json:"expiresAt" db:"expires_at"`
+	CreatorID *int64         `json:"creatorId" db:"creator_id"`
+	CreatedAt time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// GetRealmInvites returns the invites to a realm, including expired ones, newest
+// first.
+func (s *Service) GetRealmInvites(ctx context.Context, realmID int64) ([]RealmInvite, error) {
+	invites := []RealmInvite{}
+	err := s.db.SelectContext(ctx, &invites, `
+	SELECT *
+	FROM realm_invites
+	WHERE realm_id = $1
+	ORDER BY created_at DESC, id DESC
+	`, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select realm invites: %w", err)
+	}
+
+	return invites, nil
+}
+
+// GetRealmInviteByCode returns the invite with the given code. If the invite
+// doesn't exist or has expired ErrNoResults is returned.
+func (s *Service) GetRealmInviteByCode(ctx context.Context, code string) (RealmInvite, error) {
+	var invite RealmInvite
+	err := s.db.GetContext(ctx, &invite, `
+	SELECT *
+	FROM realm_invites
+	WHERE code = $1 AND (expires_at IS NULL OR expires_at > now())
+	`, code)
+	if err == sql.ErrNoRows {
+		return invite, ErrNoResults{fmt.Errorf("realm invite does not exist: %w", err)}
+	} else if err != nil {
+		return invite, fmt.Errorf("unable to select realm invite: %w", err)
+	}
+
+	return invite, nil
+}
+
+// CreateRealmInvite creates an invite to a realm and returns its ID and when it
+// was created. If an invite with the same code already exists ErrExists is
+// returned.
+func (s *Service) CreateRealmInvite(ctx context.Context, invite RealmInvite) (int64, time.Time, error) {
+	var created struct {
+		ID        int64     `db:"id"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+
+	err := s.db.GetContext(ctx, &created, `
+	INSERT INTO realm_invites (realm_id, code, roles, expires_at, creator_id)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at
+	`, invite.RealmID, invite.Code, invite.Roles, invite.ExpiresAt, invite.CreatorID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgExists {
+		return 0, created.CreatedAt, ErrExists{fmt.Errorf("realm invite code already exists: %w", err)}
+	} else if err != nil {
+		return 0, created.CreatedAt, fmt.Errorf("unable to insert realm invite: %w", err)
+	}
+
+	return created.ID, created.CreatedAt, nil
+}
+
+// DeleteRealmInvite deletes an invite to a realm so its code can no longer be
+// used. If the invite doesn't exist in the realm ErrNoResults is returned.
+func (s *Service) DeleteRealmInvite(ctx context.Context, realmID, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM realm_invites WHERE realm_id = $1 AND id = $2", realmID, id)
+	if err != nil {
+		return fmt.Errorf("unable to delete realm invite: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("realm invite does not exist")}
+	}
+
+	return nil
+}
+
+// RealmMembership is a realm a user is a member of, along with the user's roles
+// and permissions in it. Pending memberships are waiting for approval.
+type RealmMembership struct {
+	RealmID     int64          `json:"realmId" db:"realm_id"`
+	RealmName   string         `json:"realmName" db:"realm_name"`
+	Pending     bool           `json:"pending" db:"pending"`
+	Roles       pq.StringArray `json:"roles" db:"roles"`
+	Permissions Permissions    `json:"permissions" db:"permissions"`
+	CreatedAt   time.Time      `json:"createdAt" db:"created_at"`
+}
+
+// GetUserRealms returns the realms a user is a member of, including pending
+// memberships, oldest first.
+func (s *Service) GetUserRealms(ctx context.Context, userID int64) ([]RealmMembership, error) {
+	memberships := []RealmMembership{}
+	err := s.db.SelectContext(ctx, &memberships, `
+	SELECT
+		realm_memberships.realm_id,
+		realms.name AS realm_name,
+		realm_memberships.pending,
+		ARRAY(
+			SELECT roles.name
+			FROM user_roles
+			INNER JOIN roles
+				ON roles.id = user_roles.role_id
+			WHERE user_roles.user_id = realm_memberships.user_id AND user_roles.realm_id = realm_memberships.realm_id
+			ORDER BY roles.name
+		) AS roles,
+		ARRAY(
+			SELECT DISTINCT unnest(roles.permissions)
+			FROM user_roles
+			INNER JOIN roles
+				ON roles.id = user_roles.role_id
+			WHERE user_roles.user_id = realm_memberships.user_id AND user_roles.realm_id = realm_memberships.realm_id
+		) AS permissions,
+		realm_memberships.created_at
+	FROM realm_memberships
+	INNER JOIN realms
+		ON realms.id = realm_memberships.realm_id
+	WHERE realm_memberships.user_id = $1
+	ORDER BY realm_memberships.created_at, realm_memberships.realm_id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select realm memberships: %w", err)
+	}
+
+	return memberships, nil
+}
+
+// JoinRealm makes an existing user a member of another realm with the roles
+// available in the realm with the given names. If pending is true the membership
+// waits for approval. If the user is already a member of the realm ErrExists is
+// returned, if the realm doesn't exist ErrFKeyViolation is returned, and if any
+// of the roles don't exist ErrNoResults is returned.
+func (s *Service) JoinRealm(ctx context.Context, userID, realmID int64, roles []string, pending bool) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+		INSERT INTO realm_memberships (user_id, realm_id, pending)
+		VALUES ($1, $2, $3)
+		`, userID, realmID, pending)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgExists {
+			return ErrExists{fmt.Errorf("user %d is already a member of realm %d: %w", userID, realmID, err)}
+		} else if ok && pqErr.Code == pgFKeyViolation {
+			return ErrFKeyViolation{fmt.Errorf("realm membership fk violation: %w", err)}
+		} else if err != nil {
+			return fmt.Errorf("unable to insert realm membership: %w", err)
+		}
+
+		return setUserRolesTx(ctx, tx, userID, realmID, roles)
+	})
+}
+
+// removeRealmMemberTx removes a user from a realm using the given transaction. If
+// it was the only realm they were a member of the user is deleted, and if it was
+// the realm they log in to by default, their oldest other membership becomes
+// their default. If pendingOnly is true, only pending memberships are removed. If
+// the user isn't a member of the realm ErrNoResults is returned.
+func removeRealmMemberTx(ctx context.Context, tx *sqlx.Tx, userID, realmID int64, pendingOnly bool) error {
+	result, err := tx.ExecContext(ctx, `
+	DELETE FROM realm_memberships
+	WHERE user_id = $1 AND realm_id = $2 AND (pending = true OR NOT $3)
+	`, userID, realmID, pendingOnly)
+	if err != nil {
+		return fmt.Errorf("unable to delete realm membership: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{fmt.Errorf("user %d is not a member of realm %d", userID, realmID)}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	DELETE FROM users
+	WHERE id = $1 AND NOT EXISTS(SELECT true FROM realm_memberships WHERE user_id = $1)
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("unable to delete user without realms: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE users
+	SET realm_id = (
+		SELECT realm_id
+		FROM realm_memberships
+		WHERE user_id = $1
+		ORDER BY pending, created_at
+		LIMIT 1
+	)
+	WHERE id = $1 AND realm_id = $2
+	`, userID, realmID)
+	if err != nil {
+		return fmt.Errorf("unable to update default realm: %w", err)
+	}
+
+	return nil
+}
+
+// GetPendingUsers returns the users who have asked to join a realm and are
+// waiting for approval, oldest request first.
+func (s *Service) GetPendingUsers(ctx context.Context, realmID int64) ([]User, error) {
+	users := []User{}
+	err := s.db.SelectContext(ctx, &users, usersQueryInRealm("$1::INTEGER")+`
+	INNER JOIN realm_memberships
+		ON realm_memberships.user_id = users.id AND realm_memberships.realm_id = $1
+	WHERE realm_memberships.pending = true
+	GROUP BY users.id, realm_memberships.created_at
+	ORDER BY realm_memberships.created_at
+	`, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select pending users: %w", err)
+	}
+
+	return users, nil
+}
+
+// ApproveMembershipRequest approves the request of a pending user to join a realm
+// and gives them the roles available in the realm with the given names. If the
+// user isn't waiting to join the realm or any of the roles don't exist
+// ErrNoResults is returned.
+func (s *Service) ApproveMembershipRequest(ctx context.Context, realmID, userID int64, roles []string) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+		UPDATE realm_memberships
+		SET pending = false
+		WHERE user_id = $1 AND realm_id = $2 AND pending = true
+		`, userID, realmID)
+		if err != nil {
+			return fmt.Errorf("unable to approve membership request: %w", err)
+		}
+
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("unable to determine rows affected: %w", err)
+		} else if n == 0 {
+			return ErrNoResults{errors.New("membership request does not exist")}
+		}
+
+		return setUserRolesTx(ctx, tx, userID, realmID, roles)
+	})
+}
+
+// RejectMembershipRequest rejects the request of a pending user to join a realm.
+// If the user isn't a member of any other realm they are deleted. If the user
+// isn't waiting to join the realm ErrNoResults is returned.
+func (s *Service) RejectMembershipRequest(ctx context.Context, realmID, userID int64) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		return removeRealmMemberTx(ctx, tx, userID, realmID, true)
+	})
+}
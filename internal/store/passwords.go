@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// PasswordPolicy is the rules for the passwords of a realm's members.
+type PasswordPolicy struct {
+	RealmID          int64 `json:"realmId" db:"realm_id"`
+	MinLength        int   `json:"minLength" db:"min_length" validate:"gte=8,lte=128"`
+	RequireMixedCase bool  `json:"requireMixedCase" db:"require_mixed_case"`
+	RequireDigit     bool  `json:"requireDigit" db:"require_digit"`
+	RequireSymbol    bool  `json:"requireSymbol" db:"require_symbol"`
+}
+
+// DefaultPasswordPolicy is the policy of realms that haven't set one.
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8}
+
+// GetPasswordPolicy returns the password policy of a realm, or the default
+// policy if it hasn't set one.
+func (s *Service) GetPasswordPolicy(ctx context.Context, realmID int64) (PasswordPolicy, error) {
+	policy := DefaultPasswordPolicy
+	err := s.db.GetContext(ctx, &policy, `
+	SELECT realm_id, min_length, require_mixed_case, require_digit, require_symbol
+	FROM password_policies
+	WHERE realm_id = $1
+	`, realmID)
+	if err == sql.ErrNoRows {
+		policy.RealmID = realmID
+		return policy, nil
+	} else if err != nil {
+		return policy, fmt.Errorf("unable to select password policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+// GetUserPasswordPolicy returns the strictest combination of the password
+// policies of every realm a user is a member of.
+func (s *Service) GetUserPasswordPolicy(ctx context.Context, userID int64) (PasswordPolicy, error) {
+	policy := DefaultPasswordPolicy
+	err := s.db.GetContext(ctx, &policy, `
+	SELECT
+		COALESCE(MAX(COALESCE(password_policies.min_length, $2)), $2) AS min_length,
+		COALESCE(bool_or(password_policies.require_mixed_case), false) AS require_mixed_case,
+		COALESCE(bool_or(password_policies.require_digit), false) AS require_digit,
+		COALESCE(bool_or(password_policies.require_symbol), false) AS require_symbol
+	FROM realm_memberships
+	LEFT JOIN password_policies ON password_policies.realm_id = realm_memberships.realm_id
+	WHERE realm_memberships.user_id = $1
+	`, userID, DefaultPasswordPolicy.MinLength)
+	if err != nil {
+		return policy, fmt.Errorf("unable to select user password policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+// SetPasswordPolicy sets the password policy of a realm. If the realm doesn't
+// exist ErrFKeyViolation is returned.
+func (s *Service) SetPasswordPolicy(ctx context.Context, policy PasswordPolicy) error {
+	_, err := s.db.NamedExecContext(ctx, `
+	INSERT INTO password_policies (realm_id, min_length, require_mixed_case, require_digit, require_symbol)
+	VALUES (:realm_id, :min_length, :require_mixed_case, :require_digit, :require_symbol)
+	ON CONFLICT (realm_id) DO UPDATE
+	SET
+		min_length = :min_length,
+		require_mixed_case = :require_mixed_case,
+		require_digit = :require_digit,
+		require_symbol = :require_symbol
+	`, policy)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgFKeyViolation {
+		return ErrFKeyViolation{fmt.Errorf("password policy fk violation on realm ID %d: %w", policy.RealmID, err)}
+	} else if err != nil {
+		return fmt.Errorf("unable to upsert password policy: %w", err)
+	}
+
+	return nil
+}
+
+// PasswordReset is a one-time token that lets a user set a new password without
+// their old one. Only the hash of the token is stored.
+type PasswordReset struct {
+	ID        int64      `json:"id" db:"id"`
+	UserID    int64      `json:"userId" db:"user_id"`
+	CreatorID *int64     `json:"creatorId" db:"creator_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expiresAt" db:"expires_at"`
+	UsedAt    *time.Time `json:"usedAt" db:"used_at"`
+	CreatedAt time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// CreatePasswordReset creates a password reset for a user, replacing any of
+// their unused resets, and returns its ID and creation time.
+func (s *Service) CreatePasswordReset(ctx context.Context, reset PasswordReset) (id int64, createdAt time.Time, err error) {
+	err = s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+		DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL
+		`, reset.UserID)
+		if err != nil {
+			return fmt.Errorf("unable to delete unused password resets: %w", err)
+		}
+
+		row := tx.QueryRowxContext(ctx, `
+		INSERT INTO password_resets (user_id, creator_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+		`, reset.UserID, reset.CreatorID, reset.TokenHash, reset.ExpiresAt)
+		if err := row.Scan(&id, &createdAt); err != nil {
+			if err, ok := err.(*pq.Error); ok && err.Code == pgFKeyViolation {
+				return ErrFKeyViolation{fmt.Errorf("password reset fk violation on user ID %d: %w", reset.UserID, err)}
+			}
+			return fmt.Errorf("unable to insert password reset: %w", err)
+		}
+
+		return nil
+	})
+
+	return id, createdAt, err
+}
+
+// GetPasswordReset returns the unused, unexpired password reset with the given
+// token hash. If there isn't one ErrNoResults is returned.
+func (s *Service) GetPasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	var reset PasswordReset
+	err := s.db.GetContext(ctx, &reset, `
+	SELECT id, user_id, creator_id, token_hash, expires_at, used_at, created_at
+	FROM password_resets
+	WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+	`, tokenHash)
+	if err == sql.ErrNoRows {
+		return reset, ErrNoResults{fmt.Errorf("password reset does not exist: %w", err)}
+	} else if err != nil {
+		return reset, fmt.Errorf("unable to select password reset: %w", err)
+	}
+
+	return reset, nil
+}
+
+// UsePasswordReset uses a password reset to set its user's password, and
+// revokes all of the user's sessions. Changing the password also invalidates
+// refresh tokens issued before it. If the reset was already used or has
+// expired ErrNoResults is returned.
+func (s *Service) UsePasswordReset(ctx context.Context, id int64, hashedPassword string) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		var userID int64
+		err := tx.GetContext(ctx, &userID, `
+		UPDATE password_resets
+		SET used_at = now()
+		WHERE id = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id
+		`, id)
+		if err == sql.ErrNoRows {
+			return ErrNoResults{errors.New("password reset does not exist")}
+		} else if err != nil {
+			return fmt.Errorf("unable to use password reset: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		UPDATE users SET hashed_password = $2, password_changed = $3 WHERE id = $1
+		`, userID, hashedPassword, time.Now())
+		if err != nil {
+			return fmt.Errorf("unable to update password: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL
+		`, userID)
+		if err != nil {
+			return fmt.Errorf("unable to revoke sessions: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// A PitStat holds a single piece of information about a robot from pit
+// scouting. The value is a number, boolean, or string depending on the type of
+// the pit schema field.
+type PitStat struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// PitReportData holds all the data in a pit report
+type PitReportData []PitStat
+
+// Value implements driver.Valuer to return JSON for the DB from PitReportData.
+func (pd PitReportData) Value() (driver.Value, error) { return json.Marshal(pd) }
+
+// Scan implements sql.Scanner to scan JSON from the DB into PitReportData.
+func (pd *PitReportData) Scan(src interface{}) error {
+	j, ok := src.([]byte)
+	if !ok {
+		return errors.New("got invalid type for PitReportData")
+	}
+
+	return json.Unmarshal(j, pd)
+}
+
+// PitReport is data about an FRC team's robot at an event, gathered by visiting
+// their pit.
+type PitReport struct {
+	ID         int64         `json:"-" db:"id"`
+	EventKey   string        `json:"-" db:"event_key"`
+	TeamKey    string        `json:"-" db:"team_key"`
+	ReporterID *int64        `json:"reporterId" db:"reporter_id"`
+	RealmID    *int64        `json:"-" db:"realm_id"`
+	Data       PitReportData `json:"data" db:"data"`
+}
+
+// UpsertPitReport creates a new pit report in the db, or replaces the existing
+// one if the same reporter already has a pit report for that team at the event
+// in the same realm. It returns a boolean that is true when the report was
+// created, and false when it was updated.
+func (s *Service) UpsertPitReport(ctx context.Context, r PitReport) (created bool, err error) {
+	var existed bool
+
+	err = s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS(
+				SELECT FROM pit_reports
+				WHERE
+					event_key = $1 AND
+					team_key = $2 AND
+					reporter_id = $3 AND
+					realm_id = $4
+			)
+			`, r.EventKey, r.TeamKey, r.ReporterID, r.RealmID).Scan(&existed)
+		if err != nil {
+			return fmt.Errorf("unable to determine if pit report exists: %w", err)
+		}
+
+		_, err = tx.NamedExecContext(ctx, `
+			INSERT INTO
+				pit_reports (event_key, team_key, reporter_id, realm_id, data)
+			VALUES (:event_key, :team_key, :reporter_id, :realm_id, :data)
+			ON CONFLICT (event_key, team_key, reporter_id, realm_id)
+				DO UPDATE SET data = :data
+		`, r)
+		if err != nil {
+			return fmt.Errorf("unable to upsert pit report: %w", err)
+		}
+
+		return nil
+	})
+
+	return !existed, err
+}
+
+// GetEventTeamPitReportsForRealm retrieves all pit reports for a team at an event,
+// filtering to only retrieve pit reports for realms that are sharing them with the
+// realm or have a matching realm ID.
+func (s *Service) GetEventTeamPitReportsForRealm(ctx context.Context, eventKey, teamKey string, realmID *int64) ([]PitReport, error) {
+	reports := []PitReport{}
+	err := s.db.SelectContext(ctx, &reports, `
+	SELECT pit_reports.*
+	FROM pit_reports
+	INNER JOIN realms
+		ON realms.id = pit_reports.realm_id
+	WHERE
+		pit_reports.event_key = $1 AND
+		pit_reports.team_key = $2 AND
+		`+sharedWith("$3", "pit_reports.event_key", sharesPitData)+`
+	ORDER BY pit_reports.id
+	`, eventKey, teamKey, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select pit reports: %w", err)
+	}
+
+	return reports, nil
+}
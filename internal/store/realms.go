@@ -73,8 +73,28 @@ func (s *Service) InsertRealm(ctx context.Context, realm Realm) (int64, error) {
 }
 
 // DeleteRealmTx deletes a realm from the database using the given transaction.
+// Users who log in to the realm by default are deleted too, unless they are
+// members of other realms, in which case their oldest other membership becomes
+// their default.
 func (s *Service) DeleteRealmTx(ctx context.Context, tx *sqlx.Tx, id int64) error {
-	_, err := tx.ExecContext(ctx, "DELETE FROM realms WHERE id = $1", id)
+	_, err := tx.ExecContext(ctx, `
+	UPDATE users
+	SET realm_id = (
+		SELECT realm_id
+		FROM realm_memberships
+		WHERE user_id = users.id AND realm_id != $1
+		ORDER BY pending, created_at
+		LIMIT 1
+	)
+	WHERE
+		realm_id = $1 AND
+		EXISTS(SELECT true FROM realm_memberships WHERE user_id = users.id AND realm_id != $1)
+	`, id)
+	if err != nil {
+		return fmt.Errorf("unable to update default realm of realm members: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM realms WHERE id = $1", id)
 	if err != nil {
 		return fmt.Errorf("unable to delete realm: %w", err)
 	}
@@ -2,10 +2,12 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -33,58 +35,138 @@ func (rd *ReportData) Scan(src interface{}) error {
 	return json.Unmarshal(j, rd)
 }
 
-// Report is data about how an FRC team performed in a specific match.
+// Report is data about how an FRC team performed in a specific match. Excluded
+// reports have been flagged by an admin and are left out of stats, but are still
+// returned for review.
 type Report struct {
-	ID         int64      `json:"-" db:"id"`
+	ID         int64      `json:"id" db:"id"`
 	EventKey   string     `json:"-" db:"event_key"`
 	MatchKey   string     `json:"-" db:"match_key"`
 	TeamKey    string     `json:"-" db:"team_key"`
 	ReporterID *int64     `json:"reporterId" db:"reporter_id"`
 	RealmID    *int64     `json:"-" db:"realm_id"`
 	Data       ReportData `json:"data" db:"data"`
+	Excluded   bool       `json:"excluded" db:"excluded"`
 }
 
-// Leaderboard holds information about how many reports each reporter submitted.
-type Leaderboard []struct {
-	ReporterID int64 `json:"reporterId" db:"reporter_id"`
-	Reports    int64 `json:"reports" db:"num_reports"`
+// ReportKey identifies a report or comment by a reporter on a team in a match.
+type ReportKey struct {
+	MatchKey   string `db:"match_key"`
+	TeamKey    string `db:"team_key"`
+	ReporterID *int64 `db:"reporter_id"`
+}
+
+// LeaderboardEntry holds information about how much scouting a reporter did.
+type LeaderboardEntry struct {
+	ReporterID int64  `json:"reporterId" db:"reporter_id"`
+	FirstName  string `json:"firstName" db:"first_name"`
+	LastName   string `json:"lastName" db:"last_name"`
+	Reports    int64  `json:"reports" db:"num_reports"`
+	Comments   int64  `json:"comments" db:"num_comments"`
+}
+
+// Leaderboard holds information about how much scouting each reporter did.
+type Leaderboard []LeaderboardEntry
+
+// LeaderboardFilter limits the reports and comments counted on a leaderboard.
+// From and To select matches played (or scheduled to be played) from the start
+// of From to the end of To.
+type LeaderboardFilter struct {
+	EventKey *string
+	From     *time.Time
+	To       *time.Time
+}
+
+// ReporterMatch is a match at an event that a reporter submitted a report for.
+// MatchNumber is the position of the match in the event's schedule, starting
+// at 1.
+type ReporterMatch struct {
+	ReporterID  int64  `db:"reporter_id"`
+	EventKey    string `db:"event_key"`
+	MatchNumber int    `db:"match_number"`
+}
+
+// leaderboardMatches selects the keys, events, schedule positions and times of
+// all matches matching a leaderboard filter. Its arguments are the event key,
+// from and to of the filter.
+const leaderboardMatches = `
+	SELECT *
+	FROM (
+		SELECT
+			matches.key,
+			matches.event_key,
+			ROW_NUMBER() OVER (
+				PARTITION BY matches.event_key
+				ORDER BY
+					COALESCE(matches.actual_time, matches.predicted_time, matches.scheduled_time) NULLS LAST,
+					length(matches.key),
+					matches.key
+			) AS match_number,
+			COALESCE(matches.actual_time, matches.predicted_time, matches.scheduled_time) AS match_time
+		FROM matches
+		WHERE
+			NOT matches.tba_deleted AND
+			($1::TEXT IS NULL OR matches.event_key = $1)
+	) AS ranked
+	WHERE
+		($2::TIMESTAMPTZ IS NULL OR ranked.match_time >= $2) AND
+		($3::TIMESTAMPTZ IS NULL OR ranked.match_time < $3)`
+
+// args returns the arguments for leaderboardMatches.
+func (f LeaderboardFilter) args() []interface{} {
+	var to *time.Time
+	if f.To != nil {
+		end := f.To.AddDate(0, 0, 1)
+		to = &end
+	}
+
+	return []interface{}{f.EventKey, f.From, to}
 }
 
 // UpsertReport creates a new report in the db, or replaces the existing one if
-// the same reporter already has a report in the db for that team and match. It
-// returns a boolean that is true when the report was created, and false when it
-// was updated.
+// the same reporter already has a report in the db for that team and match in
+// the same realm. It returns a boolean that is true when the report was created,
+// and false when it was updated. Either way a revision is recorded with the
+// reporter as the editor.
 func (s *Service) UpsertReport(ctx context.Context, r Report) (created bool, err error) {
 	var existed bool
 
 	err = s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
-		var existed bool
-		err = tx.QueryRowContext(ctx, `
-			SELECT EXISTS(
-				SELECT FROM reports
-				WHERE
-					event_key = $1 AND
-					match_key = $2 AND
-					team_key = $3 AND
-					reporter_id = $4
-			)
-			`, r.EventKey, r.MatchKey, r.TeamKey, r.ReporterID).Scan(&existed)
-		if err != nil {
+		var oldData *ReportData
+		err := tx.QueryRowContext(ctx, `
+			SELECT data
+			FROM reports
+			WHERE
+				event_key = $1 AND
+				match_key = $2 AND
+				team_key = $3 AND
+				reporter_id = $4 AND
+				realm_id = $5
+			FOR UPDATE
+			`, r.EventKey, r.MatchKey, r.TeamKey, r.ReporterID, r.RealmID).Scan(&oldData)
+		if err != nil && err != sql.ErrNoRows {
 			return fmt.Errorf("unable to determine if report exists: %w", err)
 		}
+		existed = err == nil
 
-		_, err = tx.NamedExecContext(ctx, `
+		query, args, err := tx.BindNamed(`
 			INSERT INTO
 				reports (event_key, match_key, team_key, reporter_id, realm_id, data)
 			VALUES (:event_key, :match_key, :team_key, :reporter_id, :realm_id, :data)
-			ON CONFLICT (event_key, match_key, team_key, reporter_id)
-				DO UPDATE SET data = :data, realm_id = :realm_id
+			ON CONFLICT (event_key, match_key, team_key, reporter_id, realm_id)
+				DO UPDATE SET data = :data
+			RETURNING id
 		`, r)
 		if err != nil {
+			return fmt.Errorf("unable to bind report upsert: %w", err)
+		}
+
+		var reportID int64
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&reportID); err != nil {
 			return fmt.Errorf("unable to upsert report: %w", err)
 		}
 
-		return nil
+		return insertReportRevision(ctx, tx, reportID, r.ReporterID, oldData, r.Data)
 	})
 
 	return !existed, err
@@ -92,23 +174,23 @@ func (s *Service) UpsertReport(ctx context.Context, r Report) (created bool, err
 
 // GetEventReportsForRealm returns all event reports for a specific event and realm.
 func (s *Service) GetEventReportsForRealm(ctx context.Context, eventKey string, realmID *int64) ([]Report, error) {
-	const query = `
+	query := `
 	SELECT reports.*
 	FROM reports
 	INNER JOIN realms
 		ON realms.id = reports.realm_id
 	WHERE
 		reports.event_key = $1 AND
-		(realms.share_reports = true OR realms.id = $2)`
+		` + sharedWith("$2", "reports.event_key", sharesReports)
 
 	reports := []Report{}
 	return reports, s.db.SelectContext(ctx, &reports, query, eventKey, realmID)
 }
 
 // GetEventTeamReportsForRealm retrieves all reports for a specific team and event, filtering to only retrieve reports for realms
-// that are sharing reports or have a matching realm ID.
+// that are sharing them with the realm or have a matching realm ID.
 func (s *Service) GetEventTeamReportsForRealm(ctx context.Context, eventKey string, teamKey string, realmID *int64) (reports []Report, err error) {
-	const query = `
+	query := `
 	SELECT reports.*
 	FROM reports
 	INNER JOIN realms
@@ -116,16 +198,16 @@ func (s *Service) GetEventTeamReportsForRealm(ctx context.Context, eventKey stri
 	WHERE
 		reports.event_key = $1 AND
 		reports.team_key = $2 AND
-		(realms.share_reports = true OR realms.id = $3)`
+		` + sharedWith("$3", "reports.event_key", sharesReports)
 
 	reports = make([]Report, 0)
 	return reports, s.db.SelectContext(ctx, &reports, query, eventKey, teamKey, realmID)
 }
 
 // GetMatchTeamReportsForRealm retrieves all reports for a specific team and event, filtering to only retrieve reports for realms
-// that are sharing reports or have a matching realm ID.
+// that are sharing them with the realm or have a matching realm ID.
 func (s *Service) GetMatchTeamReportsForRealm(ctx context.Context, eventKey, matchKey string, teamKey string, realmID *int64) (reports []Report, err error) {
-	const query = `
+	query := `
 	SELECT reports.*
 	FROM reports
 	INNER JOIN realms
@@ -134,26 +216,152 @@ func (s *Service) GetMatchTeamReportsForRealm(ctx context.Context, eventKey, mat
 		reports.event_key = $1 AND
 		reports.match_key = $2 AND
 		reports.team_key = $3 AND
-		(realms.share_reports = true OR realms.id = $4)`
+		` + sharedWith("$4", "reports.event_key", sharesReports)
 
 	reports = make([]Report, 0)
 	return reports, s.db.SelectContext(ctx, &reports, query, eventKey, matchKey, teamKey, realmID)
 }
 
-// GetLeaderboardForRealm retrieves leaderboard information from the reports and users table for users
-// in the given realm.
-func (s *Service) GetLeaderboardForRealm(ctx context.Context, realmID int64) (Leaderboard, error) {
+// GetLeaderboardForRealm retrieves how many reports and comments each user in
+// the given realm submitted for matches matching the filter, ordered by the
+// number of reports.
+func (s *Service) GetLeaderboardForRealm(ctx context.Context, realmID int64, filter LeaderboardFilter) (Leaderboard, error) {
 	leaderboard := make(Leaderboard, 0)
 
-	return leaderboard, s.db.SelectContext(ctx, &leaderboard, `
+	err := s.db.SelectContext(ctx, &leaderboard, `
+	WITH filtered_matches AS (`+leaderboardMatches+`
+	)
 	SELECT
-		users.id AS reporter_id, COUNT(reports.reporter_id) AS num_reports
+		users.id AS reporter_id,
+		users.first_name,
+		users.last_name,
+		(
+			SELECT COUNT(*)
+			FROM reports
+			INNER JOIN filtered_matches
+				ON filtered_matches.key = reports.match_key
+			WHERE reports.reporter_id = users.id AND reports.realm_id = $4 AND reports.excluded = false
+		) AS num_reports,
+		(
+			SELECT COUNT(*)
+			FROM comments
+			INNER JOIN filtered_matches
+				ON filtered_matches.key = comments.match_key
+			WHERE comments.reporter_id = users.id AND comments.realm_id = $4 AND comments.excluded = false
+		) AS num_comments
 	FROM users
-	LEFT JOIN reports
-		ON (users.id = reports.reporter_id)
+	INNER JOIN realm_memberships
+		ON realm_memberships.user_id = users.id
+	WHERE
+		realm_memberships.realm_id = $4 AND
+		realm_memberships.pending = false
+	ORDER BY num_reports DESC, num_comments DESC, users.id
+	`, append(filter.args(), realmID)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select leaderboard: %w", err)
+	}
+
+	return leaderboard, nil
+}
+
+// GetReporterMatchesForRealm returns the matches matching the filter that each
+// user in the given realm submitted reports for.
+func (s *Service) GetReporterMatchesForRealm(ctx context.Context, realmID int64, filter LeaderboardFilter) ([]ReporterMatch, error) {
+	matches := []ReporterMatch{}
+
+	err := s.db.SelectContext(ctx, &matches, `
+	WITH filtered_matches AS (`+leaderboardMatches+`
+	)
+	SELECT DISTINCT
+		reports.reporter_id,
+		filtered_matches.event_key,
+		filtered_matches.match_number
+	FROM reports
+	INNER JOIN filtered_matches
+		ON filtered_matches.key = reports.match_key
 	WHERE
-		users.realm_id = $1
-	GROUP BY users.id
-	ORDER BY num_reports DESC;
-	`, realmID)
+		reports.realm_id = $4 AND
+		reports.excluded = false
+	ORDER BY reports.reporter_id, filtered_matches.event_key, filtered_matches.match_number
+	`, append(filter.args(), realmID)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select reporter matches: %w", err)
+	}
+
+	return matches, nil
+}
+
+// GetEventReportKeys returns the keys of all reports made by a realm at an event.
+func (s *Service) GetEventReportKeys(ctx context.Context, eventKey string, realmID int64) ([]ReportKey, error) {
+	keys := []ReportKey{}
+	err := s.db.SelectContext(ctx, &keys, `
+	SELECT match_key, team_key, reporter_id
+	FROM reports
+	WHERE event_key = $1 AND realm_id = $2
+	`, eventKey, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select event report keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// GetReportReporterID returns the ID of the reporter of a report in a realm,
+// which is nil if the reporter has been deleted. If the report doesn't exist
+// ErrNoResults is returned.
+func (s *Service) GetReportReporterID(ctx context.Context, target ReportTarget) (*int64, error) {
+	var reporterID *int64
+	err := s.db.GetContext(ctx, &reporterID, `
+	SELECT reporter_id
+	FROM reports
+	WHERE id = $1 AND match_key = $2 AND team_key = $3 AND realm_id = $4
+	`, target.ID, target.MatchKey, target.TeamKey, target.RealmID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoResults{fmt.Errorf("report does not exist: %w", err)}
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to select report reporter: %w", err)
+	}
+
+	return reporterID, nil
+}
+
+// DeleteReport deletes a report in a realm, along with its revisions. If the
+// report doesn't exist ErrNoResults is returned.
+func (s *Service) DeleteReport(ctx context.Context, target ReportTarget) error {
+	result, err := s.db.ExecContext(ctx, `
+	DELETE FROM reports
+	WHERE id = $1 AND match_key = $2 AND team_key = $3 AND realm_id = $4
+	`, target.ID, target.MatchKey, target.TeamKey, target.RealmID)
+	if err != nil {
+		return fmt.Errorf("unable to delete report: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("report does not exist")}
+	}
+
+	return nil
+}
+
+// SetReportExcluded sets whether a report in a realm is excluded from stats. If
+// the report doesn't exist ErrNoResults is returned.
+func (s *Service) SetReportExcluded(ctx context.Context, target ReportTarget, excluded bool) error {
+	result, err := s.db.ExecContext(ctx, `
+	UPDATE reports
+	SET excluded = $5
+	WHERE id = $1 AND match_key = $2 AND team_key = $3 AND realm_id = $4
+	`, target.ID, target.MatchKey, target.TeamKey, target.RealmID, excluded)
+	if err != nil {
+		return fmt.Errorf("unable to update report: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("report does not exist")}
+	}
+
+	return nil
 }
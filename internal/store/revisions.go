@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ReportTarget identifies a single report or comment by its ID, along with the
+// match and team it is for and the realm it must belong to.
+type ReportTarget struct {
+	ID       int64
+	MatchKey string
+	TeamKey  string
+	RealmID  int64
+}
+
+// ReportRevision is a single edit to a report. OldData is nil for the revision
+// that created the report. EditorID is nil if the editor has been deleted.
+type ReportRevision struct {
+	ID        int64       `json:"id" db:"id"`
+	ReportID  int64       `json:"-" db:"report_id"`
+	EditorID  *int64      `json:"editorId" db:"editor_id"`
+	CreatedAt time.Time   `json:"createdAt" db:"created_at"`
+	OldData   *ReportData `json:"oldData" db:"old_data"`
+	Data      ReportData  `json:"data" db:"data"`
+}
+
+// CommentRevision is a single edit to a comment. OldComment is nil for the
+// revision that created the comment. EditorID is nil if the editor has been
+// deleted.
+type CommentRevision struct {
+	ID         int64     `json:"id" db:"id"`
+	CommentID  int64     `json:"-" db:"comment_id"`
+	EditorID   *int64    `json:"editorId" db:"editor_id"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+	OldComment *string   `json:"oldComment" db:"old_comment"`
+	Comment    string    `json:"comment" db:"comment"`
+}
+
+func insertReportRevision(ctx context.Context, tx *sqlx.Tx, reportID int64, editorID *int64, oldData *ReportData, data ReportData) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO report_revisions (report_id, editor_id, old_data, data)
+	VALUES ($1, $2, $3, $4)
+	`, reportID, editorID, oldData, data)
+	if err != nil {
+		return fmt.Errorf("unable to insert report revision: %w", err)
+	}
+
+	return nil
+}
+
+func insertCommentRevision(ctx context.Context, tx *sqlx.Tx, commentID int64, editorID *int64, oldComment *string, comment string) error {
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO comment_revisions (comment_id, editor_id, old_comment, comment)
+	VALUES ($1, $2, $3, $4)
+	`, commentID, editorID, oldComment, comment)
+	if err != nil {
+		return fmt.Errorf("unable to insert comment revision: %w", err)
+	}
+
+	return nil
+}
+
+// GetReportRevisions returns every revision of a report in a realm, oldest
+// first. If the report doesn't exist ErrNoResults is returned.
+func (s *Service) GetReportRevisions(ctx context.Context, target ReportTarget) ([]ReportRevision, error) {
+	var reportID int64
+	err := s.db.GetContext(ctx, &reportID, `
+	SELECT id
+	FROM reports
+	WHERE id = $1 AND match_key = $2 AND team_key = $3 AND realm_id = $4
+	`, target.ID, target.MatchKey, target.TeamKey, target.RealmID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoResults{fmt.Errorf("report does not exist: %w", err)}
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to select report: %w", err)
+	}
+
+	revisions := []ReportRevision{}
+	err = s.db.SelectContext(ctx, &revisions, `
+	SELECT *
+	FROM report_revisions
+	WHERE report_id = $1
+	ORDER BY id
+	`, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select report revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// RevertReport sets the data of a report in a realm back to the data from one of
+// its revisions, recording the revert as a new revision by the editor. If the
+// report or revision doesn't exist ErrNoResults is returned.
+func (s *Service) RevertReport(ctx context.Context, target ReportTarget, revisionID, editorID int64) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		var report struct {
+			ID      int64      `db:"id"`
+			OldData ReportData `db:"old_data"`
+			Data    ReportData `db:"data"`
+		}
+
+		err := tx.GetContext(ctx, &report, `
+		SELECT reports.id, reports.data AS old_data, report_revisions.data
+		FROM reports
+		INNER JOIN report_revisions
+			ON report_revisions.report_id = reports.id
+		WHERE
+			reports.id = $1 AND
+			reports.match_key = $2 AND
+			reports.team_key = $3 AND
+			reports.realm_id = $4 AND
+			report_revisions.id = $5
+		FOR UPDATE OF reports
+		`, target.ID, target.MatchKey, target.TeamKey, target.RealmID, revisionID)
+		if err == sql.ErrNoRows {
+			return ErrNoResults{fmt.Errorf("report revision does not exist: %w", err)}
+		} else if err != nil {
+			return fmt.Errorf("unable to select report revision: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE reports SET data = $1 WHERE id = $2", report.Data, report.ID); err != nil {
+			return fmt.Errorf("unable to update report: %w", err)
+		}
+
+		return insertReportRevision(ctx, tx, report.ID, &editorID, &report.OldData, report.Data)
+	})
+}
+
+// GetCommentRevisions returns every revision of a comment in a realm, oldest
+// first. If the comment doesn't exist ErrNoResults is returned.
+func (s *Service) GetCommentRevisions(ctx context.Context, target ReportTarget) ([]CommentRevision, error) {
+	var commentID int64
+	err := s.db.GetContext(ctx, &commentID, `
+	SELECT id
+	FROM comments
+	WHERE id = $1 AND match_key = $2 AND team_key = $3 AND realm_id = $4
+	`, target.ID, target.MatchKey, target.TeamKey, target.RealmID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoResults{fmt.Errorf("comment does not exist: %w", err)}
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to select comment: %w", err)
+	}
+
+	revisions := []CommentRevision{}
+	err = s.db.SelectContext(ctx, &revisions, `
+	SELECT *
+	FROM comment_revisions
+	WHERE comment_id = $1
+	ORDER BY id
+	`, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select comment revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// RevertComment sets a comment in a realm back to the comment from one of its
+// revisions, recording the revert as a new revision by the editor. If the
+// comment or revision doesn't exist ErrNoResults is returned.
+func (s *Service) RevertComment(ctx context.Context, target ReportTarget, revisionID, editorID int64) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		var comment struct {
+			ID         int64  `db:"id"`
+			OldComment string `db:"old_comment"`
+			Comment    string `db:"comment"`
+		}
+
+		err := tx.GetContext(ctx, &comment, `
+		SELECT comments.id, comments.comment AS old_comment, comment_revisions.comment
+		FROM comments
+		INNER JOIN comment_revisions
+			ON comment_revisions.comment_id = comments.id
+		WHERE
+			comments.id = $1 AND
+			comments.match_key = $2 AND
+			comments.team_key = $3 AND
+			comments.realm_id = $4 AND
+			comment_revisions.id = $5
+		FOR UPDATE OF comments
+		`, target.ID, target.MatchKey, target.TeamKey, target.RealmID, revisionID)
+		if err == sql.ErrNoRows {
+			return ErrNoResults{fmt.Errorf("comment revision does not exist: %w", err)}
+		} else if err != nil {
+			return fmt.Errorf("unable to select comment revision: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE comments SET comment = $1 WHERE id = $2", comment.Comment, comment.ID); err != nil {
+			return fmt.Errorf("unable to update comment: %w", err)
+		}
+
+		return insertCommentRevision(ctx, tx, comment.ID, &editorID, &comment.OldComment, comment.Comment)
+	})
+}
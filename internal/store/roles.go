@@ -0,0 +1,259 @@
+package store
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Permission is the name of something a user is allowed to do. Users are granted
+// permissions through their roles.
+type Permission string
+
+// Permissions that can be granted by roles.
+const (
+	// PermissionViewReports allows viewing the realm's scouting analysis, such as
+	// reporter reliability, coverage, assignments, and report history.
+	PermissionViewReports Permission = "reports:view"
+	// PermissionSubmitReports allows submitting reports, comments, pit reports,
+	// and media, and modifying or deleting your own.
+	PermissionSubmitReports Permission = "reports:submit"
+	// PermissionModerateReports allows reverting, deleting, and excluding
+	// anyone's reports, comments, and media in the realm.
+	PermissionModerateReports Permission = "reports:moderate"
+	// PermissionManageScouting allows assigning scouts to matches.
+	PermissionManageScouting Permission = "scouting:manage"
+	// PermissionManageEvents allows creating and editing the realm's events and
+	// matches.
+	PermissionManageEvents Permission = "events:manage"
+	// PermissionManageSchemas allows creating the realm's schemas.
+	PermissionManageSchemas Permission = "schemas:manage"
+	// PermissionManageUsers allows editing and deleting users in the realm and
+	// assigning them roles.
+	PermissionManageUsers Permission = "users:manage"
+	// PermissionManageRealm allows editing and deleting the realm, its roles,
+	// and its comment tag catalog.
+	PermissionManageRealm Permission = "realm:manage"
+	// PermissionManageAll allows every permission in every realm, as well as
+	// managing TBA events and year schemas.
+	PermissionManageAll Permission = "all:manage"
+)
+
+// AllPermissions are all the permissions that can be granted.
+var AllPermissions = Permissions{
+	PermissionViewReports,
+	PermissionSubmitReports,
+	PermissionModerateReports,
+	PermissionManageScouting,
+	PermissionManageEvents,
+	PermissionManageSchemas,
+	PermissionManageUsers,
+	PermissionManageRealm,
+	PermissionManageAll,
+}
+
+// Permissions is a set of permissions.
+type Permissions []Permission
+
+// Has returns whether p is in the set of permissions. Users who can manage all
+// have every permission.
+func (ps Permissions) Has(p Permission) bool {
+	for _, permission := range ps {
+		if permission == p || permission == PermissionManageAll {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Value is provided for returning permissions as a PostgreSQL text array.
+func (ps Permissions) Value() (driver.Value, error) {
+	strs := make(pq.StringArray, len(ps))
+	for i, p := range ps {
+		strs[i] = string(p)
+	}
+
+	return strs.Value()
+}
+
+// Scan is provided for scanning a PostgreSQL text array into permissions.
+func (ps *Permissions) Scan(src interface{}) error {
+	var strs pq.StringArray
+	if err := strs.Scan(src); err != nil {
+		return err
+	}
+
+	*ps = make(Permissions, len(strs))
+	for i, s := range strs {
+		(*ps)[i] = Permission(s)
+	}
+
+	return nil
+}
+
+// Names of the built-in roles, which exist in every realm.
+const (
+	RoleViewer     = "viewer"
+	RoleScout      = "scout"
+	RoleStrategist = "strategist"
+	RoleAdmin      = "admin"
+	RoleSuperAdmin = "super-admin"
+)
+
+// Role is a named set of permissions that can be given to users. Built-in roles
+// have no realm and can be given to users in any realm, while other roles are
+// defined by and only available in a single realm.
+type Role struct {
+	ID          int64       `json:"id" db:"id"`
+	RealmID     *int64      `json:"realmId" db:"realm_id"`
+	Name        string      `json:"name" db:"name" validate:"gte=1,lte=32"`
+	Permissions Permissions `json:"permissions" db:"permissions"`
+}
+
+// GetRoles returns the roles available in a realm, both built-in and defined by
+// the realm, ordered by name.
+func (s *Service) GetRoles(ctx context.Context, realmID int64) ([]Role, error) {
+	roles := []Role{}
+	err := s.db.SelectContext(ctx, &roles, `
+	SELECT *
+	FROM roles
+	WHERE realm_id IS NULL OR realm_id = $1
+	ORDER BY name
+	`, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// CreateRole creates a role for a realm and returns its ID. If a role with the
+// same name is already available in the realm ErrExists is returned.
+func (s *Service) CreateRole(ctx context.Context, role Role) (int64, error) {
+	var id int64
+
+	err := s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		var exists bool
+		err := tx.GetContext(ctx, &exists, `
+		SELECT EXISTS(SELECT true FROM roles WHERE name = $1 AND (realm_id IS NULL OR realm_id = $2))
+		`, role.Name, role.RealmID)
+		if err != nil {
+			return fmt.Errorf("unable to select whether role exists: %w", err)
+		} else if exists {
+			return ErrExists{fmt.Errorf("role %q already exists", role.Name)}
+		}
+
+		err = tx.GetContext(ctx, &id, `
+		INSERT INTO roles (realm_id, name, permissions)
+		VALUES ($1, $2, $3)
+		RETURNING id
+		`, role.RealmID, role.Name, role.Permissions)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgExists {
+			return ErrExists{fmt.Errorf("role %q already exists: %w", role.Name, err)}
+		} else if err != nil {
+			return fmt.Errorf("unable to insert role: %w", err)
+		}
+
+		return nil
+	})
+
+	return id, err
+}
+
+// UpdateRolePermissions sets the permissions of a role defined by a realm.
+// Built-in roles can't be updated. If the role doesn't exist in the realm
+// ErrNoResults is returned.
+func (s *Service) UpdateRolePermissions(ctx context.Context, realmID, id int64, permissions Permissions) error {
+	result, err := s.db.ExecContext(ctx, `
+	UPDATE roles
+	SET permissions = $3
+	WHERE realm_id = $1 AND id = $2
+	`, realmID, id, permissions)
+	if err != nil {
+		return fmt.Errorf("unable to update role: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("role does not exist")}
+	}
+
+	return nil
+}
+
+// DeleteRole deletes a role defined by a realm, removing it from every user.
+// Built-in roles can't be deleted. If the role doesn't exist in the realm
+// ErrNoResults is returned.
+func (s *Service) DeleteRole(ctx context.Context, realmID, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM roles WHERE realm_id = $1 AND id = $2", realmID, id)
+	if err != nil {
+		return fmt.Errorf("unable to delete role: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("role does not exist")}
+	}
+
+	return nil
+}
+
+// GetRolesByName returns the roles available in a realm with the given names.
+// If any of the roles don't exist ErrNoResults is returned.
+func (s *Service) GetRolesByName(ctx context.Context, realmID int64, names []string) ([]Role, error) {
+	return getRolesByName(ctx, s.db, realmID, names)
+}
+
+func getRolesByName(ctx context.Context, q sqlx.QueryerContext, realmID int64, names []string) ([]Role, error) {
+	roles := []Role{}
+	err := sqlx.SelectContext(ctx, q, &roles, `
+	SELECT *
+	FROM roles
+	WHERE name = ANY($1) AND (realm_id IS NULL OR realm_id = $2)
+	`, pq.StringArray(names), realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select roles: %w", err)
+	}
+
+	found := make(map[string]bool)
+	for _, role := range roles {
+		found[role.Name] = true
+	}
+
+	for _, name := range names {
+		if !found[name] {
+			return nil, ErrNoResults{fmt.Errorf("role %q does not exist", name)}
+		}
+	}
+
+	return roles, nil
+}
+
+// setUserRolesTx replaces the roles of a user in a realm with the roles available
+// in the realm with the given names using the given transaction. The user must be
+// a member of the realm. If any of the roles don't exist ErrNoResults is returned.
+func setUserRolesTx(ctx context.Context, tx *sqlx.Tx, userID, realmID int64, names []string) error {
+	roles, err := getRolesByName(ctx, tx, realmID, names)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND realm_id = $2", userID, realmID); err != nil {
+		return fmt.Errorf("unable to delete user roles: %w", err)
+	}
+
+	for _, role := range roles {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO user_roles (user_id, realm_id, role_id) VALUES ($1, $2, $3)", userID, realmID, role.ID); err != nil {
+			return fmt.Errorf("unable to insert user role: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -13,11 +13,19 @@ import (
 	"github.com/lib/pq"
 )
 
+// Schema kinds. Match schemas describe match reports and how to summarize them,
+// pit schemas describe pit reports.
+const (
+	SchemaKindMatch = "match"
+	SchemaKindPit   = "pit"
+)
+
 // Schema describes the statistics that reports should include
 type Schema struct {
 	ID      int64        `json:"id" db:"id"`
 	Year    *int64       `json:"year,omitempty" db:"year"`
 	RealmID *int64       `json:"realmId,omitempty" db:"realm_id"`
+	Kind    string       `json:"kind" db:"kind"`
 	Schema  SchemaFields `json:"schema" db:"schema"`
 }
 
@@ -28,17 +36,23 @@ type FieldDescriptor struct {
 }
 
 // SchemaField is a singular schema field. Only specify one of: ReportReference, TBAReference,
-// Sum, or AnyOf.
+// Sum, or AnyOf. A ReportReference field may also specify VerifyTBAReference, a TBA reference
+// that reported values are checked against to score reporter reliability, with VerifyValues
+// mapping string TBA values to numbers. Pit schema fields only use the name, type, and for
+// string fields the Options the value must be one of (if any).
 type SchemaField struct {
 	FieldDescriptor
-	ReportReference string            `json:"reportReference,omitempty"`
-	TBAReference    string            `json:"tbaReference,omitempty"`
-	Sum             []FieldDescriptor `json:"sum,omitempty"`
-	AnyOf           []EqualExpression `json:"anyOf,omitempty"`
-
-	Hide   bool   `json:"hide,omitempty"`
-	Type   string `json:"type,omitempty"`
-	Period string `json:"period,omitempty"`
+	ReportReference    string             `json:"reportReference,omitempty"`
+	TBAReference       string             `json:"tbaReference,omitempty"`
+	Sum                []FieldDescriptor  `json:"sum,omitempty"`
+	AnyOf              []EqualExpression  `json:"anyOf,omitempty"`
+	VerifyTBAReference string             `json:"verifyTbaReference,omitempty"`
+	VerifyValues       map[string]float64 `json:"verifyValues,omitempty"`
+
+	Hide    bool     `json:"hide,omitempty"`
+	Type    string   `json:"type,omitempty"`
+	Period  string   `json:"period,omitempty"`
+	Options []string `json:"options,omitempty"`
 }
 
 // EqualExpression defines a reference that should equal some JSON value (float64, number,
@@ -70,8 +84,8 @@ func (s *Service) CreateSchema(ctx context.Context, schema Schema) error {
 		_, err := tx.NamedExecContext(ctx, `
 		INSERT
 			INTO
-				schemas (year, realm_id, schema)
-			VALUES (:year, :realm_id, :schema)
+				schemas (year, realm_id, kind, schema)
+			VALUES (:year, :realm_id, :kind, :schema)
 		`, schema)
 
 		if pgErr, ok := err.(*pq.Error); ok && pgErr.Code == pgExists {
@@ -98,13 +112,13 @@ func (s *Service) GetSchemaByID(ctx context.Context, id int64) (Schema, error) {
 	return schema, nil
 }
 
-// GetSchemaByYear retrieves the schema for a given year
-func (s *Service) GetSchemaByYear(ctx context.Context, year int) (Schema, error) {
+// GetSchemaByYear retrieves the schema of a kind for a given year
+func (s *Service) GetSchemaByYear(ctx context.Context, year int, kind string) (Schema, error) {
 	var schema Schema
 
-	err := s.db.GetContext(ctx, &schema, "SELECT * FROM schemas WHERE year = $1", year)
+	err := s.db.GetContext(ctx, &schema, "SELECT * FROM schemas WHERE year = $1 AND kind = $2", year, kind)
 	if err == sql.ErrNoRows {
-		return schema, ErrNoResults{fmt.Errorf("no schema for year %d exists", year)}
+		return schema, ErrNoResults{fmt.Errorf("no %s schema for year %d exists", kind, year)}
 	} else if err != nil {
 		return schema, fmt.Errorf("unable to retrieve schema: %w", err)
 	}
@@ -113,8 +127,9 @@ func (s *Service) GetSchemaByYear(ctx context.Context, year int) (Schema, error)
 }
 
 // GetSchemasForRealm retrieves schemas from the database frm a specific realm,
-// from realms with public events, and standard FRC schemas. If the realm ID is
-// nil, no private realms' schemas will be retrieved.
+// from realms with public events or sharing reports or pit data with the realm,
+// and standard FRC schemas. If the realm ID is nil, no private realms' schemas
+// will be retrieved.
 func (s *Service) GetSchemasForRealm(ctx context.Context, realmID *int64) ([]Schema, error) {
 	schemas := []Schema{}
 
@@ -126,7 +141,7 @@ func (s *Service) GetSchemasForRealm(ctx context.Context, realmID *int64) ([]Sch
 	WHERE
 		schemas.year IS NULL OR
 		realms.id = NULL OR
-		(realms.share_reports = true OR realms.id = $1)
+		`+sharedWith("$1", "", sharesReports+" OR "+sharesPitData)+`
 	`, realmID)
 	if err != nil {
 		return schemas, fmt.Errorf("unable to retrieve schemas: %w", err)
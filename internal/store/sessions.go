@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Session is a device a user is logged in on. Each refresh token belongs to a
+// session, and using it replaces it with a token with the next generation.
+type Session struct {
+	ID         int64     `json:"id" db:"id"`
+	UserID     int64     `json:"userId" db:"user_id"`
+	DeviceName string    `json:"deviceName" db:"device_name"`
+	IP         string    `json:"ip" db:"ip"`
+	Generation int64     `json:"-" db:"generation"`
+	ExpiresAt  time.Time `json:"expiresAt" db:"expires_at"`
+	LastUsedAt time.Time `json:"lastUsedAt" db:"last_used_at"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
+
+// CreateSession creates a session and returns its ID.
+func (s *Service) CreateSession(ctx context.Context, session Session) (int64, error) {
+	var id int64
+	err := s.db.GetContext(ctx, &id, `
+	INSERT INTO sessions (user_id, device_name, ip, expires_at)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id
+	`, session.UserID, session.DeviceName, session.IP, session.ExpiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("unable to insert session: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetUserSessions returns a user's sessions that haven't been revoked or expired,
+// most recently used first.
+func (s *Service) GetUserSessions(ctx context.Context, userID int64) ([]Session, error) {
+	sessions := []Session{}
+	err := s.db.SelectContext(ctx, &sessions, `
+	SELECT id, user_id, device_name, ip, generation, expires_at, last_used_at, created_at
+	FROM sessions
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+	ORDER BY last_used_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// CheckSession checks that a user's session is still active. If the session
+// doesn't exist, has been revoked, or has expired ErrNoResults is returned.
+func (s *Service) CheckSession(ctx context.Context, userID, id int64) error {
+	var active bool
+	err := s.db.GetContext(ctx, &active, `
+	SELECT EXISTS (
+		SELECT 1
+		FROM sessions
+		WHERE user_id = $1 AND id = $2 AND revoked_at IS NULL AND expires_at > now()
+	)
+	`, userID, id)
+	if err != nil {
+		return fmt.Errorf("unable to check session: %w", err)
+	} else if !active {
+		return ErrNoResults{errors.New("session does not exist")}
+	}
+
+	return nil
+}
+
+// RotateSession records that the refresh token with the given generation was
+// used from ip, and returns the generation of the token that replaces it. If the
+// session doesn't exist, has been revoked, or has expired ErrNoResults is
+// returned. If the token was already replaced it may have been stolen, so the
+// session is revoked and ErrNoResults is returned.
+func (s *Service) RotateSession(ctx context.Context, userID, id, generation int64, ip string, expiresAt time.Time) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+	UPDATE sessions
+	SET generation = generation + 1, ip = $4, expires_at = $5, last_used_at = now()
+	WHERE
+		user_id = $1 AND
+		id = $2 AND
+		generation = $3 AND
+		revoked_at IS NULL AND
+		expires_at > now()
+	`, userID, id, generation, ip, expiresAt)
+	if err != nil {
+		return 0, fmt.Errorf("unable to rotate session: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 1 {
+		return generation + 1, nil
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+	UPDATE sessions
+	SET revoked_at = now()
+	WHERE user_id = $1 AND id = $2 AND generation > $3 AND revoked_at IS NULL
+	`, userID, id, generation)
+	if err != nil {
+		return 0, fmt.Errorf("unable to revoke session with reused refresh token: %w", err)
+	}
+
+	return 0, ErrNoResults{errors.New("session does not exist")}
+}
+
+// RevokeSession revokes a user's session so its refresh token can no longer be
+// used. If the session doesn't exist or was already revoked ErrNoResults is
+// returned.
+func (s *Service) RevokeSession(ctx context.Context, userID, id int64) error {
+	result, err := s.db.ExecContext(ctx, `
+	UPDATE sessions
+	SET revoked_at = now()
+	WHERE user_id = $1 AND id = $2 AND revoked_at IS NULL
+	`, userID, id)
+	if err != nil {
+		return fmt.Errorf("unable to revoke session: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("session does not exist")}
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes all of a user's sessions.
+func (s *Service) RevokeUserSessions(ctx context.Context, userID int64) error {
+	_, err := s.db.ExecContext(ctx, `
+	UPDATE sessions
+	SET revoked_at = now()
+	WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("unable to revoke sessions: %w", err)
+	}
+
+	return nil
+}
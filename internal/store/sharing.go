@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SharingAgreement is an agreement between two realms to share data with each
+// other. The realm with RealmID invites the partner realm, and once the partner
+// accepts both realms can see each other's reports, comments, and pit data
+// (pit reports and media) as chosen by the agreement. If EventKey is not nil
+// only data from that event is shared.
+type SharingAgreement struct {
+	ID             int64     `json:"id" db:"id"`
+	RealmID        int64     `json:"realmId" db:"realm_id"`
+	PartnerRealmID int64     `json:"partnerRealmId" db:"partner_realm_id" validate:"required"`
+	EventKey       *string   `json:"eventKey" db:"event_key"`
+	ShareReports   bool      `json:"shareReports" db:"share_reports"`
+	ShareComments  bool      `json:"shareComments" db:"share_comments"`
+	SharePitData   bool      `json:"sharePitData" db:"share_pit_data"`
+	Accepted       bool      `json:"accepted" db:"accepted"`
+	CreatedAt      time.Time `json:"createdAt" db:"created_at"`
+}
+
+// Conditions on the kind of data an agreement shares, for use with sharedWith.
+const (
+	sharesReports  = "sharing_agreements.share_reports"
+	sharesComments = "sharing_agreements.share_comments"
+	sharesPitData  = "sharing_agreements.share_pit_data"
+)
+
+// sharedWith returns a condition for whether data owned by realms.id is visible
+// to the realm given by the query parameter realmParam: the data's realm is the
+// same realm, shares all its reports, or has an accepted agreement with the
+// realm matching the shares condition. If eventKeyColumn is not empty, agreements
+// limited to an event only match data with that event key.
+func sharedWith(realmParam, eventKeyColumn, shares string) string {
+	eventCondition := ""
+	if eventKeyColumn != "" {
+		eventCondition = fmt.Sprintf(" AND\n\t\t\t(sharing_agreements.event_key IS NULL OR sharing_agreements.event_key = %s)", eventKeyColumn)
+	}
+
+	return fmt.Sprintf(`(realms.share_reports = true OR realms.id = %[1]s OR EXISTS(
+			SELECT true
+			FROM sharing_agreements
+			WHERE
+			sharing_agreements.accepted = true AND
+			(%[2]s) AND
+			((sharing_agreements.realm_id = realms.id AND sharing_agreements.partner_realm_id = %[1]s) OR
+				(sharing_agreements.partner_realm_id = realms.id AND sharing_agreements.realm_id = %[1]s))%[3]s
+		))`, realmParam, shares, eventCondition)
+}
+
+// GetSharingAgreements returns the agreements a realm is part of, including
+// pending invitations to and from it, newest first.
+func (s *Service) GetSharingAgreements(ctx context.Context, realmID int64) ([]SharingAgreement, error) {
+	agreements := []SharingAgreement{}
+	err := s.db.SelectContext(ctx, &agreements, `
+	SELECT *
+	FROM sharing_agreements
+	WHERE realm_id = $1 OR partner_realm_id = $1
+	ORDER BY created_at DESC, id DESC
+	`, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select sharing agreements: %w", err)
+	}
+
+	return agreements, nil
+}
+
+// CreateSharingAgreement invites the partner realm to an agreement and returns
+// its ID. If the partner realm or event don't exist ErrFKeyViolation is returned.
+func (s *Service) CreateSharingAgreement(ctx context.Context, a SharingAgreement) (int64, error) {
+	var id int64
+	err := s.db.GetContext(ctx, &id, `
+	INSERT INTO sharing_agreements (realm_id, partner_realm_id, event_key, share_reports, share_comments, share_pit_data)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+	`, a.RealmID, a.PartnerRealmID, a.EventKey, a.ShareReports, a.ShareComments, a.SharePitData)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgFKeyViolation {
+		return 0, ErrFKeyViolation{fmt.Errorf("sharing agreement fk violation: %w", err)}
+	} else if err != nil {
+		return 0, fmt.Errorf("unable to insert sharing agreement: %w", err)
+	}
+
+	return id, nil
+}
+
+// AcceptSharingAgreement accepts an invitation to an agreement sent to the
+// partner realm. If there is no such pending invitation ErrNoResults is returned.
+func (s *Service) AcceptSharingAgreement(ctx context.Context, partnerRealmID, id int64) (SharingAgreement, error) {
+	var a SharingAgreement
+	err := s.db.GetContext(ctx, &a, `
+	UPDATE sharing_agreements
+	SET accepted = true
+	WHERE id = $1 AND partner_realm_id = $2 AND accepted = false
+	RETURNING *
+	`, id, partnerRealmID)
+	if err == sql.ErrNoRows {
+		return a, ErrNoResults{fmt.Errorf("sharing agreement %d does not exist: %w", id, err)}
+	} else if err != nil {
+		return a, fmt.Errorf("unable to accept sharing agreement: %w", err)
+	}
+
+	return a, nil
+}
+
+// DeleteSharingAgreement ends an agreement, or withdraws or declines an
+// invitation. Either realm in the agreement can delete it. If the agreement
+// doesn't exist or the realm isn't part of it ErrNoResults is returned.
+func (s *Service) DeleteSharingAgreement(ctx context.Context, realmID, id int64) error {
+	result, err := s.db.ExecContext(ctx, `
+	DELETE FROM sharing_agreements
+	WHERE id = $1 AND (realm_id = $2 OR partner_realm_id = $2)
+	`, id, realmID)
+	if err != nil {
+		return fmt.Errorf("unable to delete sharing agreement: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("sharing agreement does not exist")}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// CommentTag is a tag in a realm's catalog that comments in the realm can be
+// tagged with, e.g. "defense" or "brownout".
+type CommentTag struct {
+	ID      int64  `json:"id" db:"id"`
+	RealmID int64  `json:"-" db:"realm_id"`
+	Name    string `json:"name" db:"name" validate:"gte=1,lte=32"`
+}
+
+// TagCount is the number of comments on a team with a tag.
+type TagCount struct {
+	TeamKey string `json:"-" db:"team_key"`
+	Name    string `json:"name" db:"name"`
+	Count   int    `json:"count" db:"count"`
+}
+
+// commentTagsColumn selects the names of the tags on a comment as an array, to be
+// scanned into Comment.Tags.
+const commentTagsColumn = `
+	ARRAY(
+		SELECT comment_tags.name
+		FROM comments_comment_tags
+		INNER JOIN comment_tags
+			ON comment_tags.id = comments_comment_tags.tag_id
+		WHERE comments_comment_tags.comment_id = comments.id
+		ORDER BY comment_tags.name
+	) AS tags`
+
+// GetCommentTags returns the tag catalog of a realm, ordered by name.
+func (s *Service) GetCommentTags(ctx context.Context, realmID int64) ([]CommentTag, error) {
+	tags := []CommentTag{}
+	err := s.db.SelectContext(ctx, &tags, `
+	SELECT *
+	FROM comment_tags
+	WHERE realm_id = $1
+	ORDER BY name
+	`, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select comment tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// CreateCommentTag adds a tag to a realm's catalog and returns its ID. If the
+// realm already has a tag with the same name ErrExists is returned.
+func (s *Service) CreateCommentTag(ctx context.Context, tag CommentTag) (int64, error) {
+	var id int64
+	err := s.db.GetContext(ctx, &id, `
+	INSERT INTO comment_tags (realm_id, name)
+	VALUES ($1, $2)
+	RETURNING id
+	`, tag.RealmID, tag.Name)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgExists {
+		return 0, ErrExists{fmt.Errorf("comment tag %q already exists: %w", tag.Name, err)}
+	} else if err != nil {
+		return 0, fmt.Errorf("unable to insert comment tag: %w", err)
+	}
+
+	return id, nil
+}
+
+// DeleteCommentTag removes a tag from a realm's catalog and from every comment
+// tagged with it. If the tag doesn't exist in the realm ErrNoResults is returned.
+func (s *Service) DeleteCommentTag(ctx context.Context, realmID, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM comment_tags WHERE realm_id = $1 AND id = $2", realmID, id)
+	if err != nil {
+		return fmt.Errorf("unable to delete comment tag: %w", err)
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("unable to determine rows affected: %w", err)
+	} else if n == 0 {
+		return ErrNoResults{errors.New("comment tag does not exist")}
+	}
+
+	return nil
+}
+
+// setCommentTagsTx replaces the tags on a comment with the tags from the realm's
+// catalog with the given names using the given transaction.
+func setCommentTagsTx(ctx context.Context, tx *sqlx.Tx, commentID, realmID int64, names []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM comments_comment_tags WHERE comment_id = $1", commentID); err != nil {
+		return fmt.Errorf("unable to delete comment tags: %w", err)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO comments_comment_tags (comment_id, tag_id)
+	SELECT $1, id
+	FROM comment_tags
+	WHERE realm_id = $2 AND name = ANY($3)
+	`, commentID, realmID, pq.StringArray(names))
+	if err != nil {
+		return fmt.Errorf("unable to insert comment tags: %w", err)
+	}
+
+	return nil
+}
+
+// GetTagCountsForRealm counts the tags on comments about each team at an event,
+// or in a single match if matchKey is not nil. Excluded comments are not counted.
+// Only comments from realms that are sharing them with the realm or have a
+// matching realm ID are counted, and tags from different realms with the same name are counted
+// together. The counts are keyed by team and ordered by count, largest first.
+func (s *Service) GetTagCountsForRealm(ctx context.Context, eventKey string, matchKey *string, realmID *int64) (map[string][]TagCount, error) {
+	var counts []TagCount
+	err := s.db.SelectContext(ctx, &counts, `
+	SELECT comments.team_key, comment_tags.name, COUNT(*) AS count
+	FROM comments
+	INNER JOIN realms
+		ON realms.id = comments.realm_id
+	INNER JOIN comments_comment_tags
+		ON comments_comment_tags.comment_id = comments.id
+	INNER JOIN comment_tags
+		ON comment_tags.id = comments_comment_tags.tag_id
+	WHERE
+		comments.event_key = $1 AND
+		($2::TEXT IS NULL OR comments.match_key = $2) AND
+		comments.excluded = false AND
+		`+sharedWith("$3", "comments.event_key", sharesComments)+`
+	GROUP BY comments.team_key, comment_tags.name
+	ORDER BY count DESC, comment_tags.name
+	`, eventKey, matchKey, realmID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to select tag counts: %w", err)
+	}
+
+	teamCounts := make(map[string][]TagCount)
+	for _, c := range counts {
+		teamCounts[c.TeamKey] = append(teamCounts[c.TeamKey], c)
+	}
+
+	return teamCounts, nil
+}
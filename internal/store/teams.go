@@ -6,20 +6,27 @@ import (
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // EventTeam holds data about a single FRC team at a specific event.
 type EventTeam struct {
-	Key          string   `json:"team" db:"key"`
-	EventKey     string   `json:"-" db:"event_key"`
-	Rank         *int     `json:"rank,omitempty" db:"rank"`
-	RankingScore *float64 `json:"rankingScore,omitempty" db:"ranking_score"`
+	Key            string   `json:"team" db:"key"`
+	EventKey       string   `json:"-" db:"event_key"`
+	Rank           *int     `json:"rank,omitempty" db:"rank"`
+	RankingScore   *float64 `json:"rankingScore,omitempty" db:"ranking_score"`
+	DistrictPoints *int     `json:"districtPoints,omitempty" db:"district_points"`
 }
 
 // Team holds non-event-specific team info.
 type Team struct {
-	Key      string `json:"key" db:"key"`
-	Nickname string `json:"nickname" db:"nickname"`
+	Key        string  `json:"key" db:"key"`
+	Nickname   string  `json:"nickname" db:"nickname"`
+	Name       *string `json:"name,omitempty" db:"name"`
+	City       *string `json:"city,omitempty" db:"city"`
+	StateProv  *string `json:"stateProv,omitempty" db:"state_prov"`
+	Country    *string `json:"country,omitempty" db:"country"`
+	RookieYear *int    `json:"rookieYear,omitempty" db:"rookie_year"`
 }
 
 const allTeamsKeyUpsert = `
@@ -112,16 +119,60 @@ func (s *Service) EventTeamsUpsert(ctx context.Context, teams []EventTeam) error
 	})
 }
 
+// EventTeamDistrictPointsUpsert upserts the district points earned by multiple teams at a
+// specific event into the database.
+func (s *Service) EventTeamDistrictPointsUpsert(ctx context.Context, teams []EventTeam) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		allTeamsStmt, err := tx.PrepareNamedContext(ctx, allTeamsKeyUpsert)
+		if err != nil {
+			return fmt.Errorf("unable to prepare all_teams upsert statement: %w", err)
+		}
+		defer allTeamsStmt.Close()
+
+		for _, team := range teams {
+			if _, err = allTeamsStmt.ExecContext(ctx, team); err != nil {
+				return fmt.Errorf("unable to upsert into all_teams: %w", err)
+			}
+		}
+
+		stmt, err := tx.PrepareNamedContext(ctx, `
+		INSERT INTO teams (key, event_key, district_points)
+		VALUES (:key, :event_key, :district_points)
+		ON CONFLICT (key, event_key)
+			DO UPDATE
+				SET district_points = :district_points
+		`)
+		if err != nil {
+			return fmt.Errorf("unable to prepare teams district points upsert statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, team := range teams {
+			if _, err = stmt.ExecContext(ctx, team); err != nil {
+				return fmt.Errorf("unable to upsert district points into teams: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
 // TeamsUpsert upserts multiple teams into the database.
 func (s *Service) TeamsUpsert(ctx context.Context, teams []Team) error {
 	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
 		stmt, err := tx.PrepareNamedContext(ctx, `
-		INSERT INTO all_teams (key, nickname)
-		VALUES (:key, :nickname)
+		INSERT INTO all_teams (key, nickname, name, city, state_prov, country, rookie_year)
+		VALUES (:key, :nickname, :name, :city, :state_prov, :country, :rookie_year)
 		ON CONFLICT (key)
 		DO
 			UPDATE
-				SET nickname = $2
+				SET
+					nickname = :nickname,
+					name = :name,
+					city = :city,
+					state_prov = :state_prov,
+					country = :country,
+					rookie_year = :rookie_year
 		`)
 		if err != nil {
 			return fmt.Errorf("unable to prepare all_teams upsert statement: %w", err)
@@ -137,6 +188,93 @@ func (s *Service) TeamsUpsert(ctx context.Context, teams []Team) error {
 	})
 }
 
+// EventTeamKeysUpsert upserts the roster of team keys for a single event into the database.
+// Existing rankings for teams already at the event are left untouched. Teams that are no
+// longer on the roster are removed, unless they have been ranked, scouted, or scheduled
+// for a match at the event.
+func (s *Service) EventTeamKeysUpsert(ctx context.Context, eventKey string, keys []string) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		if err := s.EventTeamKeysUpsertTx(ctx, tx, eventKey, keys); err != nil {
+			return err
+		}
+
+		// An empty roster is more likely to be missing than to have had every team withdraw.
+		if len(keys) == 0 {
+			return nil
+		}
+
+		var current []string
+		err := tx.SelectContext(ctx, &current, `
+		SELECT key
+		FROM teams
+		WHERE event_key = $1
+		`, eventKey)
+		if err != nil {
+			return fmt.Errorf("unable to select event team keys: %w", err)
+		}
+
+		removed := removedTeamKeys(current, keys)
+		if len(removed) == 0 {
+			return nil
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		DELETE FROM teams
+		WHERE
+			event_key = $1 AND
+			key = ANY($2) AND
+			rank IS NULL AND
+			NOT EXISTS (
+				SELECT 1 FROM reports
+				WHERE reports.event_key = teams.event_key AND reports.team_key = teams.key
+			) AND
+			NOT EXISTS (
+				SELECT 1 FROM comments
+				WHERE comments.event_key = teams.event_key AND comments.team_key = teams.key
+			) AND
+			NOT EXISTS (
+				SELECT 1 FROM pit_reports
+				WHERE pit_reports.event_key = teams.event_key AND pit_reports.team_key = teams.key
+			) AND
+			NOT EXISTS (
+				SELECT 1 FROM media
+				WHERE media.event_key = teams.event_key AND media.team_key = teams.key
+			) AND
+			NOT EXISTS (
+				SELECT 1 FROM alliances
+				INNER JOIN matches
+					ON matches.key = alliances.match_key
+				WHERE
+					matches.event_key = teams.event_key AND
+					NOT matches.tba_deleted AND
+					teams.key = ANY(alliances.team_keys)
+			)
+		`, eventKey, pq.Array(removed))
+		if err != nil {
+			return fmt.Errorf("unable to remove event team keys: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// removedTeamKeys returns the keys in current that are not in roster.
+func removedTeamKeys(current, roster []string) []string {
+	onRoster := make(map[string]bool, len(roster))
+	for _, key := range roster {
+		onRoster[key] = true
+	}
+
+	removed := []string{}
+	for _, key := range current {
+		if !onRoster[key] {
+			removed = append(removed, key)
+		}
+	}
+
+	return removed
+}
+
 // EventTeamKeysUpsertTx upserts multiple team keys from a single event into the database in the given transaction.
 func (s *Service) EventTeamKeysUpsertTx(ctx context.Context, tx *sqlx.Tx, eventKey string, keys []string) error {
 	allTeamsStmt, err := tx.PrepareContext(ctx, `
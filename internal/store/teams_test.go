@@ -0,0 +1,48 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRemovedTeamKeys(t *testing.T) {
+	testCases := []struct {
+		name     string
+		current  []string
+		roster   []string
+		expected []string
+	}{
+		{
+			name:     "no teams",
+			expected: []string{},
+		},
+		{
+			name:     "unchanged roster",
+			current:  []string{"frc254", "frc1114"},
+			roster:   []string{"frc1114", "frc254"},
+			expected: []string{},
+		},
+		{
+			name:     "added team",
+			current:  []string{"frc254"},
+			roster:   []string{"frc254", "frc2733"},
+			expected: []string{},
+		},
+		{
+			name:     "removed team drops off the roster",
+			current:  []string{"frc254", "frc1114", "frc2733"},
+			roster:   []string{"frc254", "frc2733"},
+			expected: []string{"frc1114"},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			removed := removedTeamKeys(tt.current, tt.roster)
+			if !cmp.Equal(removed, tt.expected) {
+				t.Errorf("expected %v but got %v", tt.expected, removed)
+			}
+		})
+	}
+}
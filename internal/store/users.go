@@ -3,8 +3,6 @@ package store
 import (
 	"context"
 	"database/sql"
-	"database/sql/driver"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -13,32 +11,11 @@ import (
 	"github.com/lib/pq"
 )
 
-// Roles holds information about a users roles and permissions such as whether
-// they are an administrator.
-type Roles struct {
-	IsSuperAdmin bool `json:"isSuperAdmin" yaml:"isSuperAdmin"`
-	IsAdmin      bool `json:"isAdmin" yaml:"isAdmin"`
-	IsVerified   bool `json:"isVerified" yaml:"isVerified"`
-}
-
-// Value is provided for returning the value of Roles as marshalled JSON for
-// PostgreSQL's JSONB type.
-func (r Roles) Value() (driver.Value, error) {
-	return json.Marshal(r)
-}
-
-// Scan is provided for scanning data from PostgreSQL's JSONB type into Roles.
-func (r *Roles) Scan(src interface{}) error {
-	bytes, ok := src.([]byte)
-	if !ok {
-		return errors.New("got incorrect type for jsonb")
-	}
-
-	return json.Unmarshal(bytes, r)
-}
-
 // User holds information about a user such as their id, username, and hashed
-// password.
+// password. Users can belong to many realms, and RealmID is the realm the rest of
+// the fields are for. Roles are the names of the user's roles in the realm, and
+// Permissions are every permission granted by them. Pending users have asked to
+// join the realm and are waiting for approval.
 type User struct {
 	ID              int64          `json:"id" db:"id"`
 	Username        string         `json:"username" db:"username"`
@@ -47,7 +24,9 @@ type User struct {
 	RealmID         int64          `json:"realmId" db:"realm_id"`
 	FirstName       string         `json:"firstName" db:"first_name"`
 	LastName        string         `json:"lastName" db:"last_name"`
-	Roles           Roles          `json:"roles" db:"roles"`
+	Roles           pq.StringArray `json:"roles" db:"roles"`
+	Permissions     Permissions    `json:"permissions" db:"permissions"`
+	Pending         bool           `json:"pending" db:"pending"`
 	Stars           pq.StringArray `json:"stars" db:"stars"`
 }
 
@@ -59,16 +38,65 @@ type PatchUser struct {
 	PasswordChanged *time.Time     `json:"-" db:"password_changed"`
 	FirstName       *string        `json:"firstName" db:"first_name"`
 	LastName        *string        `json:"lastName" db:"last_name"`
-	Roles           *Roles         `json:"roles" db:"roles"`
 	Stars           pq.StringArray `json:"stars"`
 }
 
-// GetUserByUsername retrieves a user from the database by username. It does not
-// retrieve the users stars.
+// usersQueryInRealm selects users along with their stars, and their roles,
+// permissions, and whether they are pending in the realm given by the SQL
+// expression realm. It must be followed by a GROUP BY users.id.
+func usersQueryInRealm(realm string) string {
+	return fmt.Sprintf(`
+	SELECT
+		users.id,
+		users.username,
+		users.hashed_password,
+		users.password_changed,
+		%[1]s AS realm_id,
+		users.first_name,
+		users.last_name,
+		ARRAY(
+			SELECT roles.name
+			FROM user_roles
+			INNER JOIN roles
+				ON roles.id = user_roles.role_id
+			WHERE user_roles.user_id = users.id AND user_roles.realm_id = %[1]s
+			ORDER BY roles.name
+		) AS roles,
+		ARRAY(
+			SELECT DISTINCT unnest(roles.permissions)
+			FROM user_roles
+			INNER JOIN roles
+				ON roles.id = user_roles.role_id
+			WHERE user_roles.user_id = users.id AND user_roles.realm_id = %[1]s
+		) AS permissions,
+		EXISTS(
+			SELECT true
+			FROM realm_memberships
+			WHERE
+				realm_memberships.user_id = users.id AND
+				realm_memberships.realm_id = %[1]s AND
+				realm_memberships.pending = true
+		) AS pending,
+		array_remove(array_agg(stars.event_key), NULL) AS stars
+	FROM users
+	LEFT JOIN
+		stars
+	ON
+		stars.user_id = users.id`, realm)
+}
+
+// usersQuery selects users in the realm they log in to by default. It must be
+// followed by a GROUP BY users.id.
+var usersQuery = usersQueryInRealm("users.realm_id")
+
+// GetUserByUsername retrieves a user from the database by username.
 func (s *Service) GetUserByUsername(ctx context.Context, username string) (User, error) {
 	var u User
 
-	err := s.db.GetContext(ctx, &u, "SELECT * FROM users WHERE username = $1", username)
+	err := s.db.GetContext(ctx, &u, usersQuery+`
+	WHERE users.username = $1
+	GROUP BY users.id
+	`, username)
 	if err == sql.ErrNoRows {
 		return u, ErrNoResults{fmt.Errorf("user %d does not exist: %w", u.ID, err)}
 	} else if err != nil {
@@ -78,73 +106,77 @@ func (s *Service) GetUserByUsername(ctx context.Context, username string) (User,
 	return u, nil
 }
 
-// CreateUser creates a given user.
+// CreateUser creates a given user as a member of their realm, with the roles
+// available in the realm named by u.Roles. If any of the roles don't exist
+// ErrNoResults is returned. If the user is pending, their membership waits for
+// approval.
 func (s *Service) CreateUser(ctx context.Context, u User) error {
 	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
-		u.PasswordChanged = time.Now()
-
-		userStmt, err := tx.PrepareNamedContext(ctx, `
-		INSERT
-			INTO
-				users (username, hashed_password, password_changed, realm_id, first_name, last_name, roles)
-			VALUES (:username, :hashed_password, :password_changed, :realm_id, :first_name, :last_name, :roles)
-			RETURNING id
-		`)
-		if err != nil {
-			return fmt.Errorf("unable to prepare user insert statement: %w", err)
-		}
+		_, err := createUserTx(ctx, tx, u)
+		return err
+	})
+}
 
-		err = userStmt.GetContext(ctx, &u.ID, u)
-		if err != nil {
-			if err, ok := err.(*pq.Error); ok {
-				if err.Code == pgExists {
-					return ErrExists{fmt.Errorf("username %q already exists: %w", u.Username, err)}
-				}
-				if err.Code == pgFKeyViolation {
-					return ErrFKeyViolation{fmt.Errorf("user fk violation on realm ID %d: %w", u.RealmID, err)}
-				}
+// createUserTx creates a user and their realm membership, roles, and stars using
+// the given transaction, and returns the user's ID.
+func createUserTx(ctx context.Context, tx *sqlx.Tx, u User) (int64, error) {
+	u.PasswordChanged = time.Now()
+
+	userStmt, err := tx.PrepareNamedContext(ctx, `
+	INSERT
+		INTO
+			users (username, hashed_password, password_changed, realm_id, first_name, last_name)
+		VALUES (:username, :hashed_password, :password_changed, :realm_id, :first_name, :last_name)
+		RETURNING id
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("unable to prepare user insert statement: %w", err)
+	}
+
+	err = userStmt.GetContext(ctx, &u.ID, u)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			if err.Code == pgExists {
+				return 0, ErrExists{fmt.Errorf("username %q already exists: %w", u.Username, err)}
+			}
+			if err.Code == pgFKeyViolation {
+				return 0, ErrFKeyViolation{fmt.Errorf("user fk violation on realm ID %d: %w", u.RealmID, err)}
 			}
-			return fmt.Errorf("unable to insert user: %w", err)
 		}
+		return 0, fmt.Errorf("unable to insert user: %w", err)
+	}
 
-		starsStmt, err := tx.PrepareContext(ctx, "INSERT INTO stars (user_id, event_key) VALUES ($1, $2)")
-		if err != nil {
-			return fmt.Errorf("unable to prepare stars insert statement: %w", err)
-		}
+	_, err = tx.ExecContext(ctx, "INSERT INTO realm_memberships (user_id, realm_id, pending) VALUES ($1, $2, $3)", u.ID, u.RealmID, u.Pending)
+	if err != nil {
+		return 0, fmt.Errorf("unable to insert realm membership: %w", err)
+	}
 
-		for _, star := range u.Stars {
-			if _, err := starsStmt.ExecContext(ctx, u.ID, star); err != nil {
-				if err, ok := err.(*pq.Error); ok && err.Code == pgFKeyViolation {
-					return ErrFKeyViolation{fmt.Errorf("user stars event key fk violation: %v", err)}
-				}
-				return fmt.Errorf("unable to insert star for user: %w", err)
+	if err := setUserRolesTx(ctx, tx, u.ID, u.RealmID, u.Roles); err != nil {
+		return 0, err
+	}
+
+	starsStmt, err := tx.PrepareContext(ctx, "INSERT INTO stars (user_id, event_key) VALUES ($1, $2)")
+	if err != nil {
+		return 0, fmt.Errorf("unable to prepare stars insert statement: %w", err)
+	}
+
+	for _, star := range u.Stars {
+		if _, err := starsStmt.ExecContext(ctx, u.ID, star); err != nil {
+			if err, ok := err.(*pq.Error); ok && err.Code == pgFKeyViolation {
+				return 0, ErrFKeyViolation{fmt.Errorf("user stars event key fk violation: %v", err)}
 			}
+			return 0, fmt.Errorf("unable to insert star for user: %w", err)
 		}
+	}
 
-		return nil
-	})
+	return u.ID, nil
 }
 
 // GetUsers retrieves all users.
 func (s *Service) GetUsers(ctx context.Context) ([]User, error) {
 	users := []User{}
 
-	err := s.db.SelectContext(ctx, &users, `
-	SELECT
-		id,
-		username,
-		hashed_password,
-		password_changed,
-		realm_id,
-		first_name,
-		last_name,
-		roles,
-		array_remove(array_agg(stars.event_key), NULL) AS stars
-	FROM users
-	LEFT JOIN
-		stars
-	ON
-		stars.user_id = users.id
+	err := s.db.SelectContext(ctx, &users, usersQuery+`
 	GROUP BY users.id
 	`)
 	if err != nil {
@@ -154,27 +186,14 @@ func (s *Service) GetUsers(ctx context.Context) ([]User, error) {
 	return users, nil
 }
 
-// GetUsersByRealm retrieves all users in a specific realm.
+// GetUsersByRealm retrieves all members of a specific realm, including pending
+// members.
 func (s *Service) GetUsersByRealm(ctx context.Context, realmID int64) ([]User, error) {
 	users := []User{}
 
-	err := s.db.SelectContext(ctx, &users, `
-	SELECT
-		id,
-		username,
-		hashed_password,
-		password_changed,
-		realm_id,
-		first_name,
-		last_name,
-		roles,
-		array_remove(array_agg(stars.event_key), NULL) AS stars
-	FROM users
-	LEFT JOIN
-		stars
-	ON
-		stars.user_id = users.id
-	WHERE realm_id = $1
+	err := s.db.SelectContext(ctx, &users, usersQueryInRealm("$1::INTEGER")+`
+	INNER JOIN realm_memberships
+		ON realm_memberships.user_id = users.id AND realm_memberships.realm_id = $1
 	GROUP BY users.id
 	`, realmID)
 	if err != nil {
@@ -188,23 +207,8 @@ func (s *Service) GetUsersByRealm(ctx context.Context, realmID int64) ([]User, e
 func (s *Service) GetUserByID(ctx context.Context, id int64) (User, error) {
 	var u User
 
-	err := s.db.GetContext(ctx, &u, `
-	SELECT
-		id,
-		username,
-		hashed_password,
-		password_changed,
-		realm_id,
-		first_name,
-		last_name,
-		roles,
-		array_remove(array_agg(stars.event_key), NULL) AS stars
-	FROM users
-	LEFT JOIN
-		stars
-	ON
-		stars.user_id = users.id
-	WHERE id = $1
+	err := s.db.GetContext(ctx, &u, usersQuery+`
+	WHERE users.id = $1
 	GROUP BY users.id
 	`, id)
 	if err == sql.ErrNoRows {
@@ -216,6 +220,27 @@ func (s *Service) GetUserByID(ctx context.Context, id int64) (User, error) {
 	return u, nil
 }
 
+// GetUserInRealm retrieves a user from the database by id, along with their roles
+// and permissions in a realm. If the user doesn't exist or isn't a member of the
+// realm ErrNoResults is returned.
+func (s *Service) GetUserInRealm(ctx context.Context, id, realmID int64) (User, error) {
+	var u User
+
+	err := s.db.GetContext(ctx, &u, usersQueryInRealm("$2::INTEGER")+`
+	INNER JOIN realm_memberships
+		ON realm_memberships.user_id = users.id AND realm_memberships.realm_id = $2
+	WHERE users.id = $1
+	GROUP BY users.id
+	`, id, realmID)
+	if err == sql.ErrNoRows {
+		return u, ErrNoResults{fmt.Errorf("user %d is not a member of realm %d: %w", id, realmID, err)}
+	} else if err != nil {
+		return u, fmt.Errorf("unable to select user: %w", err)
+	}
+
+	return u, nil
+}
+
 // PatchUser updates a user by their ID.
 func (s *Service) PatchUser(ctx context.Context, pu PatchUser) error {
 	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
@@ -231,8 +256,7 @@ func (s *Service) PatchUser(ctx context.Context, pu PatchUser) error {
 				hashed_password = COALESCE(:hashed_password, hashed_password),
 				password_changed = COALESCE(:password_changed, password_changed),
 				first_name = COALESCE(:first_name, first_name),
-				last_name = COALESCE(:last_name, last_name),
-				roles = COALESCE(:roles, roles)
+				last_name = COALESCE(:last_name, last_name)
 			WHERE
 				id = :id
 		`, pu)
@@ -269,6 +293,25 @@ func (s *Service) PatchUser(ctx context.Context, pu PatchUser) error {
 	})
 }
 
+// SetUserRoles replaces the roles of a user in a realm with the roles available
+// in the realm with the given names. If the user isn't a member of the realm or
+// any of the roles don't exist ErrNoResults is returned.
+func (s *Service) SetUserRoles(ctx context.Context, userID, realmID int64, names []string) error {
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		var member bool
+		err := tx.GetContext(ctx, &member, `
+		SELECT EXISTS(SELECT true FROM realm_memberships WHERE user_id = $1 AND realm_id = $2)
+		`, userID, realmID)
+		if err != nil {
+			return fmt.Errorf("unable to select whether user is a realm member: %w", err)
+		} else if !member {
+			return ErrNoResults{fmt.Errorf("user %d is not a member of realm %d", userID, realmID)}
+		}
+
+		return setUserRolesTx(ctx, tx, userID, realmID, names)
+	})
+}
+
 // DeleteUserByID deletes a specific user from the database.
 func (s *Service) DeleteUserByID(ctx context.Context, id int64) error {
 	res, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
@@ -283,18 +326,13 @@ func (s *Service) DeleteUserByID(ctx context.Context, id int64) error {
 	return nil
 }
 
-// DeleteUserByIDRealm deletes a specific user from the database.
+// DeleteUserByIDRealm removes a specific user from a realm, deleting the user
+// from the database if it was the only realm they were a member of. If the user
+// isn't a member of the realm ErrNoResults is returned.
 func (s *Service) DeleteUserByIDRealm(ctx context.Context, id, realmID int64) error {
-	res, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1 AND realm_id = $2", id, realmID)
-	if err != nil {
-		return fmt.Errorf("unable to delete user %d: %w", id, err)
-	}
-
-	if n, err := res.RowsAffected(); err == nil && n == 0 {
-		return ErrNoResults{errors.New("got 0 affected rows")}
-	}
-
-	return nil
+	return s.DoTransaction(ctx, func(tx *sqlx.Tx) error {
+		return removeRealmMemberTx(ctx, tx, id, realmID, false)
+	})
 }
 
 // CheckSimilarUsernameExists checks whether a user with (case insensitive) the
@@ -0,0 +1,159 @@
+package summary
+
+import (
+	"fmt"
+)
+
+// MinReliabilityWeight is the lowest weight given to a reporter, so that reports
+// from even the least reliable reporters still count for something.
+const MinReliabilityWeight = 0.1
+
+// Reliability defines how often a reporter's reports agreed with TBA score
+// breakdowns and with other reports of the same robot in the same match.
+type Reliability struct {
+	TBAComparisons    int
+	TBAErrors         int
+	PeerComparisons   int
+	PeerDisagreements int
+}
+
+// TBAErrorRate returns the fraction of comparisons with TBA data that disagreed,
+// or 0 if there were none.
+func (r Reliability) TBAErrorRate() float64 {
+	return rate(r.TBAErrors, r.TBAComparisons)
+}
+
+// PeerDisagreementRate returns the fraction of comparisons with other reports
+// that disagreed, or 0 if there were none.
+func (r Reliability) PeerDisagreementRate() float64 {
+	return rate(r.PeerDisagreements, r.PeerComparisons)
+}
+
+// ErrorRate returns the fraction of all comparisons that disagreed, or 0 if there
+// were none.
+func (r Reliability) ErrorRate() float64 {
+	return rate(r.TBAErrors+r.PeerDisagreements, r.TBAComparisons+r.PeerComparisons)
+}
+
+// Weight returns how much the reporter's reports should be weighted, from
+// MinReliabilityWeight to 1.
+func (r Reliability) Weight() float64 {
+	weight := 1 - r.ErrorRate()
+	if weight < MinReliabilityWeight {
+		return MinReliabilityWeight
+	}
+
+	return weight
+}
+
+func rate(disagreements, comparisons int) float64 {
+	if comparisons == 0 {
+		return 0
+	}
+
+	return float64(disagreements) / float64(comparisons)
+}
+
+// AnalyzeReliability analyzes how reliable each reporter is. For every report
+// reference in the schema, each reported value is compared to the value of the
+// field's VerifyTBAReference (if any) and to the values from other reports of the
+// same robot in the same match. The matches may be for any number of teams, but
+// must have ReporterIDs, RobotPosition, and ScoreBreakdown set properly.
+func AnalyzeReliability(schema Schema, matches []Match) (map[int64]Reliability, error) {
+	reliabilities := make(map[int64]Reliability)
+
+	for _, match := range matches {
+		if len(match.ReporterIDs) != len(match.Reports) {
+			return nil, fmt.Errorf("match %s has %d reports but %d reporter IDs", match.Key, len(match.Reports), len(match.ReporterIDs))
+		}
+
+		for _, field := range schema {
+			if field.ReportReference == "" {
+				continue
+			}
+
+			values := make([]*float64, len(match.Reports))
+			for i, report := range match.Reports {
+				values[i] = reportValue(report, field.ReportReference)
+			}
+
+			if field.VerifyTBAReference != "" {
+				// a missing value is nil, which verifyValue doesn't convert
+				value, _, err := tbaValue(field.VerifyTBAReference, match)
+				if err != nil {
+					return nil, fmt.Errorf("unable to get verify TBA reference value: %w", err)
+				}
+
+				if expected, ok := verifyValue(field, value); ok {
+					for i, value := range values {
+						if value == nil {
+							continue
+						}
+
+						r := reliabilities[match.ReporterIDs[i]]
+						r.TBAComparisons++
+						if *value != expected {
+							r.TBAErrors++
+						}
+						reliabilities[match.ReporterIDs[i]] = r
+					}
+				}
+			}
+
+			for i := range values {
+				for j := i + 1; j < len(values); j++ {
+					if values[i] == nil || values[j] == nil {
+						continue
+					}
+
+					disagree := *values[i] != *values[j]
+					for _, reporterID := range []int64{match.ReporterIDs[i], match.ReporterIDs[j]} {
+						r := reliabilities[reporterID]
+						r.PeerComparisons++
+						if disagree {
+							r.PeerDisagreements++
+						}
+						reliabilities[reporterID] = r
+					}
+				}
+			}
+		}
+	}
+
+	return reliabilities, nil
+}
+
+// reportValue returns the sum of the report fields with the given name, or nil
+// if the report has no fields with that name.
+func reportValue(report Report, name string) *float64 {
+	var value *float64
+	for _, field := range report {
+		if field.Name == name {
+			if value == nil {
+				value = new(float64)
+			}
+			*value += field.Value
+		}
+	}
+
+	return value
+}
+
+// verifyValue converts a TBA value to a number to compare against reports. Strings
+// are converted with the field's VerifyValues.
+func verifyValue(field SchemaField, value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case bool:
+		if value {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		v, ok := field.VerifyValues[value]
+		return v, ok
+	}
+
+	return 0, false
+}
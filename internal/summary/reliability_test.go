@@ -0,0 +1,93 @@
+package summary
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var reliabilitySchema = Schema{
+	{
+		FieldDescriptor:    FieldDescriptor{Name: "Climb Level"},
+		ReportReference:    "Climb Level",
+		VerifyTBAReference: "endgameRobot{{.RobotPosition}}",
+		VerifyValues:       map[string]float64{"None": 0, "HabLevel1": 1, "HabLevel2": 2, "HabLevel3": 3},
+	},
+	{
+		FieldDescriptor: FieldDescriptor{Name: "Cargo"},
+		ReportReference: "Cargo",
+	},
+}
+
+var reliabilityMatches = []Match{
+	{
+		Key:            "qm1",
+		RobotPosition:  2,
+		ScoreBreakdown: ScoreBreakdown{"endgameRobot2": "HabLevel3"},
+		ReporterIDs:    []int64{1, 2, 3},
+		Reports: []Report{
+			{{Name: "Climb Level", Value: 3}, {Name: "Cargo", Value: 4}},
+			{{Name: "Climb Level", Value: 3}, {Name: "Cargo", Value: 4}},
+			{{Name: "Climb Level", Value: 1}, {Name: "Cargo", Value: 8}},
+		},
+	},
+	{
+		Key:            "qm2",
+		RobotPosition:  1,
+		ScoreBreakdown: ScoreBreakdown{"endgameRobot1": "Unknown"},
+		ReporterIDs:    []int64{1},
+		Reports: []Report{
+			{{Name: "Climb Level", Value: 2}, {Name: "Cargo", Value: 2}},
+		},
+	},
+}
+
+func TestAnalyzeReliability(t *testing.T) {
+	expectedReliabilities := map[int64]Reliability{
+		1: {TBAComparisons: 1, PeerComparisons: 4, PeerDisagreements: 2},
+		2: {TBAComparisons: 1, PeerComparisons: 4, PeerDisagreements: 2},
+		3: {TBAComparisons: 1, TBAErrors: 1, PeerComparisons: 4, PeerDisagreements: 4},
+	}
+
+	reliabilities, err := AnalyzeReliability(reliabilitySchema, reliabilityMatches)
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	if !cmp.Equal(expectedReliabilities, reliabilities) {
+		t.Errorf("expected reliabilities to match expected reliabilities, but got diff: %s", cmp.Diff(expectedReliabilities, reliabilities))
+	}
+
+	if weight := reliabilities[1].Weight(); weight != 0.6 {
+		t.Errorf("expected reporter 1 weight to be 0.6, got %v", weight)
+	}
+
+	if weight := reliabilities[3].Weight(); weight != MinReliabilityWeight {
+		t.Errorf("expected reporter 3 weight to be %v, got %v", MinReliabilityWeight, weight)
+	}
+}
+
+func TestAnalyzeReliabilityMissingReporterIDs(t *testing.T) {
+	matches := []Match{{Key: "qm1", Reports: []Report{{{Name: "Cargo", Value: 1}}}}}
+
+	if _, err := AnalyzeReliability(reliabilitySchema, matches); err == nil {
+		t.Errorf("expected error but didn't get one")
+	}
+}
+
+func TestSummarizeTeamWeighted(t *testing.T) {
+	weights := map[int64]float64{1: 1, 2: 1, 3: 0.5}
+
+	summary, err := SummarizeTeamWeighted(reliabilitySchema[1:], reliabilityMatches[:1], weights)
+	if err != nil {
+		t.Fatalf("did not expect error but got: %v", err)
+	}
+
+	expectedSummary := Summary{
+		{FieldDescriptor: FieldDescriptor{Name: "Cargo"}, Max: 4.8, Average: 4.8},
+	}
+
+	if !cmp.Equal(expectedSummary, summary) {
+		t.Errorf("expected summary to match expected summary, but got diff: %s", cmp.Diff(expectedSummary, summary))
+	}
+}
@@ -24,14 +24,30 @@ type ScoreBreakdown map[string]interface{}
 // Match defines information relevant to summarizing matches (match key, reports, score
 // breakdowns, alliances). RobotPosition should be the one-indexed position of the robot
 // on the field, and the score breakdown should be the relevant score breakdown to the
-// alliance the robot was on.
+// alliance the robot was on. ReporterIDs optionally holds the ID of the reporter of each
+// report, and is needed for weighting reports and analyzing reporter reliability.
 type Match struct {
 	Key            string
 	Reports        []Report
+	ReporterIDs    []int64
 	RobotPosition  int
 	ScoreBreakdown ScoreBreakdown
 }
 
+// reportWeight returns the weight of the i-th report of the match. Reports by
+// reporters without a weight are weighted 1.
+func (m Match) reportWeight(i int, weights map[int64]float64) float64 {
+	if weights == nil || i >= len(m.ReporterIDs) {
+		return 1
+	}
+
+	if weight, ok := weights[m.ReporterIDs[i]]; ok {
+		return weight
+	}
+
+	return 1
+}
+
 // Schema defines a list of schema fields for a schema. The Schema will outline how to summarize
 // data from reports, TBA, and computed properties.
 type Schema []SchemaField
@@ -43,13 +59,17 @@ type FieldDescriptor struct {
 }
 
 // SchemaField is a singular schema field. Only specify one of: ReportReference, TBAReference,
-// Sum, or AnyOf.
+// Sum, or AnyOf. A ReportReference field may also specify VerifyTBAReference, a TBA reference
+// that the reported value should match, which is used to analyze reporter reliability. String
+// TBA values are converted to numbers with VerifyValues.
 type SchemaField struct {
 	FieldDescriptor
-	ReportReference string
-	TBAReference    string
-	Sum             []FieldDescriptor
-	AnyOf           []EqualExpression
+	ReportReference    string
+	TBAReference       string
+	Sum                []FieldDescriptor
+	AnyOf              []EqualExpression
+	VerifyTBAReference string
+	VerifyValues       map[string]float64
 }
 
 // EqualExpression defines a reference that should equal some JSON value (float64, number,
@@ -73,10 +93,17 @@ type SummaryStat struct {
 // passed must be ONLY for the team being analyzed and have RobotPosition and ScoreBreakdown
 // set properly.
 func SummarizeTeam(schema Schema, matches []Match) (Summary, error) {
+	return SummarizeTeamWeighted(schema, matches, nil)
+}
+
+// SummarizeTeamWeighted is like SummarizeTeam, but when a match has multiple reports they
+// are weighted by the weight of their reporter (see Reliability.Weight) instead of equally.
+// Reporters without a weight are weighted 1. The matches must have ReporterIDs set.
+func SummarizeTeamWeighted(schema Schema, matches []Match, weights map[int64]float64) (Summary, error) {
 	records := make(map[string][]float64)
 
 	for _, match := range matches {
-		matchRecords, err := summarizeMatch(schema, match)
+		matchRecords, err := summarizeMatch(schema, match, weights)
 		if err != nil {
 			return Summary{}, fmt.Errorf("unable to summarize match: %w", err)
 		}
@@ -85,13 +112,7 @@ func SummarizeTeam(schema Schema, matches []Match) (Summary, error) {
 			// if there are multiple reports for one match we need to
 			// average them so one match isn't weighted twice as much
 			// as another if it has two reports
-
-			var sum float64
-			for _, reportGroup := range matchRecord {
-				sum += sumJSONValues(reportGroup)
-			}
-
-			records[statName] = append(records[statName], sum/float64(len(matchRecord)))
+			records[statName] = append(records[statName], average(matchRecord))
 		}
 	}
 
@@ -127,6 +148,21 @@ func sum(values []float64) float64 {
 	return sum
 }
 
+// average returns the weighted average of the sums of each record group.
+func average(groups []recordGroup) float64 {
+	var sum, totalWeight float64
+	for _, group := range groups {
+		sum += group.weight * sumJSONValues(group.values)
+		totalWeight += group.weight
+	}
+
+	if totalWeight == 0 {
+		return 0
+	}
+
+	return sum / totalWeight
+}
+
 func sumJSONValues(values []interface{}) float64 {
 	var sum float64
 	for _, value := range values {
@@ -142,16 +178,23 @@ func sumJSONValues(values []interface{}) float64 {
 	return sum
 }
 
-// mapping of stat names to a list of report values: list of JSON values
-// (float64, bool, string)
-type rawRecords map[string][][]interface{}
+// recordGroup holds the JSON values (float64, bool, string) for a stat from a
+// single report or from TBA, and how much the group should be weighted when
+// averaging multiple groups.
+type recordGroup struct {
+	values []interface{}
+	weight float64
+}
+
+// mapping of stat names to a list of record groups
+type rawRecords map[string][]recordGroup
 
-func summarizeMatch(schema Schema, match Match) (rawRecords, error) {
+func summarizeMatch(schema Schema, match Match, weights map[int64]float64) (rawRecords, error) {
 	records := make(rawRecords)
 
 	for _, statDescription := range schema {
 		if statDescription.ReportReference != "" {
-			if err := summarizeReportReference(statDescription, match, records); err != nil {
+			if err := summarizeReportReference(statDescription, match, weights, records); err != nil {
 				return nil, fmt.Errorf("unable to summarize report reference: %w", err)
 			}
 		} else if statDescription.TBAReference != "" {
@@ -174,15 +217,18 @@ func summarizeMatch(schema Schema, match Match) (rawRecords, error) {
 	return records, nil
 }
 
-func summarizeReportReference(statDescription SchemaField, match Match, records rawRecords) error {
-	for _, report := range match.Reports {
+func summarizeReportReference(statDescription SchemaField, match Match, weights map[int64]float64, records rawRecords) error {
+	for i, report := range match.Reports {
 		var reportGroup []interface{}
 		for _, reportField := range report {
 			if reportField.Name == statDescription.ReportReference {
 				reportGroup = append(reportGroup, reportField.Value)
 			}
 		}
-		records[statDescription.Name] = append(records[statDescription.Name], reportGroup)
+		records[statDescription.Name] = append(records[statDescription.Name], recordGroup{
+			values: reportGroup,
+			weight: match.reportWeight(i, weights),
+		})
 	}
 
 	return nil
@@ -193,24 +239,31 @@ type templateData struct {
 }
 
 func summarizeTBAReference(statDescription SchemaField, match Match, records rawRecords) error {
-	tmpl, err := template.New("key").Parse(statDescription.TBAReference)
+	value, ok, err := tbaValue(statDescription.TBAReference, match)
+	if err != nil || !ok {
+		return err
+	}
+
+	records[statDescription.Name] = append(records[statDescription.Name], recordGroup{values: []interface{}{value}, weight: 1})
+
+	return nil
+}
+
+// tbaValue returns the score breakdown value for a TBA reference template, and
+// whether the score breakdown has the value.
+func tbaValue(reference string, match Match) (interface{}, bool, error) {
+	tmpl, err := template.New("key").Parse(reference)
 	if err != nil {
-		return fmt.Errorf("unable to parse tba reference template: %w", err)
+		return nil, false, fmt.Errorf("unable to parse tba reference template: %w", err)
 	}
 
 	buf := new(bytes.Buffer)
 	if err := tmpl.Execute(buf, templateData{RobotPosition: match.RobotPosition}); err != nil {
-		return fmt.Errorf("unable to execute template: %w", err)
+		return nil, false, fmt.Errorf("unable to execute template: %w", err)
 	}
 
 	value, ok := match.ScoreBreakdown[buf.String()]
-	if !ok {
-		return nil
-	}
-
-	records[statDescription.Name] = append(records[statDescription.Name], []interface{}{value})
-
-	return nil
+	return value, ok, nil
 }
 
 func summarizeSum(statDescription SchemaField, match Match, records rawRecords) error {
@@ -227,15 +280,10 @@ func summarizeSum(statDescription SchemaField, match Match, records rawRecords)
 			return nil
 		}
 
-		var statsum float64
-		for _, reportGroup := range refRecords {
-			statsum += sumJSONValues(reportGroup)
-		}
-
-		sum += statsum / float64(len(refRecords))
+		sum += average(refRecords)
 	}
 
-	records[statDescription.Name] = append(records[statDescription.Name], []interface{}{sum})
+	records[statDescription.Name] = append(records[statDescription.Name], recordGroup{values: []interface{}{sum}, weight: 1})
 
 	return nil
 }
@@ -248,16 +296,16 @@ func summarizeAnyOf(statDescription SchemaField, match Match, records rawRecords
 		}
 
 		for _, reportGroup := range refRecords {
-			for _, record := range reportGroup {
+			for _, record := range reportGroup.values {
 				if compareRecords(record, ref.Equals) {
-					records[statDescription.Name] = append(records[statDescription.Name], []interface{}{1.0})
+					records[statDescription.Name] = append(records[statDescription.Name], recordGroup{values: []interface{}{1.0}, weight: 1})
 					return nil
 				}
 			}
 		}
 	}
 
-	records[statDescription.Name] = append(records[statDescription.Name], []interface{}{0.0})
+	records[statDescription.Name] = append(records[statDescription.Name], recordGroup{values: []interface{}{0.0}, weight: 1})
 	return nil
 }
 
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -45,6 +46,28 @@ type event struct {
 	EndDate      string    `json:"end_date"`
 	Timezone     string    `json:"timezone"`
 	Webcasts     []webcast `json:"webcasts"`
+
+	EventType         *int     `json:"event_type"`
+	PlayoffTypeString *string  `json:"playoff_type_string"`
+	ParentEventKey    *string  `json:"parent_event_key"`
+	DivisionKeys      []string `json:"division_keys"`
+}
+
+type awardRecipient struct {
+	TeamKey *string `json:"team_key"`
+	Awardee *string `json:"awardee"`
+}
+
+type award struct {
+	Name          string           `json:"name"`
+	AwardType     int              `json:"award_type"`
+	RecipientList []awardRecipient `json:"recipient_list"`
+}
+
+type districtPoints struct {
+	Points map[string]struct {
+		Total int `json:"total"`
+	} `json:"points"`
 }
 
 type alliance struct {
@@ -69,6 +92,16 @@ type scoreBreakdown struct {
 	Blue map[string]interface{} `json:"blue"`
 }
 
+type team struct {
+	Key        string  `json:"key"`
+	Nickname   string  `json:"nickname"`
+	Name       *string `json:"name"`
+	City       *string `json:"city"`
+	StateProv  *string `json:"state_prov"`
+	Country    *string `json:"country"`
+	RookieYear *int    `json:"rookie_year"`
+}
+
 type rankings struct {
 	Rankings      []rank          `json:"rankings"`
 	SortOrderInfo []sortOrderInfo `json:"sort_order_info"`
@@ -148,6 +181,42 @@ func webcastURL(webcastType, channel string) (string, error) {
 	return "", errors.New("got invalid webcast url")
 }
 
+// eventType converts a TBA event type code to the event type stored in the database.
+// Unlabeled or unknown event types are nil.
+func eventType(code *int) *string {
+	if code == nil {
+		return nil
+	}
+
+	var t string
+	switch *code {
+	case 0:
+		t = store.EventTypeRegional
+	case 1:
+		t = store.EventTypeDistrict
+	case 2:
+		t = store.EventTypeDistrictChampionship
+	case 3:
+		t = store.EventTypeChampionshipDivision
+	case 4:
+		t = store.EventTypeChampionship
+	case 5:
+		t = store.EventTypeDistrictChampionshipDivision
+	case 6:
+		t = store.EventTypeFestivalOfChampions
+	case 7:
+		t = store.EventTypeRemote
+	case 99:
+		t = store.EventTypeOffseason
+	case 100:
+		t = store.EventTypePreseason
+	default:
+		return nil
+	}
+
+	return &t
+}
+
 // Ping pings the TBA /status endpoint
 func (s *Service) Ping(ctx context.Context) error {
 	req, err := http.NewRequest(http.MethodGet, s.URL+"/status", nil)
@@ -230,6 +299,11 @@ func (s *Service) GetEvents(ctx context.Context, year int) ([]store.Event, error
 			Lon:          tbaEvent.Lng,
 			GMapsURL:     tbaEvent.GMapsURL,
 			LocationName: tbaEvent.LocationName,
+
+			Type:           eventType(tbaEvent.EventType),
+			PlayoffType:    tbaEvent.PlayoffTypeString,
+			ParentEventKey: tbaEvent.ParentEventKey,
+			DivisionKeys:   tbaEvent.DivisionKeys,
 		})
 	}
 
@@ -308,7 +382,7 @@ func (s *Service) GetMatches(ctx context.Context, eventKey string) ([]store.Matc
 	return matches, nil
 }
 
-// GetTeams retrieves all teams
+// GetTeams retrieves all teams along with their name, location, and rookie year.
 func (s *Service) GetTeams(ctx context.Context) ([]store.Team, error) {
 	allTeams := []store.Team{}
 	for page := 0; page < 50; page++ {
@@ -323,7 +397,7 @@ func (s *Service) GetTeams(ctx context.Context) ([]store.Team, error) {
 			return nil, fmt.Errorf("got unexpected status for url %q: %d", response.Request.URL, response.StatusCode)
 		}
 
-		teams := []store.Team{}
+		teams := []team{}
 
 		if err := json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&teams); err != nil {
 			return nil, err
@@ -333,11 +407,44 @@ func (s *Service) GetTeams(ctx context.Context) ([]store.Team, error) {
 			return allTeams, nil
 		}
 
-		allTeams = append(allTeams, teams...)
+		for _, t := range teams {
+			allTeams = append(allTeams, store.Team{
+				Key:        t.Key,
+				Nickname:   t.Nickname,
+				Name:       t.Name,
+				City:       t.City,
+				StateProv:  t.StateProv,
+				Country:    t.Country,
+				RookieYear: t.RookieYear,
+			})
+		}
 	}
 	return allTeams, errors.New("TBA teams route gave >50 pages, either number of FRC teams exceeds 25,000 or TBA is broken")
 }
 
+// GetEventTeamKeys retrieves the keys of all teams registered for a specific event.
+// Unlike rankings, the roster is available as soon as TBA publishes it, before
+// any matches have been played.
+func (s *Service) GetEventTeamKeys(ctx context.Context, eventKey string) ([]string, error) {
+	path := fmt.Sprintf("/event/%s/teams/keys", eventKey)
+
+	response, err := s.makeRequest(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got unexpected status for url %q: %d", response.Request.URL, response.StatusCode)
+	}
+
+	keys := []string{}
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
 // GetTeamRankings retrieves all team rankings from a specific event.
 func (s *Service) GetTeamRankings(ctx context.Context, eventKey string) ([]store.EventTeam, error) {
 	path := fmt.Sprintf("/event/%s/rankings", eventKey)
@@ -386,3 +493,72 @@ func (s *Service) GetTeamRankings(ctx context.Context, eventKey string) ([]store
 
 	return teams, nil
 }
+
+// GetEventAwards retrieves all awards given at a specific event. Awards with
+// multiple recipients are returned once per recipient.
+func (s *Service) GetEventAwards(ctx context.Context, eventKey string) ([]store.Award, error) {
+	path := fmt.Sprintf("/event/%s/awards", eventKey)
+
+	response, err := s.makeRequest(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got unexpected status for url %q: %d", response.Request.URL, response.StatusCode)
+	}
+
+	var tbaAwards []award
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&tbaAwards); err != nil {
+		return nil, err
+	}
+
+	awards := []store.Award{}
+	for _, tbaAward := range tbaAwards {
+		for _, recipient := range tbaAward.RecipientList {
+			awards = append(awards, store.Award{
+				EventKey:  eventKey,
+				Name:      tbaAward.Name,
+				AwardType: tbaAward.AwardType,
+				TeamKey:   recipient.TeamKey,
+				Awardee:   recipient.Awardee,
+			})
+		}
+	}
+
+	return awards, nil
+}
+
+// GetEventDistrictPoints retrieves the district points earned by each team at a
+// specific event. Events that are not part of a district have no points.
+func (s *Service) GetEventDistrictPoints(ctx context.Context, eventKey string) ([]store.EventTeam, error) {
+	path := fmt.Sprintf("/event/%s/district_points", eventKey)
+
+	response, err := s.makeRequest(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got unexpected status for url %q: %d", response.Request.URL, response.StatusCode)
+	}
+
+	var points districtPoints
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxResponseSize)).Decode(&points); err != nil {
+		return nil, err
+	}
+
+	teams := []store.EventTeam{}
+	for teamKey, teamPoints := range points.Points {
+		total := teamPoints.Total
+		teams = append(teams, store.EventTeam{
+			Key:            teamKey,
+			EventKey:       eventKey,
+			DistrictPoints: &total,
+		})
+	}
+
+	sort.Slice(teams, func(i, j int) bool { return teams[i].Key < teams[j].Key })
+
+	return teams, nil
+}
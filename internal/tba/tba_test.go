@@ -15,10 +15,13 @@ import (
 
 type tbaServer struct {
 	*httptest.Server
-	getEventsHandler       func(w http.ResponseWriter, r *http.Request)
-	getMatchesHandler      func(w http.ResponseWriter, r *http.Request)
-	getTeamRankingsHandler func(w http.ResponseWriter, r *http.Request)
-	getTeamsHandler        func(w http.ResponseWriter, r *http.Request)
+	getEventsHandler         func(w http.ResponseWriter, r *http.Request)
+	getMatchesHandler        func(w http.ResponseWriter, r *http.Request)
+	getTeamRankingsHandler   func(w http.ResponseWriter, r *http.Request)
+	getTeamsHandler          func(w http.ResponseWriter, r *http.Request)
+	getEventTeamKeysHandler  func(w http.ResponseWriter, r *http.Request)
+	getAwardsHandler         func(w http.ResponseWriter, r *http.Request)
+	getDistrictPointsHandler func(w http.ResponseWriter, r *http.Request)
 }
 
 const testingYear = 2018
@@ -47,6 +50,9 @@ func newTBAServer() *tbaServer {
 	r.HandleFunc("/event/{eventKey}/matches", func(w http.ResponseWriter, r *http.Request) { ts.getMatchesHandler(w, r) })
 	r.HandleFunc("/event/{eventKey}/rankings", func(w http.ResponseWriter, r *http.Request) { ts.getTeamRankingsHandler(w, r) })
 	r.HandleFunc("/teams/{page}", func(w http.ResponseWriter, r *http.Request) { ts.getTeamsHandler(w, r) })
+	r.HandleFunc("/event/{eventKey}/teams/keys", func(w http.ResponseWriter, r *http.Request) { ts.getEventTeamKeysHandler(w, r) })
+	r.HandleFunc("/event/{eventKey}/awards", func(w http.ResponseWriter, r *http.Request) { ts.getAwardsHandler(w, r) })
+	r.HandleFunc("/event/{eventKey}/district_points", func(w http.ResponseWriter, r *http.Request) { ts.getDistrictPointsHandler(w, r) })
 
 	ts.Server = httptest.NewServer(r)
 
@@ -142,6 +148,10 @@ func TestGetEvents(t *testing.T) {
                     {
 						"key": "key2",
 						"short_name": "Event",
+						"event_type": 2,
+						"playoff_type_string": "Elimination Bracket (8 Alliances)",
+						"parent_event_key": null,
+						"division_keys": ["key2a", "key2b"],
 						"district": {
 							"abbreviation": "ABC",
 							"display_name": "Full ABC",
@@ -168,6 +178,8 @@ func TestGetEvents(t *testing.T) {
 						"key": "key3",
 						"name": "PIGMICE_IS_BEST",
 						"short_name": "",
+						"event_type": 99,
+						"parent_event_key": "key2",
 						"district": {
 							"abbreviation": "PNW",
 							"display_name": "Full PNW",
@@ -212,19 +224,24 @@ func TestGetEvents(t *testing.T) {
 				GMapsURL:     newString("https://www.google.com/maps?cid=7437893320196269298"),
 				LocationName: "answer",
 				Webcasts:     []string{"https://www.youtube.com/watch?v=rXP6Vz9-Jjg", "https://www.twitch.tv/firstinspires12"},
+				Type:         newString("district_championship"),
+				PlayoffType:  newString("Elimination Bracket (8 Alliances)"),
+				DivisionKeys: []string{"key2a", "key2b"},
 			}, {
-				Key:          "key3",
-				Name:         "PIGMICE_IS_BEST",
-				District:     newString("PNW"),
-				FullDistrict: newString("Full PNW"),
-				Week:         newInt(2),
-				StartDate:    time.Date(2018, 11, 19, 8, 0, 0, 0, time.UTC),
-				EndDate:      time.Date(2018, 11, 23, 8, 0, 0, 0, time.UTC),
-				Lat:          45.52,
-				Lon:          -122.681944,
-				GMapsURL:     newString("https://www.google.com/maps?cid=7437893320196269298"),
-				LocationName: "Portland",
-				Webcasts:     []string{"https://www.youtube.com/watch?v=gmsHpsSavuc"},
+				Key:            "key3",
+				Name:           "PIGMICE_IS_BEST",
+				District:       newString("PNW"),
+				FullDistrict:   newString("Full PNW"),
+				Week:           newInt(2),
+				StartDate:      time.Date(2018, 11, 19, 8, 0, 0, 0, time.UTC),
+				EndDate:        time.Date(2018, 11, 23, 8, 0, 0, 0, time.UTC),
+				Lat:            45.52,
+				Lon:            -122.681944,
+				GMapsURL:       newString("https://www.google.com/maps?cid=7437893320196269298"),
+				LocationName:   "Portland",
+				Webcasts:       []string{"https://www.youtube.com/watch?v=gmsHpsSavuc"},
+				Type:           newString("offseason"),
+				ParentEventKey: newString("key2"),
 			}},
 			expectErr: false,
 		},
@@ -623,16 +640,31 @@ func TestGetTeams(t *testing.T) {
 			},
 			teams: []store.Team{
 				{
-					Key:      "frc7500",
-					Nickname: "MARAUDERS",
+					Key:        "frc7500",
+					Nickname:   "MARAUDERS",
+					Name:       newString("NASA/Florida Power and Light/State of Florida&St Thomas Aquinas High School"),
+					City:       newString("Fort Lauderdale"),
+					StateProv:  newString("Florida"),
+					Country:    newString("USA"),
+					RookieYear: newInt(2019),
 				},
 				{
-					Key:      "frc7502",
-					Nickname: "",
+					Key:        "frc7502",
+					Nickname:   "",
+					Name:       newString("NASA/Middlebury Community Schools&Northridge High School"),
+					City:       newString("Middlebury"),
+					StateProv:  newString("Indiana"),
+					Country:    newString("USA"),
+					RookieYear: newInt(2019),
 				},
 				{
-					Key:      "frc2733",
-					Nickname: "Pigmice",
+					Key:        "frc2733",
+					Nickname:   "Pigmice",
+					Name:       newString("Daimler/TE Connectivity/Boeing/Oregon Dept of Education/FLIR/Autodesk/DW Fritz Automation/Marathon Oil/SolidWorks/Hankins Hardware&Cleveland High School&Family/Community"),
+					City:       newString("Portland"),
+					StateProv:  newString("Oregon"),
+					Country:    newString("USA"),
+					RookieYear: newInt(2009),
 				},
 			},
 			expectErr: false,
@@ -657,6 +689,91 @@ func TestGetTeams(t *testing.T) {
 	}
 }
 
+func TestGetEventTeamKeys(t *testing.T) {
+	server := newTBAServer()
+	defer server.Close()
+
+	const apiKey = "notARealKey"
+
+	s := Service{URL: server.URL, APIKey: apiKey}
+
+	const eventKey = "2019orwil"
+
+	testCases := []struct {
+		name                    string
+		getEventTeamKeysHandler func(w http.ResponseWriter, r *http.Request)
+		keys                    []string
+		expectErr               bool
+	}{
+		{
+			name: "tba event team keys route gives 500",
+			getEventTeamKeysHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			keys:      nil,
+			expectErr: true,
+		},
+		{
+			name: "tba gives empty roster",
+			getEventTeamKeysHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				if _, err := w.Write([]byte(`[]`)); err != nil {
+					t.Errorf("failed to write test data")
+				}
+			},
+			keys:      []string{},
+			expectErr: false,
+		},
+		{
+			name: "tba gives event roster",
+			getEventTeamKeysHandler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-TBA-Auth-Key") != apiKey {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				vars := mux.Vars(r)
+				if key, ok := vars["eventKey"]; !ok || key != eventKey {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+				_, err := w.Write([]byte(`
+				[
+					"frc1425",
+					"frc2733",
+					"frc4488"
+				]
+				`))
+
+				if err != nil {
+					t.Errorf("failed to write test data")
+				}
+			},
+			keys:      []string{"frc1425", "frc2733", "frc4488"},
+			expectErr: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server.getEventTeamKeysHandler = tt.getEventTeamKeysHandler
+
+			keys, err := s.GetEventTeamKeys(context.TODO(), eventKey)
+			if !tt.expectErr && err != nil {
+				t.Errorf("did not expect an error but got one: %v", err)
+			} else if tt.expectErr && err == nil {
+				t.Errorf("expected error but didnt get one: %v", err)
+			}
+
+			if !cmp.Equal(keys, tt.keys) {
+				t.Errorf("expected keys do not equal actual keys, got dif: %s", cmp.Diff(tt.keys, keys))
+			}
+		})
+	}
+}
+
 func TestGetTeamRankings(t *testing.T) {
 	server := newTBAServer()
 	defer server.Close()
@@ -850,3 +967,222 @@ func TestGetTeamRankings(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEventAwards(t *testing.T) {
+	server := newTBAServer()
+	defer server.Close()
+
+	const apiKey = "notARealKey"
+
+	s := Service{URL: server.URL, APIKey: apiKey}
+
+	const eventKey = "2019orwil"
+
+	testCases := []struct {
+		name             string
+		getAwardsHandler func(w http.ResponseWriter, r *http.Request)
+		awards           []store.Award
+		expectErr        bool
+	}{
+		{
+			name: "tba awards route gives 500",
+			getAwardsHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			awards:    nil,
+			expectErr: true,
+		},
+		{
+			name: "tba gives awards with multiple recipients",
+			getAwardsHandler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-TBA-Auth-Key") != apiKey {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				vars := mux.Vars(r)
+				if key, ok := vars["eventKey"]; !ok || key != eventKey {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+				_, err := w.Write([]byte(`
+				[
+					{
+						"award_type": 1,
+						"event_key": "2019orwil",
+						"name": "District Event Winner",
+						"recipient_list": [
+							{"awardee": null, "team_key": "frc2733"},
+							{"awardee": null, "team_key": "frc1425"}
+						],
+						"year": 2019
+					},
+					{
+						"award_type": 5,
+						"event_key": "2019orwil",
+						"name": "Volunteer of the Year",
+						"recipient_list": [
+							{"awardee": "Franklin Harding", "team_key": null}
+						],
+						"year": 2019
+					}
+				]
+				`))
+
+				if err != nil {
+					t.Errorf("failed to write test data")
+				}
+			},
+			awards: []store.Award{
+				{
+					EventKey:  eventKey,
+					Name:      "District Event Winner",
+					AwardType: 1,
+					TeamKey:   newString("frc2733"),
+				},
+				{
+					EventKey:  eventKey,
+					Name:      "District Event Winner",
+					AwardType: 1,
+					TeamKey:   newString("frc1425"),
+				},
+				{
+					EventKey:  eventKey,
+					Name:      "Volunteer of the Year",
+					AwardType: 5,
+					Awardee:   newString("Franklin Harding"),
+				},
+			},
+			expectErr: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server.getAwardsHandler = tt.getAwardsHandler
+
+			awards, err := s.GetEventAwards(context.TODO(), eventKey)
+			if !tt.expectErr && err != nil {
+				t.Errorf("did not expect an error but got one: %v", err)
+			} else if tt.expectErr && err == nil {
+				t.Errorf("expected error but didnt get one: %v", err)
+			}
+
+			if !cmp.Equal(awards, tt.awards) {
+				t.Errorf("expected awards do not equal actual awards, got dif: %s", cmp.Diff(tt.awards, awards))
+			}
+		})
+	}
+}
+
+func TestGetEventDistrictPoints(t *testing.T) {
+	server := newTBAServer()
+	defer server.Close()
+
+	const apiKey = "notARealKey"
+
+	s := Service{URL: server.URL, APIKey: apiKey}
+
+	const eventKey = "2019orwil"
+
+	testCases := []struct {
+		name                     string
+		getDistrictPointsHandler func(w http.ResponseWriter, r *http.Request)
+		teams                    []store.EventTeam
+		expectErr                bool
+	}{
+		{
+			name: "tba district points route gives 500",
+			getDistrictPointsHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			teams:     nil,
+			expectErr: true,
+		},
+		{
+			name: "tba gives null for non-district event",
+			getDistrictPointsHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				if _, err := w.Write([]byte(`null`)); err != nil {
+					t.Errorf("failed to write test data")
+				}
+			},
+			teams:     []store.EventTeam{},
+			expectErr: false,
+		},
+		{
+			name: "tba gives district points",
+			getDistrictPointsHandler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-TBA-Auth-Key") != apiKey {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				vars := mux.Vars(r)
+				if key, ok := vars["eventKey"]; !ok || key != eventKey {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.WriteHeader(http.StatusOK)
+				_, err := w.Write([]byte(`
+				{
+					"points": {
+						"frc2733": {
+							"alliance_points": 16,
+							"award_points": 5,
+							"elim_points": 30,
+							"qual_points": 20,
+							"total": 71
+						},
+						"frc1425": {
+							"alliance_points": 15,
+							"award_points": 0,
+							"elim_points": 30,
+							"qual_points": 18,
+							"total": 63
+						}
+					},
+					"tiebreakers": {}
+				}
+				`))
+
+				if err != nil {
+					t.Errorf("failed to write test data")
+				}
+			},
+			teams: []store.EventTeam{
+				{
+					Key:            "frc1425",
+					EventKey:       eventKey,
+					DistrictPoints: newInt(63),
+				},
+				{
+					Key:            "frc2733",
+					EventKey:       eventKey,
+					DistrictPoints: newInt(71),
+				},
+			},
+			expectErr: false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server.getDistrictPointsHandler = tt.getDistrictPointsHandler
+
+			teams, err := s.GetEventDistrictPoints(context.TODO(), eventKey)
+			if !tt.expectErr && err != nil {
+				t.Errorf("did not expect an error but got one: %v", err)
+			} else if tt.expectErr && err == nil {
+				t.Errorf("expected error but didnt get one: %v", err)
+			}
+
+			if !cmp.Equal(teams, tt.teams) {
+				t.Errorf("expected teams do not equal actual teams, got dif: %s", cmp.Diff(tt.teams, teams))
+			}
+		})
+	}
+}
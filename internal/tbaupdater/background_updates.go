@@ -64,7 +64,8 @@ func (s *Service) run(ctx context.Context) {
 	}
 }
 
-// updatePerEventData updates all data that is tied to individual events, such as match and team ranking data
+// updatePerEventData updates all data that is tied to individual events, such as match, roster, team ranking,
+// award, and district point data
 // activeOnly specifies whether only data for active (currently happening) events should be updated
 func (s *Service) updatePerEventData(ctx context.Context, activeOnly bool) {
 	var events []store.Event
@@ -90,7 +91,10 @@ func (s *Service) updatePerEventData(ctx context.Context, activeOnly bool) {
 	}
 
 	go s.updateMatches(ctx, events)
+	go s.updateEventTeamKeys(ctx, events)
 	go s.updateEventTeamRankings(ctx, events)
+	go s.updateEventAwards(ctx, events)
+	go s.updateEventDistrictPoints(ctx, events)
 }
 
 // updateEvents gets new event data from TBA and upserts that event data into the database.
@@ -205,6 +209,46 @@ func (s *Service) updateMatches(ctx context.Context, events []store.Event) {
 	}
 }
 
+// updateEventTeamKeys gets the team roster from TBA for each event and upserts it into the database. This
+// populates the teams at an event before any rankings exist.
+func (s *Service) updateEventTeamKeys(ctx context.Context, events []store.Event) {
+	for _, event := range events {
+		// custom events don't exist on TBA
+		if event.RealmID != nil {
+			continue
+		}
+
+		keys, err := s.TBA.GetEventTeamKeys(ctx, event.Key)
+		if errors.Is(err, tba.ErrNotModified{}) {
+			continue
+		} else if err != nil {
+			if ctx.Err() != context.Canceled {
+				s.Logger.WithError(err).Error("getting event team keys from TBA")
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if err := s.Store.EventTeamKeysUpsert(ctx, event.Key, keys); err != nil {
+			if ctx.Err() != context.Canceled {
+				s.Logger.WithError(err).Error("upserting event team keys")
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
 // updateEventTeamRankings gets new team rankings data from TBA for a particular event and upserts that data into the database.
 func (s *Service) updateEventTeamRankings(ctx context.Context, events []store.Event) {
 	for _, event := range events {
@@ -238,3 +282,81 @@ func (s *Service) updateEventTeamRankings(ctx context.Context, events []store.Ev
 		}
 	}
 }
+
+// updateEventAwards gets the awards given at each event from TBA and replaces the awards stored in the database.
+func (s *Service) updateEventAwards(ctx context.Context, events []store.Event) {
+	for _, event := range events {
+		// custom events don't exist on TBA
+		if event.RealmID != nil {
+			continue
+		}
+
+		awards, err := s.TBA.GetEventAwards(ctx, event.Key)
+		if errors.Is(err, tba.ErrNotModified{}) {
+			continue
+		} else if err != nil {
+			if ctx.Err() != context.Canceled {
+				s.Logger.WithError(err).Error("getting event awards from TBA")
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if err := s.Store.UpdateEventAwards(ctx, event.Key, awards); err != nil {
+			if ctx.Err() != context.Canceled {
+				s.Logger.WithError(err).Error("updating event awards")
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
+// updateEventDistrictPoints gets the district points earned at each district event from TBA and upserts them
+// into the database.
+func (s *Service) updateEventDistrictPoints(ctx context.Context, events []store.Event) {
+	for _, event := range events {
+		if event.RealmID != nil || event.District == nil {
+			continue
+		}
+
+		teams, err := s.TBA.GetEventDistrictPoints(ctx, event.Key)
+		if errors.Is(err, tba.ErrNotModified{}) {
+			continue
+		} else if err != nil {
+			if ctx.Err() != context.Canceled {
+				s.Logger.WithError(err).Error("getting event district points from TBA")
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if err := s.Store.EventTeamDistrictPointsUpsert(ctx, teams); err != nil {
+			if ctx.Err() != context.Canceled {
+				s.Logger.WithError(err).Error("upserting event district points")
+			}
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+	}
+}
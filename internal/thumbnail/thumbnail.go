@@ -0,0 +1,105 @@
+// Package thumbnail generates small JPEG previews of uploaded images.
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	// register decoders for the image formats that can be uploaded
+	_ "image/gif"
+	_ "image/png"
+)
+
+// MaxPixels is the most pixels an image can have to generate a thumbnail for.
+// Images are decoded into memory, so small files that declare huge dimensions
+// would otherwise use gigabytes of memory.
+const MaxPixels = 50000000 // 50 megapixels
+
+// ErrTooLarge is returned when an image has more than MaxPixels pixels.
+var ErrTooLarge = errors.New("image has too many pixels")
+
+// Generate decodes a JPEG, PNG, or GIF image and returns a JPEG scaled down to
+// fit within size by size pixels, keeping the aspect ratio. Images that already
+// fit are re-encoded without scaling. If the image has more than MaxPixels
+// pixels ErrTooLarge is returned without decoding it.
+func Generate(data []byte, size int) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image config: %w", err)
+	}
+
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scale(src, size), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("unable to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// scale scales an image down to fit within size by size pixels by averaging the
+// source pixels that cover each destination pixel.
+func scale(src image.Image, size int) image.Image {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	dw, dh := w, h
+	if w > size || h > size {
+		if w >= h {
+			dw, dh = size, h*size/w
+		} else {
+			dw, dh = w*size/h, size
+		}
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := bounds.Min.Y+y*h/dh, bounds.Min.Y+(y+1)*h/dh
+		if y1 == y0 {
+			y1++
+		}
+
+		for x := 0; x < dw; x++ {
+			x0, x1 := bounds.Min.X+x*w/dw, bounds.Min.X+(x+1)*w/dw
+			if x1 == x0 {
+				x1++
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sr, sg, sb, sa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(sr), g+uint64(sg), b+uint64(sb), a+uint64(sa)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
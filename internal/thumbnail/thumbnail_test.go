@@ -0,0 +1,80 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	testCases := []struct {
+		name           string
+		width, height  int
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{name: "landscape", width: 400, height: 300, expectedWidth: 200, expectedHeight: 150},
+		{name: "portrait", width: 300, height: 600, expectedWidth: 100, expectedHeight: 200},
+		{name: "small", width: 50, height: 40, expectedWidth: 50, expectedHeight: 40},
+		{name: "thin", width: 1000, height: 2, expectedWidth: 200, expectedHeight: 1},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, tt.width, tt.height))
+			for y := 0; y < tt.height; y++ {
+				for x := 0; x < tt.width; x++ {
+					src.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, src); err != nil {
+				t.Fatalf("unable to encode test image: %v", err)
+			}
+
+			thumbnail, err := Generate(buf.Bytes(), 200)
+			if err != nil {
+				t.Fatalf("did not expect error but got: %v", err)
+			}
+
+			img, err := jpeg.Decode(bytes.NewReader(thumbnail))
+			if err != nil {
+				t.Fatalf("expected thumbnail to be a jpeg, got error decoding: %v", err)
+			}
+
+			if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != tt.expectedWidth || h != tt.expectedHeight {
+				t.Errorf("expected thumbnail to be %dx%d, got %dx%d", tt.expectedWidth, tt.expectedHeight, w, h)
+			}
+		})
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	if _, err := Generate([]byte("not an image"), 200); err == nil {
+		t.Errorf("expected error but didn't get one")
+	}
+}
+
+func TestGenerateTooManyPixels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("unable to encode test image: %v", err)
+	}
+
+	// declare a 30000x30000 canvas in the IHDR chunk, which follows the 8 byte
+	// signature, 4 byte length, and 4 byte chunk type
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 30000)
+	binary.BigEndian.PutUint32(data[20:], 30000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	if _, err := Generate(data, 200); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge but got: %v", err)
+	}
+}
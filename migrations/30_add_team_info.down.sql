@@ -0,0 +1,6 @@
+ALTER TABLE all_teams
+    DROP COLUMN name,
+    DROP COLUMN city,
+    DROP COLUMN state_prov,
+    DROP COLUMN country,
+    DROP COLUMN rookie_year;
@@ -0,0 +1,6 @@
+ALTER TABLE all_teams
+    ADD COLUMN name TEXT,
+    ADD COLUMN city TEXT,
+    ADD COLUMN state_prov TEXT,
+    ADD COLUMN country TEXT,
+    ADD COLUMN rookie_year INTEGER;
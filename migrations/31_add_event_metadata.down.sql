@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP TABLE awards;
+
+ALTER TABLE teams DROP COLUMN district_points;
+
+ALTER TABLE events
+    DROP COLUMN event_type,
+    DROP COLUMN playoff_type,
+    DROP COLUMN parent_event_key,
+    DROP COLUMN division_keys;
+
+COMMIT;
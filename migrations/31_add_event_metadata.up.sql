@@ -0,0 +1,22 @@
+BEGIN;
+
+ALTER TABLE events
+    ADD COLUMN event_type TEXT,
+    ADD COLUMN playoff_type TEXT,
+    ADD COLUMN parent_event_key TEXT,
+    ADD COLUMN division_keys TEXT[];
+
+ALTER TABLE teams ADD COLUMN district_points INTEGER;
+
+CREATE TABLE IF NOT EXISTS awards (
+    id SERIAL PRIMARY KEY,
+    event_key TEXT NOT NULL REFERENCES events ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    award_type INTEGER NOT NULL,
+    team_key TEXT,
+    awardee TEXT
+);
+
+CREATE INDEX awards_event_key_idx ON awards (event_key);
+
+COMMIT;
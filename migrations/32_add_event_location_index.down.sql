@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS events_location_idx;
+
+DROP EXTENSION IF EXISTS earthdistance;
+DROP EXTENSION IF EXISTS cube;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+CREATE INDEX events_location_idx ON events USING gist (ll_to_earth(lat, lon));
+
+COMMIT;
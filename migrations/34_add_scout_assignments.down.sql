@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE scout_assignments;
+DROP TABLE scout_shifts;
+DROP TABLE scouts;
+
+COMMIT;
@@ -0,0 +1,34 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS scouts (
+    event_key TEXT NOT NULL REFERENCES events ON DELETE CASCADE,
+    realm_id INTEGER NOT NULL REFERENCES realms ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users ON DELETE CASCADE,
+
+    PRIMARY KEY (event_key, realm_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS scout_shifts (
+    id SERIAL PRIMARY KEY,
+    event_key TEXT NOT NULL,
+    realm_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+
+    FOREIGN KEY (event_key, realm_id, user_id) REFERENCES scouts ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS scout_assignments (
+    event_key TEXT NOT NULL REFERENCES events ON DELETE CASCADE,
+    realm_id INTEGER NOT NULL REFERENCES realms ON DELETE CASCADE,
+    match_key TEXT NOT NULL REFERENCES matches ON DELETE CASCADE,
+    team_key TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users ON DELETE CASCADE,
+
+    PRIMARY KEY (realm_id, match_key, team_key)
+);
+
+CREATE INDEX scout_assignments_event_key_idx ON scout_assignments (event_key, realm_id);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE comment_revisions;
+DROP TABLE report_revisions;
+
+COMMIT;
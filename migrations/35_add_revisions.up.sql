@@ -0,0 +1,33 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS report_revisions (
+    id SERIAL PRIMARY KEY,
+    report_id INTEGER NOT NULL REFERENCES reports ON DELETE CASCADE,
+    editor_id INTEGER REFERENCES users ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    old_data JSONB,
+    data JSONB NOT NULL
+);
+
+CREATE INDEX report_revisions_report_id_idx ON report_revisions (report_id);
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id SERIAL PRIMARY KEY,
+    comment_id INTEGER NOT NULL REFERENCES comments ON DELETE CASCADE,
+    editor_id INTEGER REFERENCES users ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    old_comment TEXT,
+    comment TEXT NOT NULL
+);
+
+CREATE INDEX comment_revisions_comment_id_idx ON comment_revisions (comment_id);
+
+-- existing reports and comments start with a single revision holding their
+-- current contents, as of when the migration ran
+INSERT INTO report_revisions (report_id, editor_id, data)
+SELECT id, reporter_id, data FROM reports;
+
+INSERT INTO comment_revisions (comment_id, editor_id, comment)
+SELECT id, reporter_id, comment FROM comments;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE comments DROP COLUMN excluded;
+ALTER TABLE reports DROP COLUMN excluded;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE reports ADD COLUMN excluded BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE comments ADD COLUMN excluded BOOLEAN NOT NULL DEFAULT false;
+
+COMMIT;
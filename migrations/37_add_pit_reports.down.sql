@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP TABLE pit_reports;
+
+ALTER TABLE events DROP COLUMN pit_schema_id;
+
+DELETE FROM schemas WHERE kind = 'pit';
+ALTER TABLE schemas DROP CONSTRAINT schemas_year_kind_key;
+ALTER TABLE schemas ADD CONSTRAINT schemas_year_key UNIQUE (year);
+ALTER TABLE schemas DROP COLUMN kind;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+ALTER TABLE schemas ADD COLUMN kind TEXT NOT NULL DEFAULT 'match' CHECK (kind IN ('match', 'pit'));
+ALTER TABLE schemas DROP CONSTRAINT schemas_year_key;
+ALTER TABLE schemas ADD CONSTRAINT schemas_year_kind_key UNIQUE (year, kind);
+
+ALTER TABLE events ADD COLUMN pit_schema_id INTEGER REFERENCES schemas ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS pit_reports (
+    id SERIAL PRIMARY KEY,
+    event_key TEXT NOT NULL REFERENCES events ON DELETE CASCADE,
+    team_key TEXT NOT NULL,
+    reporter_id INTEGER REFERENCES users ON DELETE SET NULL,
+    realm_id INTEGER REFERENCES realms ON DELETE SET NULL,
+    data JSONB NOT NULL,
+
+    UNIQUE (event_key, team_key, reporter_id)
+);
+
+COMMIT;
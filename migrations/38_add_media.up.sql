@@ -0,0 +1,22 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS media (
+    id SERIAL PRIMARY KEY,
+    blob_key TEXT NOT NULL UNIQUE,
+    thumbnail_key TEXT,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    uploader_id INTEGER REFERENCES users ON DELETE SET NULL,
+    realm_id INTEGER REFERENCES realms ON DELETE SET NULL,
+    event_key TEXT NOT NULL REFERENCES events ON DELETE CASCADE,
+    team_key TEXT NOT NULL,
+    pit_report_id INTEGER REFERENCES pit_reports ON DELETE CASCADE,
+    comment_id INTEGER REFERENCES comments ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK ((pit_report_id IS NULL) != (comment_id IS NULL))
+);
+
+CREATE INDEX media_event_key_team_key_idx ON media (event_key, team_key);
+
+COMMIT;
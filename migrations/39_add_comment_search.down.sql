@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX comments_comment_search_idx;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+CREATE INDEX comments_comment_search_idx ON comments USING GIN (to_tsvector('english', comment));
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE comments_comment_tags;
+DROP TABLE comment_tags;
+
+COMMIT;
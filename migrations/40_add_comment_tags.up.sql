@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS comment_tags (
+    id SERIAL PRIMARY KEY,
+    realm_id INTEGER NOT NULL REFERENCES realms ON DELETE CASCADE,
+    name TEXT NOT NULL,
+
+    UNIQUE (realm_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS comments_comment_tags (
+    comment_id INTEGER NOT NULL REFERENCES comments ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES comment_tags ON DELETE CASCADE,
+
+    PRIMARY KEY (comment_id, tag_id)
+);
+
+CREATE INDEX comments_comment_tags_tag_id_idx ON comments_comment_tags (tag_id);
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN roles JSONB;
+
+UPDATE users
+SET roles = (
+    SELECT jsonb_build_object(
+        'isSuperAdmin', COALESCE(bool_or(roles.name = 'super-admin'), false),
+        'isAdmin', COALESCE(bool_or(roles.name = 'admin'), false),
+        'isVerified', COALESCE(bool_or(roles.name != 'viewer'), false)
+    )
+    FROM user_roles
+    INNER JOIN roles
+        ON roles.id = user_roles.role_id
+    WHERE user_roles.user_id = users.id
+);
+
+DROP TABLE user_roles;
+DROP TABLE roles;
+
+COMMIT;
@@ -0,0 +1,43 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS roles (
+    id SERIAL PRIMARY KEY,
+    realm_id INTEGER REFERENCES realms ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    permissions TEXT[] NOT NULL DEFAULT '{}'
+);
+
+-- built-in roles have no realm, and role names are unique within a realm
+CREATE UNIQUE INDEX roles_realm_id_name_key ON roles (COALESCE(realm_id, 0), name);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INTEGER NOT NULL REFERENCES users ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles ON DELETE CASCADE,
+
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name, permissions) VALUES
+    ('viewer', '{reports:view}'),
+    ('scout', '{reports:view,reports:submit}'),
+    ('strategist', '{reports:view,reports:submit,scouting:manage}'),
+    ('admin', '{reports:view,reports:submit,reports:moderate,scouting:manage,events:manage,schemas:manage,users:manage,realm:manage}'),
+    ('super-admin', '{all:manage}');
+
+INSERT INTO user_roles (user_id, role_id)
+    SELECT users.id, roles.id
+    FROM users
+    INNER JOIN roles
+        ON roles.realm_id IS NULL AND roles.name = CASE
+            WHEN (users.roles->>'isSuperAdmin')::BOOLEAN THEN 'super-admin'
+            WHEN (users.roles->>'isAdmin')::BOOLEAN THEN 'admin'
+            ELSE 'scout'
+        END
+    WHERE
+        (users.roles->>'isSuperAdmin')::BOOLEAN OR
+        (users.roles->>'isAdmin')::BOOLEAN OR
+        (users.roles->>'isVerified')::BOOLEAN;
+
+ALTER TABLE users DROP COLUMN roles;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS sharing_agreements (
+    id SERIAL PRIMARY KEY,
+    realm_id INTEGER NOT NULL REFERENCES realms ON DELETE CASCADE,
+    partner_realm_id INTEGER NOT NULL REFERENCES realms ON DELETE CASCADE,
+    event_key TEXT REFERENCES events ON DELETE CASCADE,
+    share_reports BOOLEAN NOT NULL DEFAULT false,
+    share_comments BOOLEAN NOT NULL DEFAULT false,
+    share_pit_data BOOLEAN NOT NULL DEFAULT false,
+    accepted BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    CHECK (realm_id != partner_realm_id)
+);
+
+CREATE INDEX sharing_agreements_realm_id_idx ON sharing_agreements (realm_id);
+CREATE INDEX sharing_agreements_partner_realm_id_idx ON sharing_agreements (partner_realm_id);
+
+COMMIT;
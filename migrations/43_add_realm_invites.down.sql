@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE membership_requests;
+DROP TABLE realm_invites;
+
+COMMIT;
@@ -0,0 +1,26 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS realm_invites (
+    id SERIAL PRIMARY KEY,
+    realm_id INTEGER NOT NULL REFERENCES realms ON DELETE CASCADE,
+    code TEXT NOT NULL UNIQUE,
+    roles TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMPTZ,
+    creator_id INTEGER REFERENCES users ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX realm_invites_realm_id_idx ON realm_invites (realm_id);
+
+CREATE TABLE IF NOT EXISTS membership_requests (
+    user_id INTEGER PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- users without any roles were never verified, so they are waiting for approval
+INSERT INTO membership_requests (user_id)
+    SELECT users.id
+    FROM users
+    WHERE NOT EXISTS (SELECT true FROM user_roles WHERE user_roles.user_id = users.id);
+
+COMMIT;
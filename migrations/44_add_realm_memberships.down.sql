@@ -0,0 +1,26 @@
+BEGIN;
+
+DELETE FROM user_roles
+USING users
+WHERE users.id = user_roles.user_id AND users.realm_id != user_roles.realm_id;
+
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_user_id_realm_id_fkey;
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_pkey;
+ALTER TABLE user_roles DROP COLUMN realm_id;
+ALTER TABLE user_roles ADD PRIMARY KEY (user_id, role_id);
+
+CREATE TABLE IF NOT EXISTS membership_requests (
+    user_id INTEGER PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO membership_requests (user_id, created_at)
+    SELECT realm_memberships.user_id, realm_memberships.created_at
+    FROM realm_memberships
+    INNER JOIN users
+        ON users.id = realm_memberships.user_id AND users.realm_id = realm_memberships.realm_id
+    WHERE realm_memberships.pending = true;
+
+DROP TABLE realm_memberships;
+
+COMMIT;
@@ -0,0 +1,39 @@
+BEGIN;
+
+-- users.realm_id is now the realm users log in to by default
+CREATE TABLE IF NOT EXISTS realm_memberships (
+    user_id INTEGER NOT NULL REFERENCES users ON DELETE CASCADE,
+    realm_id INTEGER NOT NULL REFERENCES realms ON DELETE CASCADE,
+    pending BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (user_id, realm_id)
+);
+
+CREATE INDEX realm_memberships_realm_id_idx ON realm_memberships (realm_id);
+
+INSERT INTO realm_memberships (user_id, realm_id, pending, created_at)
+    SELECT users.id, users.realm_id, membership_requests.user_id IS NOT NULL, COALESCE(membership_requests.created_at, now())
+    FROM users
+    LEFT JOIN membership_requests
+        ON membership_requests.user_id = users.id;
+
+DROP TABLE membership_requests;
+
+-- roles are given to users per realm
+ALTER TABLE user_roles ADD COLUMN realm_id INTEGER;
+
+UPDATE user_roles
+SET realm_id = users.realm_id
+FROM users
+WHERE users.id = user_roles.user_id;
+
+ALTER TABLE user_roles ALTER COLUMN realm_id SET NOT NULL;
+ALTER TABLE user_roles DROP CONSTRAINT user_roles_pkey;
+ALTER TABLE user_roles ADD PRIMARY KEY (user_id, realm_id, role_id);
+ALTER TABLE user_roles
+    ADD FOREIGN KEY (user_id, realm_id)
+        REFERENCES realm_memberships
+        ON DELETE CASCADE;
+
+COMMIT;
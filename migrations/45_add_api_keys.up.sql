@@ -0,0 +1,20 @@
+BEGIN;
+
+-- API keys act as their creator in a single realm, limited to their scopes
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    realm_id INTEGER NOT NULL,
+    creator_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (creator_id, realm_id) REFERENCES realm_memberships ON DELETE CASCADE
+);
+
+CREATE INDEX api_keys_realm_id_idx ON api_keys (realm_id);
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- failed logins by username or IP address, shared by every server so they agree
+-- on who is locked out
+CREATE TABLE IF NOT EXISTS login_failures (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE password_resets;
+DROP TABLE password_policies;
+
+COMMIT;
@@ -0,0 +1,26 @@
+BEGIN;
+
+-- passwords of realm members must follow the realm's policy, and realms without
+-- one use the default policy
+CREATE TABLE IF NOT EXISTS password_policies (
+    realm_id INTEGER PRIMARY KEY REFERENCES realms ON DELETE CASCADE,
+    min_length INTEGER NOT NULL DEFAULT 8,
+    require_mixed_case BOOLEAN NOT NULL DEFAULT false,
+    require_digit BOOLEAN NOT NULL DEFAULT false,
+    require_symbol BOOLEAN NOT NULL DEFAULT false
+);
+
+-- one-time tokens generated by admins to let users set a new password
+CREATE TABLE IF NOT EXISTS password_resets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users ON DELETE CASCADE,
+    creator_id INTEGER REFERENCES users ON DELETE SET NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX password_resets_user_id_idx ON password_resets (user_id);
+
+COMMIT;
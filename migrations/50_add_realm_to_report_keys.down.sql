@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE pit_reports DROP CONSTRAINT pit_reports_event_key_team_key_reporter_id_realm_id_key;
+ALTER TABLE pit_reports ADD CONSTRAINT pit_reports_event_key_team_key_reporter_id_key UNIQUE (event_key, team_key, reporter_id);
+
+ALTER TABLE comments DROP CONSTRAINT comments_event_key_match_key_team_key_reporter_id_realm_id_key;
+ALTER TABLE comments ADD CONSTRAINT comments_event_key_match_key_team_key_reporter_id_key UNIQUE (event_key, match_key, team_key, reporter_id);
+
+ALTER TABLE reports DROP CONSTRAINT reports_event_key_match_key_team_key_reporter_id_realm_id_key;
+ALTER TABLE reports ADD CONSTRAINT reports_event_key_match_key_team_key_reporter_id_key UNIQUE (event_key, match_key, team_key, reporter_id);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE calendar_tokens DROP COLUMN realm_id;
+
+COMMIT;
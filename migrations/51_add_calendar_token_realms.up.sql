@@ -0,0 +1,28 @@
+BEGIN;
+
+-- calendar tokens show a single realm's data, and are revoked when the user
+-- leaves it
+ALTER TABLE calendar_tokens ADD COLUMN realm_id INTEGER;
+
+UPDATE calendar_tokens
+SET realm_id = users.realm_id
+FROM users
+WHERE users.id = calendar_tokens.user_id;
+
+DELETE FROM calendar_tokens
+WHERE NOT EXISTS(
+    SELECT true
+    FROM realm_memberships
+    WHERE
+        realm_memberships.user_id = calendar_tokens.user_id AND
+        realm_memberships.realm_id = calendar_tokens.realm_id AND
+        realm_memberships.pending = false
+);
+
+ALTER TABLE calendar_tokens ALTER COLUMN realm_id SET NOT NULL;
+ALTER TABLE calendar_tokens
+    ADD FOREIGN KEY (user_id, realm_id)
+        REFERENCES realm_memberships
+        ON DELETE CASCADE;
+
+COMMIT;